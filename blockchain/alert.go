@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// balanceAlertTimeout bounds how long posting a low balance alert to the webhook may take
+const balanceAlertTimeout = 10 * time.Second
+
+// balanceAlert warns operators when the signing account's balance drops below a threshold, in the log and, if a
+// webhook is configured, with a POST of a lowBalanceAlert to it. At most one alert is raised per cooldown window. It
+// is safe for concurrent use, and a nil balanceAlert never alerts.
+type balanceAlert struct {
+	threshold *big.Int
+	webhook   string
+	cooldown  time.Duration
+	client    *http.Client
+
+	mutex    sync.Mutex
+	lastSent time.Time
+}
+
+// lowBalanceAlert is the JSON body posted to the webhook, with amounts in wei
+type lowBalanceAlert struct {
+	Address   string `json:"address"`
+	Balance   string `json:"balance"`
+	Threshold string `json:"threshold"`
+}
+
+func newBalanceAlert(threshold *big.Int, webhook string, cooldown time.Duration) *balanceAlert {
+	return &balanceAlert{
+		threshold: threshold,
+		webhook:   webhook,
+		cooldown:  cooldown,
+		client:    &http.Client{Timeout: balanceAlertTimeout},
+	}
+}
+
+// check raises an alert if balance is below the threshold and none was raised within the cooldown window. The webhook
+// is posted in the background so a slow endpoint doesn't hold up job completion.
+func (a *balanceAlert) check(account common.Address, balance *big.Int) {
+	if a == nil || balance.Cmp(a.threshold) >= 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	now := time.Now()
+	if !a.lastSent.IsZero() && now.Sub(a.lastSent) < a.cooldown {
+		a.mutex.Unlock()
+		return
+	}
+	a.lastSent = now
+	a.mutex.Unlock()
+
+	log.WithField("account", account.Hex()).
+		WithField("balance", balance).
+		WithField("threshold", a.threshold).
+		Warn("signing account balance below MIN_BALANCE_ALERT")
+
+	if a.webhook != "" {
+		go a.post(lowBalanceAlert{Address: account.Hex(), Balance: balance.String(), Threshold: a.threshold.String()})
+	}
+}
+
+// post sends alert to the webhook, logging rather than returning any failure since nothing waits on it
+func (a *balanceAlert) post(alert lowBalanceAlert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.WithError(err).Error("error marshaling low balance alert")
+		return
+	}
+
+	resp, err := a.client.Post(a.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).Error("error posting low balance alert to BALANCE_ALERT_WEBHOOK")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.WithField("status", resp.Status).Error("BALANCE_ALERT_WEBHOOK rejected low balance alert")
+	}
+}
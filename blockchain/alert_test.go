@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceAlert(t *testing.T) {
+	alerts := make(chan lowBalanceAlert, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		alert := lowBalanceAlert{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&alert))
+		alerts <- alert
+	}))
+	defer server.Close()
+
+	// Enough to pay for the completion, but below the alert threshold
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, balance: big.NewInt(200000)}
+	p := newFakeNodeProcessor(t, node)
+	p.balanceAlert = newBalanceAlert(big.NewInt(1000000), server.URL, time.Hour)
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	submit := func() {
+		require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+			&jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: signature}))
+	}
+
+	submit()
+	select {
+	case alert := <-alerts:
+		assert.Equal(t, lowBalanceAlert{Address: p.address, Balance: "200000", Threshold: "1000000"}, alert)
+	case <-time.After(5 * time.Second):
+		t.Fatal("low balance alert not posted")
+	}
+
+	// Within the cooldown the balance is still low, but no further alert is sent
+	submit()
+	select {
+	case alert := <-alerts:
+		t.Fatalf("unexpected alert %+v within cooldown", alert)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Once the cooldown has passed the next low balance alerts again
+	p.balanceAlert.mutex.Lock()
+	p.balanceAlert.lastSent = time.Now().Add(-time.Hour)
+	p.balanceAlert.mutex.Unlock()
+	submit()
+	select {
+	case <-alerts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("low balance alert not posted after cooldown")
+	}
+
+	// A balance at the threshold is fine
+	p.balanceAlert = newBalanceAlert(big.NewInt(200000), server.URL, time.Hour)
+	submit()
+	select {
+	case alert := <-alerts:
+		t.Fatalf("unexpected alert %+v at threshold", alert)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// archiveKey is the key of a log in db.LogArchiveBucketName: its block number and log index, big-endian so that Bolt
+// orders the archive as the chain does
+func archiveKey(block uint64, index uint) []byte {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key, block)
+	binary.BigEndian.PutUint32(key[8:], uint32(index))
+	return key
+}
+
+// archiveJobLogs persists jobLogs, as returned by the node and before they are decoded, to bucket. A log re-scanned
+// after a reorg or resync replaces the one archived at its position.
+func archiveJobLogs(bucket *bolt.Bucket, jobLogs []types.Log) error {
+	for _, jobLog := range jobLogs {
+		logBytes, err := json.Marshal(jobLog)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling job log")
+		}
+		if err := bucket.Put(archiveKey(jobLog.BlockNumber, jobLog.Index), logBytes); err != nil {
+			return errors.Wrap(err, "error archiving job log")
+		}
+	}
+	return nil
+}
+
+// deleteArchivedLogs deletes the archived logs after block, for when the cursor is moved back to block; the logs
+// re-scanned from there are archived afresh, and any in reorganized blocks are no longer on chain to be replayed.
+func deleteArchivedLogs(bucket *bolt.Bucket, block uint64) error {
+	// Bolt doesn't allow deleting keys while iterating over a bucket
+	var after [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(archiveKey(block+1, 0)); k != nil; k, _ = c.Next() {
+		after = append(after, append([]byte(nil), k...))
+	}
+
+	for _, k := range after {
+		if err := bucket.Delete(k); err != nil {
+			return errors.Wrap(err, "error deleting archived job log")
+		}
+	}
+	return nil
+}
+
+// ReplayLogs re-applies the job logs archived for blocks fromBlock to toBlock, inclusive, through the same handlers as
+// the event loop, without querying the chain; e.g. to rebuild job state after fixing how an event is decoded. The
+// event cursor is left where it is, and job listeners aren't notified of the transitions replayed. Only logs archived
+// while ARCHIVE_LOGS was enabled can be replayed.
+func (p Processor) ReplayLogs(fromBlock, toBlock uint64) error {
+	if p.boltDB == nil {
+		return errors.New("blockchain processing disabled")
+	}
+	if fromBlock > toBlock {
+		return errors.Errorf("replay start block %v is after end block %v", fromBlock, toBlock)
+	}
+
+	p.cursorLock.lock()
+	defer p.cursorLock.unlock()
+
+	var jobLogs []types.Log
+	var processed map[string]int
+	if err := p.boltDB.Update(func(tx *bolt.Tx) (err error) {
+		end := archiveKey(toBlock, ^uint(0))
+		c := tx.Bucket(db.LogArchiveBucketName).Cursor()
+		for k, v := c.Seek(archiveKey(fromBlock, 0)); k != nil && bytes.Compare(k, end) <= 0; k, v = c.Next() {
+			var jobLog types.Log
+			if err := json.Unmarshal(v, &jobLog); err != nil {
+				return errors.Wrapf(err, "error unmarshaling archived job log at block %v",
+					binary.BigEndian.Uint64(k))
+			}
+			jobLogs = append(jobLogs, jobLog)
+		}
+
+		processed, _, err = applyJobLogs(tx.Bucket(db.JobBucketName), p.events, jobLogs)
+		return err
+	}); err != nil {
+		return errors.Wrap(err, "error replaying archived job logs")
+	}
+
+	log.WithField("fromBlock", fromBlock).
+		WithField("toBlock", toBlock).
+		WithField("logs", len(jobLogs)).
+		WithField("processed", processed).
+		Info("replayed archived job logs")
+	return nil
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayLogs(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.archiveLogs = true
+
+	jobAddress, otherAddress := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	consumer := common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...),
+			BlockNumber: 5, TxHash: common.Hash{1}, Index: 0},
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(otherAddress), word(consumer)...),
+			BlockNumber: 5, TxHash: common.Hash{2}, Index: 1},
+	}, big.NewInt(5), common.Hash{}))
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress), BlockNumber: 8,
+			TxHash: common.Hash{3}},
+	}, big.NewInt(10), common.Hash{}))
+
+	jobs, err := db.ListJobs(boltDB, "")
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	clearJobs := func() {
+		require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+			for _, job := range jobs {
+				if err := tx.Bucket(db.JobBucketName).Delete(job.JobAddress); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+
+	// Replaying the archive into an emptied job bucket reproduces the job states without moving the cursor
+	clearJobs()
+	require.NoError(t, p.ReplayLogs(0, 10))
+	for _, job := range jobs {
+		replayed, err := db.GetJob(boltDB, job.JobAddress)
+		require.NoError(t, err)
+		require.NotNil(t, replayed)
+		assert.Equal(t, job.JobState, replayed.JobState)
+		assert.Equal(t, job.Consumer, replayed.Consumer)
+		assert.Equal(t, job.AgentAddress, replayed.AgentAddress)
+	}
+	block, _ := getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(10), block)
+
+	// Only the blocks in range are replayed
+	clearJobs()
+	require.NoError(t, p.ReplayLogs(6, 10))
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, jobFundedState, job.JobState)
+	other, err := db.GetJob(boltDB, otherAddress.Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, other)
+	assert.Error(t, p.ReplayLogs(10, 6))
+
+	// Moving the cursor back forgets the logs after it, which are archived again as they are re-scanned
+	clearJobs()
+	require.NoError(t, p.Resync(big.NewInt(6)))
+	require.NoError(t, p.ReplayLogs(0, 10))
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, jobPendingState, job.JobState)
+}
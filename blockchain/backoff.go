@@ -0,0 +1,58 @@
+package blockchain
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// backoff computes retry delays that grow exponentially with the number of consecutive failures. It is safe for
+// concurrent use.
+type backoff struct {
+	mutex    sync.Mutex
+	failures uint
+}
+
+// delay returns the wait before the next attempt: base after a success, otherwise base doubled per consecutive
+// failure, capped at max, with jitter spreading it over the upper half of that range
+func (b *backoff) delay(base, max time.Duration) time.Duration {
+	b.mutex.Lock()
+	failures := b.failures
+	b.mutex.Unlock()
+
+	if failures == 0 {
+		return base
+	}
+
+	d := base
+	for i := uint(0); i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (b *backoff) fail() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+}
+
+func (b *backoff) succeed() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+}
+
+// consecutiveFailures returns the number of failures since the last success
+func (b *backoff) consecutiveFailures() uint {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.failures
+}
@@ -0,0 +1,30 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := &backoff{}
+	base, max := 100*time.Millisecond, time.Second
+
+	assert.Equal(t, base, b.delay(base, max))
+
+	// Each failure doubles the delay until it reaches the cap; jitter keeps it within the upper half of that
+	for failures, expected := range []time.Duration{2 * base, 4 * base, 8 * base, max, max, max} {
+		b.fail()
+		assert.Equal(t, uint(failures+1), b.consecutiveFailures())
+		for i := 0; i < 20; i++ {
+			d := b.delay(base, max)
+			assert.True(t, d >= expected/2 && d <= expected, "delay %v after %v failures", d, failures+1)
+		}
+	}
+
+	// A success resets the failures and the delay returns to the poll interval
+	b.succeed()
+	assert.Equal(t, uint(0), b.consecutiveFailures())
+	assert.Equal(t, base, b.delay(base, max))
+}
@@ -5,31 +5,49 @@ package blockchain
 import (
 	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"math/big"
+	"sync"
+	"time"
 
 	"github.com/coreos/bbolt"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/pkg/errors"
 	"github.com/singnet/snet-daemon/config"
 	"github.com/singnet/snet-daemon/db"
-	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
+// Job states: a job is pending once created and funded once funded on chain. A job whose completion can't be sent
+// after all retries is failed, and is left in the db for inspection rather than resubmitted.
 const (
 	jobPendingState    = "PENDING"
 	jobFundedState     = "FUNDED"
+	jobFailedState     = "FAILED"
 	JobAddressHeader   = "snet-job-address"
 	JobSignatureHeader = "snet-job-signature"
 )
 
+// Completion policies, set by COMPLETION_POLICY, decide when a job moves toward completion. A job can only be
+// completed with its consumer's signature, which arrives with the call invoking it, so no policy completes a job that
+// hasn't been invoked.
+const (
+	// completionOnFunded completes a funded job as soon as its invocation is validated, before the call is served;
+	// the consumer pays whether or not the call then succeeds
+	completionOnFunded = "on_funded"
+	// completionOnSignal completes a job once the daemon is signalled that the call succeeded, the default
+	completionOnSignal = "on_signal"
+	// completionManual only marks a served job completed, leaving its completion to be submitted by an operator
+	// through the admin API; the old job scan skips it too
+	completionManual = "manual"
+)
+
 var (
 	// Ethereum signature prefix: see https://github.com/ethereum/go-ethereum/blob/bf468a81ec261745b25206b2a596eb0ee0a24a74/internal/ethapi/api.go#L361
 	hashPrefix32Bytes = []byte("\x19Ethereum Signed Message:\n32")
@@ -39,88 +57,543 @@ var (
 type jobInfo struct {
 	jobAddressBytes   []byte
 	jobSignatureBytes []byte
+	agentAddressBytes []byte
+	// enqueuedAt is when the job was last sent to the completion queue
+	enqueuedAt time.Time
+	// result, if set, receives the outcome of the first attempt to submit the job's completion
+	result chan completionResult
+}
+
+// completionResult is the hash of the transaction sent to complete a job, or the error that kept it from being sent
+type completionResult struct {
+	txHash common.Hash
+	err    error
+}
+
+// report passes the outcome of a submission attempt to whoever is waiting on it, if anyone still is
+func (j *jobInfo) report(txHash common.Hash, err error) {
+	if j.result == nil {
+		return
+	}
+
+	select {
+	case j.result <- completionResult{txHash, err}:
+	default:
+	}
+}
+
+// agentContract is a watched agent contract along with the job signature scheme of its contract version
+type agentContract struct {
+	address   common.Address
+	agent     AgentCompleter
+	sigHasher func([]byte) []byte
 }
 
 type Processor struct {
 	enabled            bool
-	ethClient          *ethclient.Client
-	rawClient          *rpc.Client
-	agent              *Agent
-	sigHasher          func([]byte) []byte
-	privateKey         *ecdsa.PrivateKey
+	ethClient          EthereumClient
+	rawClient          RawCaller
+	agents             []*agentContract
+	signer             TransactionSigner
 	address            string
+	nonces             *nonceTracker
 	jobCompletionQueue chan *jobInfo
 	boltDB             *bolt.DB
+	// registry, if set, resolves further agents to watch from an on-chain registry contract
+	registry *agentRegistry
+	// agentABI is the parsed agent contract ABI, and events the IDs of its job events, so neither is parsed per use
+	agentABI abi.ABI
+	events   jobEvents
+	// jobCompletionGasLimit is the static gas limit for CompleteJob transactions; 0 means estimate per transaction
+	jobCompletionGasLimit uint64
+	// jobCompletionGasBuffer is the percentage added on top of an estimated gas limit
+	jobCompletionGasBuffer uint64
+	// gasLimitCap bounds estimated gas limits; 0 means no cap
+	gasLimitCap uint64
+	// balanceAlert, if set, alerts when the balance checked before each completion is below MIN_BALANCE_ALERT
+	balanceAlert *balanceAlert
+	// settings holds the gas price multiplier and consumer allowlist, which Reload may change while the processor runs
+	settings *liveSettings
+	// batchCompletionSize is the most jobs completed in a single transaction through the Multicall contract at
+	// multicallAddress; 1 sends a CompleteJob transaction per job
+	batchCompletionSize int
+	multicallAddress    common.Address
+	// completionDelay is how long after a job is funded its completion waits before being enqueued, leaving the
+	// consumer a window for corrections; 0 enqueues it as soon as the job is invoked
+	completionDelay time.Duration
+	// relayer, if set, submits job completions through a meta-transaction relayer instead of sending them directly
+	relayer *relayerClient
+	// confirmationTimeout bounds how long a CompleteJob transaction is waited on before it is left to the old job scan
+	confirmationTimeout time.Duration
+	// resubmitInterval is how long a CompleteJob transaction may stay pending before it is replaced at a higher price
+	resubmitInterval time.Duration
+	// maxResubmits bounds the number of replacement transactions sent per job
+	maxResubmits int
+	// gasPriceBump is the percentage by which each replacement raises the gas price
+	gasPriceBump uint64
+	// maxGasPrice caps the gas price replacements may bump to; nil means no cap
+	maxGasPrice *big.Int
+	// gasTipCap is the priority fee paid above the base fee on EIP-1559 chains; gasFeeCap caps the resulting gas
+	// price, nil meaning no cap
+	gasTipCap *big.Int
+	gasFeeCap *big.Int
+	// reorgRewindDepth is how many blocks the event cursor walks back when a reorganization is detected
+	reorgRewindDepth int64
+	// blockConfirmations is the number of confirmations a block needs before its events are processed; the chain head
+	// has one, so 0 and 1 both scan up to the head
+	blockConfirmations int64
+	// deleteConfirmations is how many blocks past its JobCompleted event a job is kept, so a reorg that reverts the
+	// event can restore it; 0 deletes it as soon as the event is processed
+	deleteConfirmations int64
+	// archiveLogs persists every raw job log applied to the db, so ReplayLogs can re-apply them later
+	archiveLogs bool
+	// finalizedTag scans up to the node's finalized block instead of counting blockConfirmations back from the head
+	finalizedTag bool
+	// blocksBehindWarning is how far the event cursor may trail the chain before polls log a warning; 0 disables it
+	blocksBehindWarning int64
+	// logScanChunkSize is the maximum number of blocks covered by a single FilterLogs query
+	logScanChunkSize int64
+	// logScanMaxSplits bounds how many times a block range is halved when the node rejects its log query as too large
+	logScanMaxSplits int
+	// maxBlocksPerPoll bounds the blocks a single poll scans, so a long backfill advances over several polls; 0 means
+	// no bound
+	maxBlocksPerPoll int64
+	// subscribeEvents streams job events over a log subscription between polls instead of only polling
+	subscribeEvents bool
+	// startBlock is the first block scanned for events when no cursor has been persisted yet; nil means start at the
+	// current block
+	startBlock *big.Int
+	// historySkipTo is the block before which ranges the node has no history for are skipped instead of retried; nil
+	// means such ranges are never skipped
+	historySkipTo *big.Int
+	// pollSleep is the base interval between event polls; pollInterval adapts it to chain activity within the
+	// configured bounds
+	pollSleep    time.Duration
+	pollInterval *pollInterval
+	// pollJitter is the fraction of the poll interval by which each sleep is randomly lengthened or shortened; 0 keeps
+	// it exact
+	pollJitter float64
+	// pollBackoff tracks consecutive event poll failures; pollBackoffMax caps the resulting delay
+	pollBackoff    *backoff
+	pollBackoffMax time.Duration
+	// breaker, if set, stops event polls from calling a node that keeps failing them
+	breaker *circuitBreaker
+	// rpcTimeout bounds each node call made while processing events
+	rpcTimeout time.Duration
+	// rawBlockLookup reads block numbers and hashes with raw calls instead of ethClient.HeaderByNumber, for nodes
+	// whose headers ethClient can't unmarshal
+	rawBlockLookup bool
+	status         *processorStatus
+	// maxCompletionAttempts bounds how often a job completion that failed to send is retried before the job is marked
+	// failed; completionRetryDelay is the delay before the first retry, doubling with each attempt
+	maxCompletionAttempts int
+	completionRetryDelay  time.Duration
+	// submitAttempts bounds how often sending a completion transaction is tried when the node call fails transiently;
+	// submitRetryDelay is the delay before the second try, doubling with each one, and submitTimeout bounds each try
+	submitAttempts   int
+	submitRetryDelay time.Duration
+	submitTimeout    time.Duration
+	// minJobFunding, if set, limits automatic job completion to jobs funded with at least this many wei; other jobs are
+	// left in the db for manual completion
+	minJobFunding *big.Int
+	// dryRun logs the transaction each job completion would send instead of sending it
+	dryRun bool
+	// observerMode only follows job events into the db; no identity is loaded and no completions are submitted
+	observerMode bool
+	// grpcCompleteEnabled lets the jobs gRPC API force job completions, which spend gas; it's off unless configured,
+	// since the API doesn't authenticate its callers
+	grpcCompleteEnabled bool
+	// pendingFundings, if set, tracks jobs funded in the pending block, whose invocations are served before the
+	// funding is confirmed
+	pendingFundings *pendingFundings
+	// jobTTL is how long a pending or funded job may go unwritten before it is pruned; 0 disables pruning
+	jobTTL time.Duration
+	// oldJobScanInterval is how often the db is rescanned for completed jobs still to be submitted; 0 scans only at
+	// startup
+	oldJobScanInterval time.Duration
+	// ctx is cancelled by Stop to signal the processor loops to exit; loops tracks the running loops
+	ctx    context.Context
+	cancel context.CancelFunc
+	loops  *sync.WaitGroup
+	// queueMutex guards sends on jobCompletionQueue against Stop closing it
+	queueMutex *sync.RWMutex
+	closeQueue *sync.Once
+	// inFlight holds the jobs queued or being completed
+	inFlight *inFlightJobs
+	// confirmations, if set, bounds the completion transactions being confirmed at once, so several can be waited on
+	// while the next are sent; nil has each completion worker confirm one before sending the next
+	confirmations confirmationLimit
+	// completionPolicy is when a job moves toward completion: one of completionOnFunded, completionOnSignal or
+	// completionManual, empty meaning completionOnSignal
+	completionPolicy string
+	// completionWorkers is the number of goroutines draining jobCompletionQueue, each submitting the jobs it takes
+	completionWorkers int
+	// listeners are notified of job state transitions, and errorListeners of processing failures
+	listeners      *jobListeners
+	errorListeners *errorListeners
+	// cursorLock keeps Resync from moving the event cursor while the event loop is advancing it
+	cursorLock *cursorLock
 }
 
-// NewProcessor creates a new blockchain processor
+// NewProcessor creates a new blockchain processor connected to the configured Ethereum endpoint, and to any fallback
+// endpoints it fails over to
 func NewProcessor(boltDB *bolt.DB) (Processor, error) {
+	if !config.GetBool(config.BlockchainEnabledKey) {
+		return NewProcessorWithClients(boltDB, nil, nil)
+	}
+
+	// Setup ethereum client
+	tlsConfig, err := ethereumTLSConfig(config.GetString(config.EthereumJsonRpcTLSCertKey),
+		config.GetString(config.EthereumJsonRpcTLSKeyKey), config.GetString(config.EthereumJsonRpcTLSCAKey))
+	if err != nil {
+		return Processor{}, errors.Wrap(err, "error loading Ethereum RPC TLS settings")
+	}
+
+	var endpoints []failoverEndpoint
+	for _, url := range append([]string{config.GetString(config.EthereumJsonRpcEndpointKey)},
+		config.GetStringSlice(config.FallbackEndpointsKey)...) {
+		client, err := dialEthereum(url, config.GetString(config.EthereumJsonRpcProxyKey), tlsConfig)
+		if err != nil {
+			return Processor{}, errors.Wrapf(err, "error creating RPC client for %v", url)
+		}
+		endpoints = append(endpoints, failoverEndpoint{url: url, eth: ethclient.NewClient(client), raw: client})
+	}
+
+	var ethClient EthereumClient = endpoints[0].eth
+	var rawClient RawCaller = endpoints[0].raw
+	if len(endpoints) > 1 {
+		failover := newFailoverClient(endpoints, config.GetInt(config.RPCFailoverThresholdKey),
+			config.GetDuration(config.RPCFailbackIntervalKey))
+		ethClient, rawClient = failover, failover
+	}
+	if limit := config.GetFloat64(config.RPCRateLimitKey); limit > 0 {
+		limiter := newRateLimiter(limit)
+		ethClient, rawClient = rateLimitedEthClient{ethClient, limiter}, rateLimitedRawCaller{rawClient, limiter}
+	}
+
+	return NewProcessorWithClients(boltDB, ethClient, rawClient)
+}
+
+// NewProcessorWithClients creates a new blockchain processor that works through the given clients rather than dialing
+// the configured endpoint, e.g. so tests can substitute fakes. The clients are unused unless blockchain processing is
+// enabled.
+func NewProcessorWithClients(boltDB *bolt.DB, ethClient EthereumClient, rawClient RawCaller) (Processor, error) {
 	// TODO(aiden) accept configuration as a parameter
 
 	p := Processor{
-		jobCompletionQueue: make(chan *jobInfo, 1000),
-		enabled:            config.GetBool(config.BlockchainEnabledKey),
-		boltDB:             boltDB,
+		jobCompletionQueue:     make(chan *jobInfo, config.GetInt(config.CompletionQueueSizeKey)),
+		enabled:                config.GetBool(config.BlockchainEnabledKey),
+		boltDB:                 boltDB,
+		ethClient:              ethClient,
+		rawClient:              rawClient,
+		jobCompletionGasLimit:  uint64(config.GetInt(config.JobCompletionGasLimitKey)),
+		jobCompletionGasBuffer: uint64(config.GetInt(config.JobCompletionGasBufferKey)),
+		gasLimitCap:            uint64(config.GetInt(config.GasLimitCapKey)),
+		batchCompletionSize:    config.GetInt(config.BatchCompletionSizeKey),
+		completionWorkers:      config.GetInt(config.CompletionWorkersKey),
+		completionPolicy:       config.GetString(config.CompletionPolicyKey),
+		multicallAddress:       common.HexToAddress(config.GetString(config.MulticallAddressKey)),
+		completionDelay:        config.GetDuration(config.CompletionDelayKey),
+		confirmationTimeout:    config.GetDuration(config.CompletionTimeoutKey),
+		resubmitInterval:       config.GetDuration(config.ResubmitIntervalKey),
+		maxResubmits:           config.GetInt(config.MaxResubmitsKey),
+		gasPriceBump:           uint64(config.GetInt(config.GasPriceBumpKey)),
+		reorgRewindDepth:       int64(config.GetInt(config.ReorgRewindDepthKey)),
+		blockConfirmations:     int64(config.GetInt(config.BlockConfirmationsKey)),
+		deleteConfirmations:    int64(config.GetInt(config.DeleteConfirmationsKey)),
+		archiveLogs:            config.GetBool(config.ArchiveLogsKey),
+		blocksBehindWarning:    int64(config.GetInt(config.BlocksBehindWarningKey)),
+		logScanChunkSize:       int64(config.GetInt(config.LogScanChunkSizeKey)),
+		logScanMaxSplits:       config.GetInt(config.LogScanMaxSplitsKey),
+		maxBlocksPerPoll:       int64(config.GetInt(config.MaxBlocksPerPollKey)),
+		pollSleep:              config.GetDuration(config.PollSleepKey),
+		pollJitter:             config.GetFloat64(config.PollJitterKey),
+		pollBackoff:            &backoff{},
+		pollBackoffMax:         config.GetDuration(config.PollBackoffMaxKey),
+		rpcTimeout:             config.GetDuration(config.RPCTimeoutKey),
+		rawBlockLookup:         config.GetBool(config.RawBlockLookupKey),
+		status:                 &processorStatus{},
+		jobTTL:                 config.GetDuration(config.JobTTLKey),
+		oldJobScanInterval:     config.GetDuration(config.OldJobScanIntervalKey),
+		dryRun:                 config.GetBool(config.DryRunKey),
+		observerMode:           config.GetBool(config.ObserverModeKey),
+		grpcCompleteEnabled:    config.GetBool(config.JobsGrpcCompleteKey),
+		maxCompletionAttempts:  config.GetInt(config.MaxAttemptsKey),
+		completionRetryDelay:   config.GetDuration(config.RetryDelayKey),
+		submitAttempts:         config.GetInt(config.SubmitAttemptsKey),
+		submitRetryDelay:       config.GetDuration(config.SubmitRetryDelayKey),
+		submitTimeout:          config.GetDuration(config.SubmitTimeoutKey),
+		loops:                  &sync.WaitGroup{},
+		queueMutex:             &sync.RWMutex{},
+		closeQueue:             &sync.Once{},
+		inFlight:               newInFlightJobs(),
+		listeners:              &jobListeners{},
+		errorListeners:         &errorListeners{},
+		cursorLock:             &cursorLock{},
+	}
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	if config.GetBool(config.ActOnPendingKey) {
+		p.pendingFundings = newPendingFundings()
+	}
+
+	if config.GetString(config.SubmissionBackendKey) == "relayer" {
+		p.relayer = newRelayerClient(config.GetString(config.RelayerURLKey), config.GetString(config.RelayerFormatKey))
+	}
+
+	if threshold := config.GetInt(config.CircuitBreakerThresholdKey); threshold > 0 {
+		p.breaker = newCircuitBreaker(uint(threshold), config.GetDuration(config.CircuitBreakerCooldownKey))
+	}
+
+	if n := config.GetInt(config.MaxConfirmationsKey); n > 1 {
+		p.confirmations = newConfirmationLimit(n)
+	}
+
+	agentABI, ok := agentABIVersions[config.GetString(config.AgentABIVersionKey)]
+	if !ok {
+		return p, errors.Errorf("unrecognized AGENT_ABI_VERSION '%v'", config.GetString(config.AgentABIVersionKey))
+	}
+	if a, err := parseAgentABI(agentABI); err != nil {
+		return p, err
+	} else {
+		p.agentABI, p.events = a, newJobEvents(a)
+	}
+
+	if maxGasPrice := config.GetString(config.MaxGasPriceKey); maxGasPrice != "" {
+		p.maxGasPrice, _ = new(big.Int).SetString(maxGasPrice, 10)
+	}
+
+	if gasTipCap := config.GetString(config.GasTipCapKey); gasTipCap != "" {
+		p.gasTipCap, _ = new(big.Int).SetString(gasTipCap, 10)
+	}
+
+	if gasFeeCap := config.GetString(config.GasFeeCapKey); gasFeeCap != "" {
+		p.gasFeeCap, _ = new(big.Int).SetString(gasFeeCap, 10)
+	}
+
+	if minFunding := config.GetString(config.MinJobFundingKey); minFunding != "" {
+		p.minJobFunding, _ = new(big.Int).SetString(minFunding, 10)
+		if !p.events.carriesFundedAmount() {
+			return p, errors.Errorf("MIN_JOB_FUNDING requires an agent ABI whose JobFunded event carries the funded "+
+				"amount; AGENT_ABI_VERSION '%v' doesn't", config.GetString(config.AgentABIVersionKey))
+		}
+	}
+
+	if minBalance := config.GetString(config.MinBalanceAlertKey); minBalance != "" {
+		threshold, _ := new(big.Int).SetString(minBalance, 10)
+		p.balanceAlert = newBalanceAlert(threshold, config.GetString(config.BalanceAlertWebhookKey),
+			config.GetDuration(config.BalanceAlertCooldownKey))
+	}
+
+	if startBlock := config.GetString(config.StartBlockKey); startBlock != "" {
+		p.startBlock, _ = new(big.Int).SetString(startBlock, 10)
+	}
+
+	if skipTo := config.GetString(config.HistorySkipToBlockKey); skipTo != "" {
+		p.historySkipTo, _ = new(big.Int).SetString(skipTo, 10)
+	}
+
+	p.pollInterval = newPollInterval(configuredPollInterval())
+
+	if settings, err := loadLiveSettings(); err != nil {
+		return p, err
+	} else {
+		p.settings = settings
+	}
+
+	if err := configureLogger(config.GetString(config.BlockchainLogLevelKey),
+		config.GetString(config.BlockchainLogFormatKey)); err != nil {
+		return p, err
 	}
 
 	if !p.enabled {
 		return p, nil
 	}
 
-	// Setup ethereum client
-	if client, err := rpc.Dial(config.GetString(config.EthereumJsonRpcEndpointKey)); err != nil {
-		return p, errors.Wrap(err, "error creating RPC client")
-	} else {
-		p.rawClient = client
-		p.ethClient = ethclient.NewClient(client)
+	// Refuse to run against the wrong network rather than send completions there
+	if networkID := config.GetString(config.NetworkIDKey); networkID != "" {
+		expected, _ := new(big.Int).SetString(networkID, 10)
+		if err := p.checkNetworkID(expected); err != nil {
+			return p, err
+		}
+	}
+
+	p.finalizedTag = config.GetString(config.FinalityStrategyKey) == "finalized_tag"
+
+	switch config.GetString(config.BlockchainEventModeKey) {
+	case "subscribe":
+		p.subscribeEvents = true
+	case "auto":
+		p.subscribeEvents = config.IsWebSocketEndpoint(config.GetString(config.EthereumJsonRpcEndpointKey))
+		// Streaming would apply events before they have the confirmations asked for
+		if p.subscribeEvents && (p.finalizedTag || p.blockConfirmations > 1) {
+			log.Info("polling for job events rather than subscribing, as they must be confirmed before being applied")
+			p.subscribeEvents = false
+		}
+	}
+
+	// Setup agents
+	for _, agentAddress := range config.GetStringSlice(config.AgentContractAddressKey) {
+		if a, err := p.newAgentContract(common.HexToAddress(agentAddress)); err != nil {
+			return p, err
+		} else {
+			p.agents = append(p.agents, a)
+		}
 	}
 
-	agentAddress := common.HexToAddress(config.GetString(config.AgentContractAddressKey))
+	if config.GetBool(config.UseRegistryKey) {
+		p.registry = newAgentRegistry(common.HexToAddress(config.GetString(config.RegistryAddressKey)),
+			config.GetString(config.RegistryAgentIDKey), config.GetDuration(config.RegistryRefreshIntervalKey))
+		if err := p.refreshRegistryAgent(); err != nil {
+			return p, errors.Wrap(err, "error resolving agent contract from registry")
+		}
+	}
 
-	// Setup agent
-	if a, err := NewAgent(agentAddress, p.ethClient); err != nil {
-		return p, errors.Wrap(err, "error instantiating agent")
+	// Setup identity
+	if p.observerMode {
+		log.Info("OBSERVER_MODE enabled; job completions will not be submitted")
+	} else if config.GetString(config.SignerTypeKey) == "clef" {
+		if signer, err := newClefSigner(config.GetString(config.ClefEndpointKey),
+			common.HexToAddress(config.GetString(config.ClefAccountKey))); err != nil {
+			return p, errors.Wrap(err, "error connecting to external signer")
+		} else {
+			p.signer = signer
+		}
+	} else if keystorePath := config.GetString(config.KeystorePathKey); keystorePath != "" {
+		if privKey, err := loadKeystoreKey(keystorePath, config.GetString(config.KeystorePassphraseKey)); err != nil {
+			return p, errors.Wrap(err, "error loading private key from keystore")
+		} else {
+			p.signer = &keySigner{privKey}
+		}
+	} else if privateKeyString := config.GetString(config.PrivateKeyKey); privateKeyString != "" {
+		log.Warn("PRIVATE_KEY in plaintext config is deprecated; use KEYSTORE_PATH and KEYSTORE_PASSPHRASE instead")
+		if privKey, err := crypto.HexToECDSA(privateKeyString); err != nil {
+			return p, errors.Wrap(err, "error getting private key")
+		} else {
+			p.signer = &keySigner{privKey}
+		}
+	} else if hdwalletMnemonic := config.GetString(config.HdwalletMnemonicKey); hdwalletMnemonic != "" {
+		if privKey, err := derivePrivateKey(hdwalletMnemonic, 44, 60, 0, 0, uint32(config.GetInt(config.HdwalletIndexKey))); err != nil {
+			log.WithError(err).Panic("error deriving private key")
+		} else {
+			p.signer = &keySigner{privKey}
+		}
+	}
+
+	if p.signer != nil {
+		p.address = p.signer.Address().Hex()
+	}
+
+	p.nonces = newNonceTracker(p.ethClient, common.HexToAddress(p.address))
+
+	return p, nil
+}
+
+// checkNetworkID returns an error unless the Ethereum endpoint is on the expected network
+func (p Processor) checkNetworkID(expected *big.Int) error {
+	ctx, cancel := p.rpcContext()
+	defer cancel()
+
+	networkID, err := p.ethClient.NetworkID(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error determining network id")
+	}
+
+	if networkID.Cmp(expected) != 0 {
+		return errors.Errorf("ethereum endpoint is on network %v rather than NETWORK_ID %v", networkID, expected)
+	}
+
+	return nil
+}
+
+// newAgentContract binds the agent at address and determines its job signature scheme. An address without contract
+// code, e.g. an account or a contract deployed on another network, is an error, as no job events would ever be seen
+// from it.
+func (p Processor) newAgentContract(address common.Address) (*agentContract, error) {
+	a := &agentContract{address: address}
+
+	if agent, err := NewAgent(address, p.ethClient); err != nil {
+		return nil, errors.Wrap(err, "error instantiating agent")
 	} else {
-		p.agent = a
+		a.agent = agent
 	}
 
 	// Determine "version" of agent contract and set local signature hash creator
-	if bytecode, err := p.ethClient.CodeAt(context.Background(), agentAddress, nil); err != nil {
-		return p, errors.Wrap(err, "error retrieving agent bytecode")
+	if bytecode, err := p.ethClient.CodeAt(context.Background(), address, nil); err != nil {
+		return nil, errors.Wrap(err, "error retrieving agent bytecode")
+	} else if len(bytecode) == 0 {
+		return nil, errors.Errorf("no contract deployed at agent address %v; check it is the address of the agent "+
+			"contract on the network the ethereum endpoint is on", address.Hex())
 	} else {
 		bcSum := md5.Sum(bytecode)
 
 		// Compare checksum of agent with known checksum of the first version of the agent contract, which signed
 		// the raw bytes of the address rather than the hex-encoded string
 		if bytes.Equal(bcSum[:], []byte{244, 176, 168, 6, 74, 56, 171, 175, 38, 48, 245, 246, 189, 0, 67, 200}) {
-			p.sigHasher = func(i []byte) []byte {
+			a.sigHasher = func(i []byte) []byte {
 				return crypto.Keccak256(hashPrefix32Bytes, crypto.Keccak256(i))
 			}
 		} else {
-			p.sigHasher = func(i []byte) []byte {
+			a.sigHasher = func(i []byte) []byte {
 				return crypto.Keccak256(hashPrefix42Bytes, []byte(hex.EncodeToString(i)))
 			}
 		}
 	}
 
-	// Setup identity
-	if privateKeyString := config.GetString(config.PrivateKeyKey); privateKeyString != "" {
-		if privKey, err := crypto.HexToECDSA(privateKeyString); err != nil {
-			return p, errors.Wrap(err, "error getting private key")
-		} else {
-			p.privateKey = privKey
-			p.address = crypto.PubkeyToAddress(p.privateKey.PublicKey).Hex()
+	return a, nil
+}
+
+// watchedAgents returns the configured agents followed by those resolved from the registry
+func (p Processor) watchedAgents() []*agentContract {
+	if p.registry == nil {
+		return p.agents
+	}
+	return append(append([]*agentContract{}, p.agents...), p.registry.resolved()...)
+}
+
+// agentAddresses returns the addresses of all watched agents
+func (p Processor) agentAddresses() []common.Address {
+	agents := p.watchedAgents()
+	addresses := make([]common.Address, len(agents))
+	for i, a := range agents {
+		addresses[i] = a.address
+	}
+	return addresses
+}
+
+// consumerAllowed reports whether jobs created by the given consumer are completed automatically
+func (p Processor) consumerAllowed(consumer []byte) bool {
+	return p.settings.consumerAllowed(common.BytesToAddress(consumer))
+}
+
+// fundingSufficient reports whether a job funded with the given amount is completed automatically. A job whose funded
+// amount isn't known, e.g. because its JobFunded event hasn't been seen, doesn't meet a minimum.
+func (p Processor) fundingSufficient(amount *big.Int) bool {
+	return p.minJobFunding == nil || (amount != nil && amount.Cmp(p.minJobFunding) >= 0)
+}
+
+// agentFor returns the watched agent with the given address. Jobs persisted before agents were tracked per job, and
+// jobs never seen in an event, have no address, so they fall back to the first configured agent, or the current
+// registry agent if none is configured. An address that is no agent being watched is an error, as is there being no
+// agent to fall back to.
+func (p Processor) agentFor(address []byte) (*agentContract, error) {
+	if len(address) == 0 {
+		if len(p.agents) > 0 {
+			return p.agents[0], nil
 		}
-	} else if hdwalletMnemonic := config.GetString(config.HdwalletMnemonicKey); hdwalletMnemonic != "" {
-		if privKey, err := derivePrivateKey(hdwalletMnemonic, 44, 60, 0, 0, uint32(config.GetInt(config.HdwalletIndexKey))); err != nil {
-			log.WithError(err).Panic("error deriving private key")
-		} else {
-			p.privateKey = privKey
-			p.address = crypto.PubkeyToAddress(p.privateKey.PublicKey).Hex()
+		if p.registry != nil {
+			return p.registry.current(), nil
 		}
+		return nil, errors.New("no agent contract configured")
 	}
 
-	return p, nil
+	for _, a := range p.watchedAgents() {
+		if bytes.Equal(a.address.Bytes(), address) {
+			return a, nil
+		}
+	}
+	return nil, errors.Errorf("agent contract %v is not watched", common.BytesToAddress(address).Hex())
 }
 
 func (p Processor) GrpcStreamInterceptor() grpc.StreamServerInterceptor {
@@ -131,8 +604,20 @@ func (p Processor) GrpcStreamInterceptor() grpc.StreamServerInterceptor {
 	return noOpInterceptor
 }
 
+// IsValidJobInvocation reports whether the job is funded and the signature is its consumer's, so the call may be
+// served. Under the on_funded completion policy a valid invocation also moves the job to completion.
 func (p Processor) IsValidJobInvocation(jobAddressBytes, jobSignatureBytes []byte) bool {
-	log := log.WithFields(log.Fields{
+	if !p.validJobInvocation(jobAddressBytes, jobSignatureBytes) {
+		return false
+	}
+	if p.completionPolicy == completionOnFunded {
+		p.beginJobCompletion(jobAddressBytes, jobSignatureBytes)
+	}
+	return true
+}
+
+func (p Processor) validJobInvocation(jobAddressBytes, jobSignatureBytes []byte) bool {
+	log := log.WithFields(logrus.Fields{
 		"jobAddress":   common.BytesToAddress(jobAddressBytes).Hex(),
 		"jobSignature": hex.EncodeToString(jobSignatureBytes)})
 
@@ -144,7 +629,10 @@ func (p Processor) IsValidJobInvocation(jobAddressBytes, jobSignatureBytes []byt
 		bucket := tx.Bucket(db.JobBucketName)
 		jobBytes := bucket.Get(jobAddressBytes)
 		if jobBytes != nil {
-			json.Unmarshal(jobBytes, job)
+			// An unreadable record leaves job empty, which just falls back to on-chain validation below
+			if err := json.Unmarshal(jobBytes, job); err != nil {
+				log.WithError(err).Warn("error unmarshaling job from db")
+			}
 		}
 		return nil
 	})
@@ -155,35 +643,35 @@ func (p Processor) IsValidJobInvocation(jobAddressBytes, jobSignatureBytes []byt
 		return false
 	}
 
-	v, r, s, err := parseSignature(jobSignatureBytes)
+	agent, err := p.agentFor(job.AgentAddress)
 	if err != nil {
-		log.WithError(err).Error("error parsing signature")
+		log.WithError(err).Error("error finding job's agent contract")
 		return false
 	}
 
-	pubKey, err := crypto.SigToPub(p.sigHasher(jobAddressBytes), bytes.Join([][]byte{jobSignatureBytes[0:64], {v % 27}},
-		[]byte{}))
+	signer, err := recoverJobSigner(agent, jobAddressBytes, jobSignatureBytes)
 	if err != nil {
 		log.WithError(err).Error("error recovering signature")
 		return false
 	}
 
 	// If job is FUNDED and signature validates, skip on-chain validation
-	if job.JobState == jobFundedState && bytes.Equal(crypto.PubkeyToAddress(*pubKey).Bytes(), job.Consumer) {
+	if job.JobState == jobFundedState && bytes.Equal(signer.Bytes(), job.Consumer) {
 		log.Debug("validated job invocation locally")
 		return true
 	}
 
+	// With ACT_ON_PENDING, as is a created job whose funding is only in the pending block
+	if job.JobState == jobPendingState && p.fundedPending(job, signer) {
+		log.Warn("validated job invocation against unconfirmed pending funding")
+		return true
+	}
+
 	log.Debug("unable to validate job invocation locally; falling back to on-chain validation")
 
 	// Fall back to on-chain validation
-	if validated, err := p.agent.ValidateJobInvocation(&bind.CallOpts{
-		Pending: true,
-		From:    common.HexToAddress(p.address)}, common.BytesToAddress(jobAddressBytes), v, r, s); err != nil {
-		log.WithError(err).Error("error validating job on chain")
-		return false
-	} else if !validated {
-		log.Debug("job failed to validate")
+	if err := p.validateJobOnChain(agent, jobAddressBytes, jobSignatureBytes); err != nil {
+		log.WithError(err).Debug("job failed to validate")
 		return false
 	}
 
@@ -191,33 +679,180 @@ func (p Processor) IsValidJobInvocation(jobAddressBytes, jobSignatureBytes []byt
 	return true
 }
 
+// CompleteJob signals that the service call for the job succeeded. Under the on_signal completion policy this moves
+// the job to completion; under on_funded that already happened when the invocation was validated, and under manual
+// the job is only marked completed, leaving it for an operator to submit.
 func (p Processor) CompleteJob(jobAddressBytes, jobSignatureBytes []byte) {
-	job := &db.Job{}
+	switch p.completionPolicy {
+	case completionOnFunded:
+		return
+	case completionManual:
+		if _, err := p.markJobCompleted(jobAddressBytes, jobSignatureBytes, false); err != nil {
+			log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+				WithError(err).Error("error marking job completed in db")
+			return
+		}
+		log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+			Info("COMPLETION_POLICY manual; leaving job for manual completion")
+		return
+	}
 
+	p.beginJobCompletion(jobAddressBytes, jobSignatureBytes)
+}
+
+// beginJobCompletion marks the job completed in the db and, unless it is to be left for manual completion, queues it
+func (p Processor) beginJobCompletion(jobAddressBytes, jobSignatureBytes []byte) {
 	// Mark the job completed in the db synchronously
-	if err := p.boltDB.Update(func(tx *bolt.Tx) error {
+	job, err := p.markJobCompleted(jobAddressBytes, jobSignatureBytes, false)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"jobAddress":   common.BytesToAddress(jobAddressBytes).Hex(),
+			"jobSignature": hex.EncodeToString(jobSignatureBytes),
+		}).WithError(err).Error("error marking job completed in db")
+	}
+
+	// A job from an untrusted consumer stays marked completed in the db for an operator to submit by hand
+	if !p.consumerAllowed(job.Consumer) {
+		log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+			WithField("consumer", common.BytesToAddress(job.Consumer).Hex()).
+			Info("job consumer not in allowlist; leaving job for manual completion")
+		return
+	}
+
+	// As does an underfunded job, which isn't worth the gas to complete; a job served on its pending funding is
+	// judged by the amount seen pending
+	fundedAmount := job.FundedAmount
+	if funding, ok := p.pendingFundings.lookup(common.BytesToAddress(jobAddressBytes)); ok && fundedAmount == nil {
+		fundedAmount = funding.amount
+	}
+	if !p.fundingSufficient(fundedAmount) {
+		log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+			WithField("fundedAmount", fundedAmount).
+			WithField("minJobFunding", p.minJobFunding).
+			Info("job funded below MIN_JOB_FUNDING; leaving job for manual completion")
+		return
+	}
+
+	// An observer leaves completed jobs for a daemon that can sign
+	if p.observerMode {
+		log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+			Debug("observer mode; leaving job completion to another daemon")
+		return
+	}
+
+	jobInfo := &jobInfo{jobAddressBytes: jobAddressBytes, jobSignatureBytes: jobSignatureBytes,
+		agentAddressBytes: job.AgentAddress}
+
+	// A job funded within the completion delay waits out the rest of it. Until then it is only marked completed in
+	// the db, so a restart leaves it to the old job scan, which honors the delay too.
+	if delay := p.remainingCompletionDelay(job.JobState, job.FundedAt, time.Now()); delay > 0 {
+		log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).WithField("delay", delay).
+			Debug("delaying job completion")
+		time.AfterFunc(delay, func() {
+			if p.ctx.Err() == nil {
+				p.enqueueJobCompletion(jobInfo)
+			}
+		})
+		return
+	}
+
+	// Submit the job for completion
+	p.enqueueJobCompletion(jobInfo)
+}
+
+// remainingCompletionDelay returns how much of the completion delay is left at now for a job in the given state funded
+// at funded. A job not yet seen funded waits the full delay, while one funded before its funding time was recorded has
+// long since waited it out.
+func (p Processor) remainingCompletionDelay(state string, funded, now time.Time) time.Duration {
+	if p.completionDelay <= 0 {
+		return 0
+	}
+	if state != jobFundedState {
+		return p.completionDelay
+	}
+	if funded.IsZero() {
+		return 0
+	}
+	return funded.Add(p.completionDelay).Sub(now)
+}
+
+// SubmitJobForCompletion completes a job out of band, e.g. once an operator has resolved its signature by hand. The
+// signature is checked as for a resubmitted job and recorded in the db, and the job is queued for completion; a job
+// that had failed gets a fresh set of attempts, and one from a consumer outside the allowlist or funded below
+// MIN_JOB_FUNDING is completed regardless. It returns the hash of the transaction sent once the job reaches the front
+// of the queue.
+func (p Processor) SubmitJobForCompletion(ctx context.Context, jobAddressBytes, jobSignatureBytes []byte) (common.Hash,
+	error) {
+	if !p.enabled || p.signer == nil {
+		return common.Hash{}, errors.New("blockchain processing disabled")
+	}
+
+	if p.observerMode {
+		return common.Hash{}, errors.New("job completion disabled in observer mode")
+	}
+
+	if len(jobAddressBytes) != common.AddressLength {
+		return common.Hash{}, errors.New("invalid job address")
+	}
+
+	if err := p.VerifyJobSignature(jobAddressBytes, jobSignatureBytes); err != nil {
+		return common.Hash{}, errors.Wrap(err, "invalid job signature")
+	}
+
+	job, err := p.markJobCompleted(jobAddressBytes, jobSignatureBytes, true)
+	if err != nil {
+		return common.Hash{}, errors.Wrap(err, "error marking job completed in db")
+	}
+
+	jobInfo := &jobInfo{jobAddressBytes: jobAddressBytes, jobSignatureBytes: jobSignatureBytes,
+		agentAddressBytes: job.AgentAddress, result: make(chan completionResult, 1)}
+	if !p.inFlight.add(jobAddressBytes) {
+		return common.Hash{}, errors.New("job completion already in flight")
+	}
+	p.putOutbox(jobInfo)
+	p.queueJobCompletion(jobInfo)
+
+	select {
+	case result := <-jobInfo.result:
+		return result.txHash, result.err
+	case <-p.ctx.Done():
+		return common.Hash{}, errors.New("blockchain processor stopping; job left for resubmission on restart")
+	case <-ctx.Done():
+		return common.Hash{}, errors.Wrap(ctx.Err(), "error waiting for job completion to be submitted")
+	}
+}
+
+// markJobCompleted records the job as completed locally with the given signature, optionally resetting a failed
+// job's completion attempts so it can be retried
+func (p Processor) markJobCompleted(jobAddressBytes, jobSignatureBytes []byte, resetFailed bool) (*db.Job, error) {
+	job := &db.Job{}
+
+	err := p.boltDB.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(db.JobBucketName)
 		jobBytes := bucket.Get(jobAddressBytes)
 		if jobBytes != nil {
-			json.Unmarshal(jobBytes, job)
+			// Writing back a job that failed to deserialize would clobber the record with a zero-valued one
+			if err := json.Unmarshal(jobBytes, job); err != nil {
+				return errors.Wrap(err, "error unmarshaling job")
+			}
 		}
 		job.Completed = true
 		job.JobSignature = jobSignatureBytes
+		if resetFailed && job.JobState == jobFailedState {
+			job.JobState = jobFundedState
+			job.CompletionAttempts = 0
+			if err := db.AppendJobHistory(tx, jobAddressBytes, db.HistoryEntry{Timestamp: time.Now(),
+				FromState: jobFailedState, ToState: jobFundedState}); err != nil {
+				return err
+			}
+		}
+		job.Touch(time.Now())
 		jobBytes, err := json.Marshal(job)
 		if err != nil {
-			return err
-		}
-		if err = bucket.Put(jobAddressBytes, jobBytes); err != nil {
-			return err
+			return errors.Wrap(err, "error marshaling job")
 		}
-		return nil
-	}); err != nil {
-		log.WithFields(log.Fields{
-			"jobAddress":   common.BytesToAddress(jobAddressBytes).Hex(),
-			"jobSignature": hex.EncodeToString(jobSignatureBytes),
-		}).WithError(err).Error("error marking job completed in db")
-	}
+		return errors.Wrap(bucket.Put(jobAddressBytes, jobBytes), "error putting job to db")
+	})
 
-	// Submit the job for completion
-	p.jobCompletionQueue <- &jobInfo{jobAddressBytes, jobSignatureBytes}
+	return job, err
 }
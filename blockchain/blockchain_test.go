@@ -0,0 +1,36 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FakeNet serves net_version for a fixed network
+type FakeNet struct {
+	networkID string
+}
+
+func (f *FakeNet) Version() string {
+	return f.networkID
+}
+
+func TestCheckNetworkID(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("net", &FakeNet{networkID: "3"}))
+
+	p := Processor{ethClient: ethclient.NewClient(rpc.DialInProc(server))}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	defer p.cancel()
+
+	assert.NoError(t, p.checkNetworkID(big.NewInt(3)))
+
+	err := p.checkNetworkID(big.NewInt(1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "network 3")
+}
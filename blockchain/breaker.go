@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+)
+
+// breakerState is the state of a circuitBreaker, as exported by the circuit breaker metric
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calls to a failing node. After threshold consecutive failures it opens for cooldown, during
+// which no calls are allowed, then half-opens to let a single call through: a success closes it again, and a failure
+// reopens it for another cooldown. It is safe for concurrent use, and a nil circuitBreaker never opens.
+type circuitBreaker struct {
+	threshold uint
+	cooldown  time.Duration
+
+	mutex    sync.Mutex
+	state    breakerState
+	failures uint
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold uint, cooldown time.Duration) *circuitBreaker {
+	circuitBreakerState.Set(float64(breakerClosed))
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may be made at now, half-opening an open breaker once its cooldown is over
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == breakerOpen {
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(breakerHalfOpen)
+	}
+	return true
+}
+
+func (b *circuitBreaker) succeed() {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	if b.state != breakerClosed {
+		b.transition(breakerClosed)
+	}
+}
+
+// fail records a failed call made at now, opening the breaker once the threshold is reached or the test call of a
+// half-open breaker fails
+func (b *circuitBreaker) fail(now time.Time) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		b.openedAt = now
+		b.transition(breakerOpen)
+	}
+}
+
+func (b *circuitBreaker) currentState() breakerState {
+	if b == nil {
+		return breakerClosed
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// transition moves the breaker to state; the caller holds the mutex
+func (b *circuitBreaker) transition(state breakerState) {
+	log.WithField("from", b.state).WithField("to", state).WithField("consecutiveFailures", b.failures).
+		Warn("eth RPC circuit breaker changed state")
+	b.state = state
+	circuitBreakerState.Set(float64(state))
+}
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.pollBackoff, p.status, p.errorListeners = &backoff{}, &processorStatus{}, &errorListeners{}
+	p.pollInterval = newPollInterval(time.Second, time.Second, time.Second)
+	p.logScanChunkSize = 100
+	p.breaker = newCircuitBreaker(3, 50*time.Millisecond)
+
+	node := &downNode{err: errors.New("dial tcp: connection refused")}
+	p.ethClient, p.rawClient = node, node
+
+	// Polls call the node until the threshold of consecutive failures opens the breaker
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, breakerClosed, p.breaker.currentState())
+		p.processEventsOnce(testEvents)
+	}
+	assert.Equal(t, breakerOpen, p.breaker.currentState())
+	calls := node.calls
+
+	// While open, polls make no calls at all
+	p.processEventsOnce(testEvents)
+	p.processEventsOnce(testEvents)
+	assert.Equal(t, calls, node.calls)
+
+	// After the cooldown a single test poll is let through, and its failure reopens the breaker
+	time.Sleep(60 * time.Millisecond)
+	p.processEventsOnce(testEvents)
+	assert.True(t, node.calls > calls)
+	assert.Equal(t, breakerOpen, p.breaker.currentState())
+	calls = node.calls
+	p.processEventsOnce(testEvents)
+	assert.Equal(t, calls, node.calls)
+
+	// Once the node recovers, the next test poll closes it
+	p.ethClient = &fakeEthClient{head: &types.Header{Number: big.NewInt(20), Difficulty: big.NewInt(1),
+		Time: big.NewInt(1), Extra: []byte{}}}
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, p.breaker.allow(time.Now()))
+	assert.Equal(t, breakerHalfOpen, p.breaker.currentState())
+	p.processEventsOnce(testEvents)
+	assert.Equal(t, breakerClosed, p.breaker.currentState())
+
+	// A nil breaker never opens
+	var disabled *circuitBreaker
+	disabled.fail(time.Now())
+	assert.True(t, disabled.allow(time.Now()))
+	assert.Equal(t, breakerClosed, disabled.currentState())
+}
@@ -0,0 +1,43 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// LogFilterer reads and subscribes to the agents' job event logs
+type LogFilterer interface {
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription,
+		error)
+}
+
+// EthereumClient is the Ethereum client the processor follows the chain and sends transactions through: job event logs,
+// the calls the contract bindings make, block headers, the signer's balance and the network id. *ethclient.Client
+// implements it.
+type EthereumClient interface {
+	LogFilterer
+	bind.ContractCaller
+	bind.ContractTransactor
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NetworkID(ctx context.Context) (*big.Int, error)
+}
+
+// RawCaller makes raw JSON-RPC calls, for responses EthereumClient can't decode; *rpc.Client implements it
+type RawCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// AgentCompleter is the part of an agent contract the processor calls: completing jobs, and validating invocations of
+// them on chain. The generated *Agent binding implements it.
+type AgentCompleter interface {
+	CompleteJob(opts *bind.TransactOpts, job common.Address, v uint8, r [32]byte, s [32]byte) (*types.Transaction,
+		error)
+	ValidateJobInvocation(opts *bind.CallOpts, job common.Address, v uint8, r [32]byte, s [32]byte) (bool, error)
+}
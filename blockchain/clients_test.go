@@ -0,0 +1,291 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/config"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEthClient is an EthereumClient serving a fixed chain head, job logs, balance and gas estimate. Methods it doesn't
+// override panic on the nil embedded client, so a test only needs to fake what the code under test calls.
+type fakeEthClient struct {
+	EthereumClient
+	head    *types.Header
+	logs    []types.Log
+	balance *big.Int
+	gas     uint64
+}
+
+func (f *fakeEthClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil || number.Cmp(f.head.Number) == 0 {
+		return f.head, nil
+	}
+	return &types.Header{Number: number, Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}}, nil
+}
+
+func (f *fakeEthClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	for _, jobLog := range f.logs {
+		if jobLog.BlockNumber >= query.FromBlock.Uint64() && jobLog.BlockNumber <= query.ToBlock.Uint64() {
+			logs = append(logs, jobLog)
+		}
+	}
+	return logs, nil
+}
+
+func (f *fakeEthClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0}, nil
+}
+
+func (f *fakeEthClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int,
+	error) {
+	return f.balance, nil
+}
+
+func (f *fakeEthClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return f.gas, nil
+}
+
+func (f *fakeEthClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return 7, nil
+}
+
+func (f *fakeEthClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+// fakeRawCaller is a RawCaller answering each method with a canned response
+type fakeRawCaller map[string]interface{}
+
+func (f fakeRawCaller) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	encoded, err := json.Marshal(f[method])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, result)
+}
+
+// fakeAgent is an AgentCompleter that records the jobs it was asked to complete. Calls to CompleteJob fail with errs
+// in turn before succeeding.
+type fakeAgent struct {
+	mutex     sync.Mutex
+	completed []common.Address
+	nonces    []uint64
+	errs      []error
+	calls     int
+}
+
+func (f *fakeAgent) CompleteJob(opts *bind.TransactOpts, job common.Address, v uint8, r [32]byte,
+	s [32]byte) (*types.Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+
+	f.completed = append(f.completed, job)
+	f.nonces = append(f.nonces, opts.Nonce.Uint64())
+	return types.NewTransaction(opts.Nonce.Uint64(), job, big.NewInt(0), opts.GasLimit, big.NewInt(1), nil), nil
+}
+
+func (f *fakeAgent) ValidateJobInvocation(opts *bind.CallOpts, job common.Address, v uint8, r [32]byte,
+	s [32]byte) (bool, error) {
+	return true, nil
+}
+
+func TestNewProcessorWithClients(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	agentAddress := common.HexToAddress("0xa9e7")
+	for key, value := range map[string]interface{}{config.BlockchainEnabledKey: true,
+		config.AgentContractAddressKey: agentAddress.Hex()} {
+		defer config.Vip().Set(key, config.Vip().Get(key))
+		config.Vip().Set(key, value)
+	}
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	ethClient := &fakeEthClient{
+		head: &types.Header{Number: big.NewInt(20), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}},
+		logs: []types.Log{{Address: agentAddress, Topics: []common.Hash{testEvents.jobCreatedID},
+			Data: append(word(jobAddress), word(consumer)...), BlockNumber: 20}},
+	}
+
+	p, err := NewProcessorWithClients(boltDB, ethClient, fakeRawCaller{})
+	require.NoError(t, err)
+	defer p.cancel()
+	require.Len(t, p.agents, 1)
+	assert.Equal(t, agentAddress, p.agents[0].address)
+
+	// Events are read through the injected client
+	require.True(t, p.pollEvents(p.events))
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+	assert.Equal(t, agentAddress.Bytes(), job.AgentAddress)
+}
+
+// undeployedEthClient reports no contract code at any address
+type undeployedEthClient struct {
+	*fakeEthClient
+}
+
+func (c undeployedEthClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte,
+	error) {
+	return nil, nil
+}
+
+func TestNewProcessorRejectsUndeployedAgent(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	agentAddress := common.HexToAddress("0xa9e7")
+	for key, value := range map[string]interface{}{config.BlockchainEnabledKey: true,
+		config.AgentContractAddressKey: agentAddress.Hex()} {
+		defer config.Vip().Set(key, config.Vip().Get(key))
+		config.Vip().Set(key, value)
+	}
+
+	_, err := NewProcessorWithClients(boltDB, undeployedEthClient{&fakeEthClient{}}, fakeRawCaller{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), agentAddress.Hex())
+}
+
+// newFakeAgentProcessor returns a processor completing jobs through agent, over clients that report every
+// transaction mined
+func newFakeAgentProcessor(agent *fakeAgent) Processor {
+	signer := &fakeSigner{address: common.HexToAddress("0x5e1f")}
+	ethClient := &fakeEthClient{balance: big.NewInt(1000000)}
+	rawClient := fakeRawCaller{
+		"eth_getBlockByNumber": map[string]interface{}{"number": "0x1"},
+		"eth_getTransactionReceipt": map[string]interface{}{
+			"status":            "0x1",
+			"cumulativeGasUsed": "0x5208",
+			"logsBloom":         types.Bloom{},
+			"logs":              []*types.Log{},
+			"transactionHash":   common.Hash{},
+			"gasUsed":           "0x5208",
+			"blockNumber":       "0x2",
+		},
+	}
+	return Processor{
+		ethClient:             ethClient,
+		rawClient:             rawClient,
+		agents:                []*agentContract{{address: common.HexToAddress("0xa9e7"), agent: agent}},
+		signer:                signer,
+		address:               signer.address.Hex(),
+		nonces:                newNonceTracker(ethClient, signer.address),
+		jobCompletionGasLimit: 100000,
+		confirmationTimeout:   5 * time.Second,
+	}
+}
+
+// testJobSignature is a well-formed job signature
+var testJobSignature = func() []byte {
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	return signature
+}()
+
+func TestSubmitJobCompletionFakeAgent(t *testing.T) {
+	agent := &fakeAgent{}
+	p := newFakeAgentProcessor(agent)
+
+	jobAddress := common.HexToAddress("0x1234")
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature}))
+
+	assert.Equal(t, []common.Address{jobAddress}, agent.completed)
+	assert.Equal(t, []uint64{7}, agent.nonces)
+}
+
+func TestSubmitJobCompletionRetriesTransientErrors(t *testing.T) {
+	agent := &fakeAgent{errs: []error{errors.New("Post http://node: dial tcp: connection refused"),
+		errors.New("503 Service Unavailable")}}
+	p := newFakeAgentProcessor(agent)
+	p.submitAttempts, p.submitRetryDelay = 3, time.Millisecond
+
+	jobAddress := common.HexToAddress("0x1234")
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature}))
+
+	// The third try went through, with the nonce of the first
+	assert.Equal(t, 3, agent.calls)
+	assert.Equal(t, []common.Address{jobAddress}, agent.completed)
+	assert.Equal(t, []uint64{7}, agent.nonces)
+
+	// Transient failures past the last try are returned
+	agent = &fakeAgent{errs: []error{errors.New("EOF"), errors.New("EOF"), errors.New("EOF")}}
+	p = newFakeAgentProcessor(agent)
+	p.submitAttempts, p.submitRetryDelay = 3, time.Millisecond
+	assert.Error(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature}))
+	assert.Equal(t, 3, agent.calls)
+}
+
+func TestSubmitJobCompletionNonceTooLow(t *testing.T) {
+	agent := &fakeAgent{errs: []error{errors.New("nonce too low")}}
+	p := newFakeAgentProcessor(agent)
+
+	// The tracker has drifted behind the node, whose pending nonce is 7
+	nonce, err := p.nonces.next(context.Background())
+	require.NoError(t, err)
+	p.nonces.release(nonce)
+	p.nonces.mutex.Lock()
+	p.nonces.nonce = 3
+	p.nonces.mutex.Unlock()
+
+	jobAddress := common.HexToAddress("0x1234")
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature}))
+
+	// The retry went out with the refreshed nonce, and the tracker carries on from it
+	assert.Equal(t, 2, agent.calls)
+	assert.Equal(t, []uint64{7}, agent.nonces)
+	nonce, err = p.nonces.next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), nonce)
+
+	// A second nonce too low isn't retried again
+	agent = &fakeAgent{errs: []error{errors.New("nonce too low"), errors.New("nonce too low")}}
+	p = newFakeAgentProcessor(agent)
+	assert.Error(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature}))
+	assert.Equal(t, 2, agent.calls)
+}
+
+func TestSubmitJobCompletionPermanentErrors(t *testing.T) {
+	for _, message := range []string{"execution reverted", "insufficient funds for gas * price + value"} {
+		t.Run(message, func(t *testing.T) {
+			agent := &fakeAgent{errs: []error{errors.New(message)}}
+			p := newFakeAgentProcessor(agent)
+			p.submitAttempts, p.submitRetryDelay = 3, time.Millisecond
+
+			err := p.submitJobCompletion(context.Background(), testAgentABI,
+				&jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: testJobSignature})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), message)
+			assert.Equal(t, 1, agent.calls)
+			assert.Empty(t, agent.completed)
+		})
+	}
+}
@@ -0,0 +1,703 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/sirupsen/logrus"
+)
+
+// submitJobCompletion sends the CompleteJob transaction for a single job and waits for it to be mined. While waiting,
+// a transaction that sits in the mempool longer than the resubmit interval is replaced with a copy that reuses its
+// nonce at a higher gas price, up to the configured number of attempts.
+//
+// An error is returned only when no transaction could be sent or the one mined reverted, so the completion can be
+// safely retried; a transaction that was sent but not mined in time is left with errCompletionUnconfirmed for the old
+// job scan to wait on instead. Cancelling ctx, as the processor does when stopping, aborts the submission, or the wait
+// for it to be mined with errSubmissionCancelled.
+func (p Processor) submitJobCompletion(ctx context.Context, a abi.ABI, jobInfo *jobInfo) error {
+	sent, err := p.sendJobCompletion(ctx, a, jobInfo)
+	if err != nil || sent == nil {
+		return err
+	}
+	return p.confirmJobCompletion(ctx, sent)
+}
+
+// sentCompletion is a CompleteJob transaction that has been sent and is waiting to be mined, along with what is needed
+// to replace it
+type sentCompletion struct {
+	jobInfo     *jobInfo
+	agent       *agentContract
+	jobAddress  common.Address
+	v           uint8
+	r, s        [32]byte
+	opts        *bind.TransactOpts
+	txHash      common.Hash
+	submittedAt time.Time
+	log         *logrus.Entry
+}
+
+// sendJobCompletion sends the CompleteJob transaction for a single job, the first half of submitJobCompletion. It
+// returns nil without an error if nothing was sent for a reason that needs no retry, e.g. a dry run.
+func (p Processor) sendJobCompletion(ctx context.Context, a abi.ABI, jobInfo *jobInfo) (*sentCompletion, error) {
+	jobAddress := common.BytesToAddress(jobInfo.jobAddressBytes)
+	agent, err := p.agentFor(jobInfo.agentAddressBytes)
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error finding agent contract to complete job")
+	}
+	log := log.WithFields(logrus.Fields{"jobAddress": jobAddress.Hex(),
+		"jobSignature": hex.EncodeToString(jobInfo.jobSignatureBytes),
+		"agentAddress": agent.address.Hex()})
+
+	v, r, s, err := parseSignature(jobInfo.jobSignatureBytes)
+
+	// A transaction with a zero-valued signature can only fail, so don't spend gas on it
+	if err != nil {
+		completionFailures.Inc()
+		return nil, permanentError{errors.Wrap(err, "error parsing job signature")}
+	}
+	sent := &sentCompletion{jobInfo: jobInfo, agent: agent, jobAddress: jobAddress, v: v, r: r, s: s, log: log}
+
+	// A transaction sent earlier, e.g. before a confirmation timeout or a restart, is waited on again rather than
+	// followed by one under a fresh nonce that could complete the job twice
+	resumed, err := p.resumeJobCompletion(ctx, sent)
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error checking transaction already sent to complete job")
+	}
+	if resumed {
+		return sent, nil
+	}
+
+	gasLimit, err := p.completeJobGasLimit(ctx, a, agent.address, jobAddress, v, r, s)
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error estimating gas to complete job")
+	}
+
+	// A nil gas price leaves the choice to go-ethereum, as before
+	gasPrice, err := p.completeJobGasPrice(ctx)
+	if err != nil {
+		log.WithError(err).Warn("error suggesting gas price; falling back to default")
+	}
+
+	// The job is left marked completed in the db, so the rest of the pipeline carries on as if the transaction was mined
+	if p.dryRun {
+		log.WithField("v", v).
+			WithField("r", hex.EncodeToString(r[:])).
+			WithField("s", hex.EncodeToString(s[:])).
+			WithField("gasLimit", gasLimit).
+			WithField("gasPrice", gasPrice).
+			Info("dry run; not submitting transaction to complete job")
+		p.recordCompletionDryRun(jobInfo.jobAddressBytes)
+		jobInfo.report(common.Hash{}, nil)
+		return nil, nil
+	}
+
+	// A relayed completion is paid for by the relayer, from its own account and nonces
+	if p.relayer != nil {
+		sent.opts = &bind.TransactOpts{}
+		return p.relayJobCompletion(ctx, a, sent, gasLimit)
+	}
+
+	if err := p.checkBalance(ctx, gasLimit, gasPrice); err != nil {
+		completionFailures.Inc()
+		return nil, err
+	}
+
+	nonce, err := p.nonces.next(ctx)
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error determining nonce to complete job")
+	}
+
+	opts := &bind.TransactOpts{
+		From:     common.HexToAddress(p.address),
+		Nonce:    new(big.Int).SetUint64(nonce),
+		Signer:   signerFn(p.signer),
+		GasLimit: gasLimit,
+		GasPrice: gasPrice,
+		Context:  ctx,
+	}
+	sent.opts = opts
+
+	log.WithField("nonce", nonce).WithField("gasLimit", gasLimit).WithField("gasPrice", gasPrice).
+		Debug("submitting transaction to complete job")
+	txn, signed, err := p.sendCompleteJob(ctx, sent)
+
+	// The nonce may have been used by an earlier try at this very transaction that reached the node before failing,
+	// which must not be followed by another; otherwise the tracked nonce has fallen behind the node's, e.g. because
+	// another transaction was sent from the same key, so resync and try once more with the node's pending nonce
+	if err != nil && isNonceTooLowError(err) {
+		landed, lookupErr := p.knownTransaction(ctx, signed)
+		switch {
+		case lookupErr != nil:
+			err = errors.Wrap(lookupErr, "error checking for an earlier try at the transaction")
+		case landed != nil:
+			log.WithField("txHash", landed.Hash().Hex()).
+				Info("nonce too low but an earlier try reached the node; treating transaction as sent")
+			txn, err = landed, nil
+		default:
+			log.WithError(err).WithField("nonce", nonce).Warn("nonce too low; refreshing nonce from node and retrying")
+			p.nonces.release(nonce)
+			p.nonces.reset()
+			if nonce, err = p.nonces.next(ctx); err != nil {
+				completionFailures.Inc()
+				return nil, errors.Wrap(err, "error refreshing nonce to complete job")
+			}
+			opts.Nonce = new(big.Int).SetUint64(nonce)
+			txn, _, err = p.sendCompleteJob(ctx, sent)
+		}
+	}
+	p.nonces.release(nonce)
+	if err != nil {
+		// The nonce was not consumed; resync with the node before the next submission
+		p.nonces.reset()
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error submitting transaction to complete job")
+	}
+	completionTransactions.Inc()
+	sent.txHash, sent.submittedAt = txn.Hash(), time.Now()
+	log.WithField("txHash", txn.Hash().Hex()).WithField("nonce", nonce).Info("submitted transaction to complete job")
+	p.recordCompletionTx(txn.Hash(), jobInfo.jobAddressBytes)
+	jobInfo.report(txn.Hash(), nil)
+
+	return sent, nil
+}
+
+// sentTransaction is a transaction as the node reports it; blockNumber is nil while it is pending
+type sentTransaction struct {
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Gas         hexutil.Uint64  `json:"gas"`
+	GasPrice    *hexutil.Big    `json:"gasPrice"`
+	To          *common.Address `json:"to"`
+	BlockNumber *hexutil.Big    `json:"blockNumber"`
+}
+
+// transactionByHash returns the transaction with the given hash, or nil if the node doesn't know it, e.g. because it
+// was dropped from the mempool
+func (p Processor) transactionByHash(ctx context.Context, txHash common.Hash) (*sentTransaction, error) {
+	var tx *sentTransaction
+	if err := p.rawClient.CallContext(ctx, &tx, "eth_getTransactionByHash", txHash); err != nil {
+		return nil, errors.Wrap(err, "error retrieving transaction")
+	}
+	return tx, nil
+}
+
+// resumeJobCompletion looks up the completion transaction last recorded for the job of sent, reporting whether it is
+// to be waited on instead of sending a new one. A transaction the node still has pending, or mined successfully, is
+// adopted by sent along with the nonce it was signed with; one the daemon sent to the agent directly can also be
+// replaced at that nonce while it is stuck. A job without a transaction, or whose transaction reverted or is no longer
+// known to the node, needs a new one.
+func (p Processor) resumeJobCompletion(ctx context.Context, sent *sentCompletion) (bool, error) {
+	if p.boltDB == nil {
+		return false, nil
+	}
+	job, err := db.GetJob(p.boltDB, sent.jobInfo.jobAddressBytes)
+	if err != nil || job == nil || job.CompletionTxHash == nil {
+		return false, err
+	}
+
+	txHash := common.BytesToHash(job.CompletionTxHash)
+	tx, err := p.transactionByHash(ctx, txHash)
+	if err != nil || tx == nil {
+		return false, err
+	}
+	if tx.BlockNumber != nil {
+		receipt, err := p.transactionReceipt(ctx, txHash)
+		if err != nil {
+			return false, err
+		}
+		// A reverted completion left the job open, and used up its nonce
+		if receipt != nil && receipt.Status != types.ReceiptStatusSuccessful {
+			return false, nil
+		}
+	}
+
+	sent.opts = &bind.TransactOpts{
+		From:    common.HexToAddress(p.address),
+		Nonce:   new(big.Int).SetUint64(uint64(tx.Nonce)),
+		Signer:  signerFn(p.signer),
+		Context: ctx,
+	}
+	// A batch or relayed transaction can't be replaced by a single completion; without a gas price it isn't
+	if p.relayer == nil && tx.To != nil && *tx.To == sent.agent.address {
+		sent.opts.GasLimit, sent.opts.GasPrice = uint64(tx.Gas), (*big.Int)(tx.GasPrice)
+	}
+	sent.txHash, sent.submittedAt = txHash, time.Now()
+	sent.log.WithField("txHash", txHash.Hex()).WithField("nonce", uint64(tx.Nonce)).
+		Info("transaction already sent to complete job still known to node; waiting on it")
+	sent.jobInfo.report(txHash, nil)
+	return true, nil
+}
+
+// completionSent reports whether a completion transaction is recorded for the job
+func (p Processor) completionSent(jobAddressBytes []byte) bool {
+	if p.boltDB == nil {
+		return false
+	}
+	job, err := db.GetJob(p.boltDB, jobAddressBytes)
+	return err == nil && job != nil && job.CompletionTxHash != nil
+}
+
+// sendCompleteJob sends the CompleteJob transaction of sent, signed with its opts, through sendWithRetry; the original
+// and its replacements alike go out this way, under the nonce pinned in opts. The transaction last signed is kept as
+// the one sent when the node reports it already known. Every transaction signed on the way is returned too, since a
+// try that failed, e.g. by timing out, may still have reached the node.
+func (p Processor) sendCompleteJob(ctx context.Context, sent *sentCompletion) (*types.Transaction, []*types.Transaction,
+	error) {
+	opts := *sent.opts
+	var signed []*types.Transaction
+	sign := sent.opts.Signer
+	opts.Signer = func(s types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		tx, err := sign(s, address, tx)
+		if err == nil {
+			signed = append(signed, tx)
+		}
+		return tx, err
+	}
+
+	var txn *types.Transaction
+	err := p.sendWithRetry(ctx, func(ctx context.Context) (err error) {
+		opts.Context = ctx
+		txn, err = sent.agent.agent.CompleteJob(&opts, sent.jobAddress, sent.v, sent.r, sent.s)
+		return err
+	})
+	if err != nil {
+		return nil, signed, err
+	}
+	if txn == nil && len(signed) == 0 {
+		return nil, nil, errors.New("node reported transaction to complete job already known before it was signed")
+	}
+	if txn == nil {
+		txn = signed[len(signed)-1]
+	}
+	return txn, signed, nil
+}
+
+// knownTransaction returns the first of txns the node knows, pending or mined, or nil if it knows none of them
+func (p Processor) knownTransaction(ctx context.Context, txns []*types.Transaction) (*types.Transaction, error) {
+	for _, txn := range txns {
+		tx, err := p.transactionByHash(ctx, txn.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if tx != nil {
+			return txn, nil
+		}
+	}
+	return nil, nil
+}
+
+// confirmJobCompletion waits for a sent CompleteJob transaction to be mined, replacing it at a higher gas price while
+// it is stuck, the second half of submitJobCompletion
+func (p Processor) confirmJobCompletion(ctx context.Context, sent *sentCompletion) error {
+	jobInfo, opts, log := sent.jobInfo, sent.opts, sent.log
+
+	// Bound the wait so a transaction that never gets mined doesn't block the rest of the queue; the job stays marked
+	// completed in the db, and in the outbox, for the old job scan to wait on the transaction again
+	waitCtx, cancel := context.WithTimeout(ctx, p.confirmationTimeout)
+	defer cancel()
+
+	// Every transaction sent for this nonce is watched, since the original may still be mined after a replacement
+	txHashes := []common.Hash{sent.txHash}
+
+	for attempts := 0; ; attempts++ {
+		attemptCtx, attemptCancel := waitCtx, context.CancelFunc(func() {})
+		canResubmit := opts.GasPrice != nil && p.resubmitInterval > 0 && attempts < p.maxResubmits
+		if canResubmit {
+			attemptCtx, attemptCancel = context.WithTimeout(waitCtx, p.resubmitInterval)
+		}
+
+		receipt, err := p.waitMined(attemptCtx, txHashes)
+		attemptCancel()
+
+		if err == nil {
+			confirmationLatency := time.Since(sent.submittedAt)
+			completionConfirmationLatency.Observe(confirmationLatency.Seconds())
+			logReceipt(log.WithField("confirmationLatency", confirmationLatency), receipt)
+
+			// The job is still open on chain, so have it retried like a completion that failed to send
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				completionFailures.Inc()
+				return errors.Errorf("transaction %v to complete job reverted", receipt.TxHash.Hex())
+			}
+			p.recordCompletionMined(receipt.blockNumber, jobInfo.jobAddressBytes)
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			log.WithField("txHash", txHashes[len(txHashes)-1].Hex()).
+				Warn("submission cancelled; no longer waiting for transaction to complete job")
+			return errSubmissionCancelled
+		}
+
+		if waitCtx.Err() != nil || !canResubmit {
+			log.WithError(err).WithField("txHash", txHashes[len(txHashes)-1].Hex()).
+				Warn("transaction to complete job not mined before timeout; leaving it to the old job scan")
+			return errCompletionUnconfirmed
+		}
+
+		bumpedGasPrice := bumpGasPrice(opts.GasPrice, p.gasPriceBump, p.maxGasPrice)
+		if bumpedGasPrice.Cmp(opts.GasPrice) <= 0 {
+			// Already at the cap; keep waiting on what has been sent without further resubmission
+			attempts = p.maxResubmits
+			continue
+		}
+		opts.GasPrice = bumpedGasPrice
+
+		// The replacement reuses the nonce pinned in opts rather than drawing a new one, so it can only take the
+		// original's place
+		log.WithField("nonce", opts.Nonce).WithField("gasPrice", opts.GasPrice).WithField("attempt", attempts+1).
+			Info("job completion transaction stuck; resubmitting with higher gas price")
+		if replacement, _, err := p.sendCompleteJob(ctx, sent); err != nil {
+			log.WithError(err).Warn("error resubmitting transaction to complete job")
+		} else {
+			txHashes = append(txHashes, replacement.Hash())
+			completionTransactions.Inc()
+			p.recordCompletionTx(replacement.Hash(), jobInfo.jobAddressBytes)
+			log.WithField("txHash", replacement.Hash().Hex()).Info("submitted replacement transaction to complete job")
+		}
+	}
+}
+
+// nonceTooLowErrors are fragments of the errors nodes return for a transaction whose nonce the account has already
+// used
+var nonceTooLowErrors = []string{
+	"nonce too low",
+	"nonce is too low",
+	"oldnonce",
+}
+
+// isNonceTooLowError reports whether sending a transaction failed because its nonce was already used
+func isNonceTooLowError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, fragment := range nonceTooLowErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCompletionTx stores txHash as the completion transaction of each of the jobs, and in their histories, so
+// operators can tell which transaction completed a job. Jobs no longer in the db, e.g. because their JobCompleted event
+// was already handled, are left deleted. The transaction has been sent whatever happens here, so a failure is only
+// logged, and without a db there is nothing to record.
+func (p Processor) recordCompletionTx(txHash common.Hash, jobAddresses ...[]byte) {
+	if p.boltDB == nil {
+		return
+	}
+
+	err := p.boltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.JobBucketName)
+		for _, jobAddressBytes := range jobAddresses {
+			jobBytes := bucket.Get(jobAddressBytes)
+			if jobBytes == nil {
+				continue
+			}
+
+			job := &db.Job{}
+			if err := json.Unmarshal(jobBytes, job); err != nil {
+				return errors.Wrap(err, "error unmarshaling job")
+			}
+			job.CompletionTxHash = txHash.Bytes()
+			job.Touch(time.Now())
+			// Sending the transaction doesn't change the job's state; its JobCompleted event does
+			if err := db.AppendJobHistory(tx, jobAddressBytes, db.HistoryEntry{Timestamp: job.UpdatedAt,
+				FromState: job.JobState, ToState: job.JobState, TxHash: txHash.Bytes()}); err != nil {
+				return err
+			}
+
+			jobBytes, err := json.Marshal(job)
+			if err != nil {
+				return errors.Wrap(err, "error marshaling job")
+			}
+			if err := bucket.Put(jobAddressBytes, jobBytes); err != nil {
+				return errors.Wrap(err, "error putting job to db")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).WithField("txHash", txHash.Hex()).Error("error recording job completion transaction")
+	}
+}
+
+// recordCompletionMined stores block as the block a successful completion transaction of each of the jobs was mined
+// in, so the old job scan doesn't resend their completions while their JobCompleted events are yet to be seen, e.g.
+// under BLOCK_CONFIRMATIONS. As with recordCompletionTx, jobs no longer in the db are left deleted and a failure is
+// only logged.
+func (p Processor) recordCompletionMined(block *big.Int, jobAddresses ...[]byte) {
+	if p.boltDB == nil || block == nil {
+		return
+	}
+
+	minedAtBlock := block.Uint64()
+	err := p.boltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.JobBucketName)
+		for _, jobAddressBytes := range jobAddresses {
+			jobBytes := bucket.Get(jobAddressBytes)
+			if jobBytes == nil {
+				continue
+			}
+
+			job := &db.Job{}
+			if err := json.Unmarshal(jobBytes, job); err != nil {
+				return errors.Wrap(err, "error unmarshaling job")
+			}
+			job.CompletionMinedAtBlock = &minedAtBlock
+			job.Touch(time.Now())
+
+			jobBytes, err := json.Marshal(job)
+			if err != nil {
+				return errors.Wrap(err, "error marshaling job")
+			}
+			if err := bucket.Put(jobAddressBytes, jobBytes); err != nil {
+				return errors.Wrap(err, "error putting job to db")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).WithField("block", block).Error("error recording mined job completion transaction")
+	}
+}
+
+// recordCompletionDryRun marks the jobs as completed by a dry run, so the old job scan doesn't log the same completions
+// again on every pass. As with recordCompletionTx, jobs no longer in the db are left deleted and a failure is only
+// logged.
+func (p Processor) recordCompletionDryRun(jobAddresses ...[]byte) {
+	if p.boltDB == nil {
+		return
+	}
+
+	err := p.boltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.JobBucketName)
+		for _, jobAddressBytes := range jobAddresses {
+			jobBytes := bucket.Get(jobAddressBytes)
+			if jobBytes == nil {
+				continue
+			}
+
+			job := &db.Job{}
+			if err := json.Unmarshal(jobBytes, job); err != nil {
+				return errors.Wrap(err, "error unmarshaling job")
+			}
+			job.DryRun = true
+			job.Touch(time.Now())
+
+			jobBytes, err := json.Marshal(job)
+			if err != nil {
+				return errors.Wrap(err, "error marshaling job")
+			}
+			if err := bucket.Put(jobAddressBytes, jobBytes); err != nil {
+				return errors.Wrap(err, "error putting job to db")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Error("error recording dry run job completion")
+	}
+}
+
+// minedReceipt is a transaction receipt along with the block it was mined in, which the receipt type of the pinned
+// go-ethereum doesn't decode
+type minedReceipt struct {
+	*types.Receipt
+	blockNumber *big.Int
+}
+
+// logReceipt logs the outcome of a mined job completion transaction and counts it by status
+func logReceipt(entry *logrus.Entry, receipt *minedReceipt) {
+	txStatus := "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		txStatus = "reverted"
+	}
+	completionReceipts.WithLabelValues(txStatus).Inc()
+
+	entry = entry.WithFields(logrus.Fields{
+		"txHash":      receipt.TxHash.Hex(),
+		"txStatus":    txStatus,
+		"gasUsed":     receipt.GasUsed,
+		"blockNumber": receipt.blockNumber,
+	})
+	if txStatus == "success" {
+		entry.Info("transaction to complete job mined")
+	} else {
+		entry.Error("transaction to complete job reverted")
+	}
+}
+
+// waitMined polls for a receipt of any of the given transactions until one is found or the context is done
+func (p Processor) waitMined(ctx context.Context, txHashes []common.Hash) (*minedReceipt, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, txHash := range txHashes {
+			if receipt, err := p.transactionReceipt(ctx, txHash); receipt != nil && err == nil {
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// transactionReceipt returns the receipt of a mined transaction, or nil if it hasn't been mined yet
+func (p Processor) transactionReceipt(ctx context.Context, txHash common.Hash) (*minedReceipt, error) {
+	var raw json.RawMessage
+	if err := p.rawClient.CallContext(ctx, &raw, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	receipt := &types.Receipt{}
+	if err := json.Unmarshal(raw, receipt); err != nil {
+		return nil, errors.Wrap(err, "error decoding receipt")
+	}
+
+	var block struct {
+		BlockNumber *hexutil.Big `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, errors.Wrap(err, "error decoding receipt block number")
+	}
+
+	return &minedReceipt{Receipt: receipt, blockNumber: (*big.Int)(block.BlockNumber)}, nil
+}
+
+// completeJobGasLimit returns the gas limit to use for a CompleteJob transaction. If a static limit is configured it
+// is used as-is; otherwise the gas is estimated against the packed completeJob call and padded by the configured
+// buffer percentage.
+func (p Processor) completeJobGasLimit(ctx context.Context, a abi.ABI, agentAddress, jobAddress common.Address,
+	v uint8, r, s [32]byte) (uint64, error) {
+	if p.jobCompletionGasLimit != 0 {
+		return p.jobCompletionGasLimit, nil
+	}
+
+	input, err := a.Pack("completeJob", jobAddress, v, r, s)
+	if err != nil {
+		return 0, errors.Wrap(err, "error packing completeJob call")
+	}
+
+	return p.estimateGasLimit(ctx, agentAddress, input)
+}
+
+// estimateGasLimit estimates the gas for a transaction calling to with input, padded by the configured buffer
+// percentage. With a gas limit cap the padding stops at the cap, and an estimate already over it is refused rather
+// than sent with a limit it would run out of.
+func (p Processor) estimateGasLimit(ctx context.Context, to common.Address, input []byte) (uint64, error) {
+	gas, err := p.ethClient.EstimateGas(ctx, ethereum.CallMsg{
+		From: common.HexToAddress(p.address),
+		To:   &to,
+		Data: input,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "error estimating gas")
+	}
+
+	if p.gasLimitCap != 0 && gas > p.gasLimitCap {
+		return 0, errors.Errorf("estimated gas %v exceeds GAS_LIMIT_CAP of %v", gas, p.gasLimitCap)
+	}
+
+	gasLimit := gas + gas*p.jobCompletionGasBuffer/100
+	if p.gasLimitCap != 0 && gasLimit > p.gasLimitCap {
+		gasLimit = p.gasLimitCap
+	}
+	return gasLimit, nil
+}
+
+// checkBalance returns an error, and raises the low balance gauge, if the signing account can't pay for a
+// transaction using all of gasLimit at gasPrice, so it isn't sent only to be rejected for insufficient funds. A nil
+// gas price is left to go-ethereum to choose, so there is nothing to check against. The balance is also checked
+// against the low balance alert threshold, if any.
+func (p Processor) checkBalance(ctx context.Context, gasLimit uint64, gasPrice *big.Int) error {
+	if gasPrice == nil && p.balanceAlert == nil {
+		return nil
+	}
+
+	account := common.HexToAddress(p.address)
+	balance, err := p.ethClient.BalanceAt(ctx, account, nil)
+	if err != nil {
+		return errors.Wrap(err, "error retrieving signing account balance")
+	}
+
+	p.balanceAlert.check(account, balance)
+	if gasPrice == nil {
+		return nil
+	}
+
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice)
+	if balance.Cmp(cost) < 0 {
+		lowBalance.Set(1)
+		log.WithField("account", account.Hex()).
+			WithField("balance", balance).
+			WithField("cost", cost).
+			Error("signing account balance too low to pay for job completion; fund the account to resume completions")
+		return errors.Errorf("balance %v of %v is below the %v wei the transaction may cost", balance, account.Hex(),
+			cost)
+	}
+
+	lowBalance.Set(0)
+	return nil
+}
+
+// completeJobGasPrice returns the gas price for a CompleteJob transaction. On EIP-1559 chains this is derived from the
+// latest base fee plus the configured tip; otherwise it is the node's suggested gas price scaled by the configured
+// multiplier.
+//
+// The pinned go-ethereum can only build legacy transactions, which 1559 chains accept with the gas price serving as
+// both fee cap and tip, so dynamic fees are approximated rather than sent as GasFeeCap/GasTipCap.
+func (p Processor) completeJobGasPrice(ctx context.Context) (*big.Int, error) {
+	baseFee, err := p.latestBaseFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if baseFee != nil {
+		return dynamicFeeGasPrice(baseFee, p.gasTipCap, p.gasFeeCap), nil
+	}
+
+	suggested, err := p.ethClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return scaleGasPrice(suggested, p.settings.gasMultiplier()), nil
+}
+
+// latestBaseFee returns the base fee of the latest block, or nil if the chain doesn't support EIP-1559. The header
+// type of the pinned go-ethereum has no base fee, so the block is fetched raw.
+func (p Processor) latestBaseFee(ctx context.Context) (*big.Int, error) {
+	var header struct {
+		BaseFee *hexutil.Big `json:"baseFeePerGas"`
+	}
+
+	if err := p.rawClient.CallContext(ctx, &header, "eth_getBlockByNumber", "latest", false); err != nil {
+		return nil, errors.Wrap(err, "error retrieving latest block")
+	}
+
+	return (*big.Int)(header.BaseFee), nil
+}
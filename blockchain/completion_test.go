@@ -0,0 +1,887 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitJobCompletionMalformedSignature(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+
+	// Without clients, getting anywhere near submitting a transaction would panic
+	p := Processor{agents: []*agentContract{{}}}
+	jobInfo := &jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: []byte{1, 2, 3}}
+
+	assert.False(t, recoverPanic("test", func() { p.submitJobCompletion(context.Background(), a, jobInfo) }))
+}
+
+// GasNode estimates every call at a fixed amount of gas
+type GasNode struct {
+	gas uint64
+}
+
+func (n *GasNode) EstimateGas(call map[string]interface{}) hexutil.Uint64 {
+	return hexutil.Uint64(n.gas)
+}
+
+func TestCompleteJobGasLimit(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+	var r, s [32]byte
+	jobAddress, agentAddress := common.HexToAddress("0x1234"), common.HexToAddress("0xa9e7")
+
+	// A configured limit is used as-is, without asking the node for an estimate
+	p := Processor{jobCompletionGasLimit: 250000}
+	gasLimit, err := p.completeJobGasLimit(context.Background(), a, agentAddress, jobAddress, 27, r, s)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(250000), gasLimit)
+
+	// Without one the gas is estimated and padded by the buffer
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &GasNode{gas: 50000}))
+	p = Processor{ethClient: ethclient.NewClient(rpc.DialInProc(server)), jobCompletionGasBuffer: 20}
+	gasLimit, err = p.completeJobGasLimit(context.Background(), a, agentAddress, jobAddress, 27, r, s)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(60000), gasLimit)
+}
+
+// StuckNode accepts every transaction sent to it but never mines one
+type StuckNode struct {
+	mutex sync.Mutex
+	sent  []common.Hash
+}
+
+func (n *StuckNode) GetBalance(address common.Address, block string) *hexutil.Big {
+	return (*hexutil.Big)(new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func (n *StuckNode) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1))
+}
+
+func (n *StuckNode) GetBlockByNumber(number string, full bool) map[string]interface{} {
+	return map[string]interface{}{"number": "0x1"}
+}
+
+func (n *StuckNode) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return 0
+}
+
+func (n *StuckNode) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	tx := &types.Transaction{}
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.sent = append(n.sent, tx.Hash())
+	return tx.Hash(), nil
+}
+
+func (n *StuckNode) GetTransactionReceipt(txHash common.Hash) map[string]interface{} {
+	return nil
+}
+
+func TestProcessJobCompletionsNotMined(t *testing.T) {
+	node := &StuckNode{}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", node))
+	client := rpc.DialInProc(server)
+	ethClient := ethclient.NewClient(client)
+
+	agentAddress := common.HexToAddress("0xa9e7")
+	agent, err := NewAgent(agentAddress, ethClient)
+	require.NoError(t, err)
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	p := Processor{
+		rawClient:             client,
+		ethClient:             ethClient,
+		agents:                []*agentContract{{address: agentAddress, agent: agent}},
+		signer:                &keySigner{privateKey},
+		address:               address.Hex(),
+		nonces:                newNonceTracker(ethClient, address),
+		jobCompletionQueue:    make(chan *jobInfo, 2),
+		jobCompletionGasLimit: 100000,
+		confirmationTimeout:   200 * time.Millisecond,
+		ctx:                   context.Background(),
+	}
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	p.jobCompletionQueue <- &jobInfo{jobAddressBytes: common.HexToAddress("0x01").Bytes(), jobSignatureBytes: signature}
+	p.jobCompletionQueue <- &jobInfo{jobAddressBytes: common.HexToAddress("0x02").Bytes(), jobSignatureBytes: signature}
+	close(p.jobCompletionQueue)
+
+	// Each transaction never mined is left unconfirmed once the wait times out, so the next job isn't starved
+	done := make(chan struct{})
+	go func() {
+		p.processJobCompletions()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job completions blocked on a transaction that is never mined")
+	}
+
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	assert.Len(t, node.sent, 2)
+}
+
+// FakeEth serves the eth_ methods used to price transactions
+type FakeEth struct {
+	baseFee  *big.Int
+	gasPrice *big.Int
+}
+
+func (f *FakeEth) GetBlockByNumber(number string, full bool) (map[string]interface{}, error) {
+	block := map[string]interface{}{"number": "0x1"}
+	if f.baseFee != nil {
+		block["baseFeePerGas"] = (*hexutil.Big)(f.baseFee)
+	}
+	return block, nil
+}
+
+func (f *FakeEth) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(f.gasPrice)
+}
+
+func TestCompleteJobGasPrice(t *testing.T) {
+	eth := &FakeEth{gasPrice: big.NewInt(20)}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", eth))
+	client := rpc.DialInProc(server)
+
+	p := Processor{rawClient: client, ethClient: ethclient.NewClient(client),
+		settings: &liveSettings{gasPriceMultiplier: 1.5}, gasTipCap: big.NewInt(2)}
+
+	// Legacy chain: suggested price scaled by the multiplier
+	gasPrice, err := p.completeJobGasPrice(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(30), gasPrice)
+
+	// EIP-1559 chain: base fee with headroom plus tip
+	eth.baseFee = big.NewInt(800)
+	gasPrice, err = p.completeJobGasPrice(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(902), gasPrice)
+
+	p.gasFeeCap = big.NewInt(850)
+	gasPrice, err = p.completeJobGasPrice(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(850), gasPrice)
+}
+
+// FakeReceipts serves eth_getTransactionReceipt for a single mined transaction
+type FakeReceipts struct {
+	txHash common.Hash
+}
+
+func (f *FakeReceipts) GetTransactionReceipt(txHash common.Hash) map[string]interface{} {
+	if txHash != f.txHash {
+		return nil
+	}
+	return map[string]interface{}{
+		"status":            "0x0",
+		"cumulativeGasUsed": "0x5208",
+		"logsBloom":         types.Bloom{},
+		"logs":              []*types.Log{},
+		"transactionHash":   txHash,
+		"gasUsed":           "0x5208",
+		"blockNumber":       "0x2a",
+	}
+}
+
+func TestTransactionReceipt(t *testing.T) {
+	txHash := common.HexToHash("0x1234")
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &FakeReceipts{txHash: txHash}))
+	p := Processor{rawClient: rpc.DialInProc(server)}
+
+	receipt, err := p.transactionReceipt(context.Background(), txHash)
+	require.NoError(t, err)
+	require.NotNil(t, receipt)
+	assert.Equal(t, types.ReceiptStatusFailed, receipt.Status)
+	assert.Equal(t, uint64(21000), receipt.GasUsed)
+	assert.Equal(t, big.NewInt(42), receipt.blockNumber)
+
+	// Not mined yet
+	receipt, err = p.transactionReceipt(context.Background(), common.HexToHash("0x5678"))
+	require.NoError(t, err)
+	assert.Nil(t, receipt)
+}
+
+// FakeNode serves the eth_ methods used to send a transaction and wait for it to be mined. Every transaction sent is
+// mined immediately with receiptStatus.
+type FakeNode struct {
+	mutex         sync.Mutex
+	receiptStatus uint64
+	// revertTo, if set, makes transactions sent to that address revert regardless of receiptStatus
+	revertTo *common.Address
+	// unmined leaves every transaction pending
+	unmined bool
+	// dropped makes the node forget the transactions left pending, as if evicted from its mempool
+	dropped bool
+	// balance is the signing account's balance; nil is enough for any transaction
+	balance *big.Int
+	// gasPrice is the suggested gas price; nil suggests 1 wei
+	gasPrice *big.Int
+	// sendErrs are returned by the next sends in turn, each after the node has accepted the transaction
+	sendErrs []error
+	sent     []*types.Transaction
+}
+
+func (f *FakeNode) GetBalance(address common.Address, block string) *hexutil.Big {
+	if f.balance == nil {
+		return (*hexutil.Big)(new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return (*hexutil.Big)(f.balance)
+}
+
+func (f *FakeNode) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return 0
+}
+
+func (f *FakeNode) GetBlockByNumber(number string, full bool) map[string]interface{} {
+	return map[string]interface{}{"number": "0x1"}
+}
+
+func (f *FakeNode) GasPrice() *hexutil.Big {
+	if f.gasPrice == nil {
+		return (*hexutil.Big)(big.NewInt(1))
+	}
+	return (*hexutil.Big)(f.gasPrice)
+}
+
+func (f *FakeNode) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	tx := &types.Transaction{}
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	known := false
+	for _, sent := range f.sent {
+		known = known || sent.Hash() == tx.Hash()
+	}
+	if !known {
+		f.sent = append(f.sent, tx)
+	}
+	if len(f.sendErrs) > 0 {
+		err := f.sendErrs[0]
+		f.sendErrs = f.sendErrs[1:]
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+func (f *FakeNode) GetTransactionReceipt(txHash common.Hash) map[string]interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, tx := range f.sent {
+		if tx.Hash() == txHash && !f.unmined {
+			status := f.receiptStatus
+			if f.revertTo != nil && tx.To() != nil && *tx.To() == *f.revertTo {
+				status = types.ReceiptStatusFailed
+			}
+			return map[string]interface{}{
+				"status":            hexutil.Uint64(status),
+				"cumulativeGasUsed": "0x5208",
+				"logsBloom":         types.Bloom{},
+				"logs":              []*types.Log{},
+				"transactionHash":   txHash,
+				"gasUsed":           "0x5208",
+				"blockNumber":       "0x2",
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FakeNode) GetTransactionByHash(txHash common.Hash) map[string]interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, tx := range f.sent {
+		if tx.Hash() == txHash && !(f.unmined && f.dropped) {
+			transaction := map[string]interface{}{
+				"hash":        txHash,
+				"nonce":       hexutil.Uint64(tx.Nonce()),
+				"gas":         hexutil.Uint64(tx.Gas()),
+				"gasPrice":    (*hexutil.Big)(tx.GasPrice()),
+				"to":          tx.To(),
+				"blockNumber": nil,
+			}
+			if !f.unmined {
+				transaction["blockNumber"] = "0x2"
+			}
+			return transaction
+		}
+	}
+	return nil
+}
+
+func (f *FakeNode) sentTransactions() []*types.Transaction {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]*types.Transaction(nil), f.sent...)
+}
+
+// newFakeNodeProcessor returns a processor sending job completions for a single agent to node
+func newFakeNodeProcessor(t *testing.T, node *FakeNode) Processor {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", node))
+	client := rpc.DialInProc(server)
+	ethClient := ethclient.NewClient(client)
+
+	agentAddress := common.HexToAddress("0xa9e7")
+	agent, err := NewAgent(agentAddress, ethClient)
+	require.NoError(t, err)
+
+	signer := &fakeSigner{address: common.HexToAddress("0x5e1f")}
+	return Processor{
+		rawClient:             client,
+		ethClient:             ethClient,
+		agents:                []*agentContract{{address: agentAddress, agent: agent}},
+		agentABI:              testAgentABI,
+		events:                testEvents,
+		signer:                signer,
+		address:               signer.address.Hex(),
+		nonces:                newNonceTracker(ethClient, signer.address),
+		jobCompletionGasLimit: 100000,
+		confirmationTimeout:   5 * time.Second,
+	}
+}
+
+func TestSubmitJobCompletionReverted(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobInfo := &jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: signature}
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	require.NoError(t, p.submitJobCompletion(context.Background(), a, jobInfo))
+
+	node.receiptStatus = types.ReceiptStatusFailed
+	err = p.submitJobCompletion(context.Background(), a, jobInfo)
+	require.Error(t, err)
+	_, permanent := err.(permanentError)
+	assert.False(t, permanent)
+	assert.Len(t, node.sentTransactions(), 2)
+}
+
+func TestSubmitJobCompletionLowBalance(t *testing.T) {
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobInfo := &jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: signature}
+
+	// The transaction may cost the 100000 gas limit at a gas price of 1
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, balance: big.NewInt(99999)}
+	p := newFakeNodeProcessor(t, node)
+	err := p.submitJobCompletion(context.Background(), testAgentABI, jobInfo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "below the 100000 wei")
+	assert.Empty(t, node.sentTransactions())
+	assert.Equal(t, float64(1), gaugeValue(t, lowBalance))
+
+	node.balance = big.NewInt(100000)
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI, jobInfo))
+	assert.Len(t, node.sentTransactions(), 1)
+	assert.Equal(t, float64(0), gaugeValue(t, lowBalance))
+}
+
+func TestEstimateGasLimitCap(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		gas      uint64
+		cap      uint64
+		expected uint64
+		err      bool
+	}{
+		{name: "no cap", gas: 100000, expected: 120000},
+		{name: "under cap", gas: 100000, cap: 200000, expected: 120000},
+		{name: "buffer capped", gas: 100000, cap: 110000, expected: 110000},
+		{name: "estimate over cap", gas: 100000, cap: 90000, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Processor{ethClient: &fakeEthClient{gas: tt.gas}, jobCompletionGasBuffer: 20, gasLimitCap: tt.cap}
+
+			gasLimit, err := p.estimateGasLimit(context.Background(), common.HexToAddress("0xa9e7"), nil)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, gasLimit)
+		})
+	}
+}
+
+func TestSubmitJobForCompletion(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	p.enabled, p.boltDB = true, boltDB
+	p.jobCompletionQueue = make(chan *jobInfo, 1)
+	p.queueMutex, p.inFlight = &sync.RWMutex{}, newInFlightJobs()
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	defer p.cancel()
+	go p.processJobCompletions()
+	defer close(p.jobCompletionQueue)
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFailedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+
+	_, err = p.SubmitJobForCompletion(context.Background(), jobAddress.Bytes(), []byte{1, 2, 3})
+	assert.Error(t, err)
+	assert.Empty(t, node.sentTransactions())
+
+	txHash, err := p.SubmitJobForCompletion(context.Background(), jobAddress.Bytes(), job.JobSignature)
+	require.NoError(t, err)
+
+	// The agent was sent completeJob for the job with its signature
+	sent := node.sentTransactions()
+	require.Len(t, sent, 1)
+	assert.Equal(t, sent[0].Hash(), txHash)
+	assert.Equal(t, p.agents[0].address, *sent[0].To())
+
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+	v, r, s, err := parseSignature(job.JobSignature)
+	require.NoError(t, err)
+	input, err := a.Pack("completeJob", jobAddress, v, r, s)
+	require.NoError(t, err)
+	assert.Equal(t, input, sent[0].Data())
+
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobFundedState, job.JobState)
+}
+
+func TestSubmitJobCompletionRecordsTxHash(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	p.boltDB = boltDB
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFundedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: job.JobSignature}))
+	sent := node.sentTransactions()
+	require.Len(t, sent, 1)
+
+	// The transaction is stored with the job and shown by the jobs API
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sent[0].Hash().Bytes(), job.CompletionTxHash)
+	assert.Equal(t, sent[0].Hash().Hex(), newJobView(*job).CompletionTx)
+
+	// It survives a re-scan of the job's events until JobCompleted removes the job
+	word := common.LeftPadBytes(jobAddress.Bytes(), 32)
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{{Topics: []common.Hash{testEvents.jobFundedID},
+		Data: word}}, big.NewInt(1), common.Hash{}))
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sent[0].Hash().Bytes(), job.CompletionTxHash)
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{{Topics: []common.Hash{testEvents.jobCompletedID},
+		Data: word}}, big.NewInt(2), common.Hash{}))
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+}
+
+func TestSubmitOldJobsSkipsMinedCompletion(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	scanner, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	p.boltDB = boltDB
+
+	mined, unsent := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	putCompletedJobs(t, scanner, map[common.Address]string{mined: jobFundedState, unsent: jobFundedState})
+	job, err := db.GetJob(boltDB, mined.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: mined.Bytes(), jobSignatureBytes: job.JobSignature}))
+
+	// The receipt is in but the JobCompleted event isn't yet, so only the job with nothing sent is resubmitted
+	job, err = db.GetJob(boltDB, mined.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job.CompletionMinedAtBlock)
+	assert.Equal(t, uint64(2), *job.CompletionMinedAtBlock)
+	assert.Nil(t, job.CompletedAtBlock)
+
+	scanner.submitOldJobsForCompletion()
+	require.Len(t, scanner.jobCompletionQueue, 1)
+	assert.Equal(t, unsent.Bytes(), (<-scanner.jobCompletionQueue).jobAddressBytes)
+	scanner.inFlight.remove(unsent.Bytes())
+
+	// A reorg back before the mined block leaves the completion to be sent again
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return restoreCompletedJobs(tx.Bucket(db.JobBucketName), big.NewInt(1))
+	}))
+	scanner.submitOldJobsForCompletion()
+	assert.Len(t, scanner.jobCompletionQueue, 2)
+}
+
+func TestSubmitJobCompletionAlreadyKnown(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// The node takes the transaction but the reply times out, so the retry finds it already in the pool
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful,
+		sendErrs: []error{errors.New("request timeout"), errors.New("already known")}}
+	p := newFakeNodeProcessor(t, node)
+	p.boltDB = boltDB
+	p.submitAttempts, p.submitRetryDelay = 3, time.Millisecond
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFundedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: job.JobSignature}))
+
+	// No second transaction went out under a fresh nonce; the one the node has is recorded and waited on
+	sent := node.sentTransactions()
+	require.Len(t, sent, 1)
+	assert.Equal(t, uint64(0), sent[0].Nonce())
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sent[0].Hash().Bytes(), job.CompletionTxHash)
+	require.NotNil(t, job.CompletionMinedAtBlock)
+	nonce, err := p.nonces.next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce)
+}
+
+func TestSubmitJobCompletionReplacement(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// The original is never mined, and the first try at sending its replacement times out
+	node := &FakeNode{unmined: true, gasPrice: big.NewInt(1000), sendErrs: []error{nil, errors.New("request timeout")}}
+	p := newFakeNodeProcessor(t, node)
+	p.boltDB = boltDB
+	p.submitAttempts, p.submitRetryDelay = 3, time.Millisecond
+	p.resubmitInterval, p.maxResubmits, p.gasPriceBump = 100*time.Millisecond, 1, 10
+	p.confirmationTimeout = time.Second
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFundedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, errCompletionUnconfirmed, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: job.JobSignature}))
+
+	// The replacement was retried like the original and went out under its nonce at the bumped gas price
+	sent := node.sentTransactions()
+	require.Len(t, sent, 2)
+	assert.Equal(t, sent[0].Nonce(), sent[1].Nonce())
+	assert.True(t, sent[1].GasPrice().Cmp(big.NewInt(1100)) >= 0, "replacement gas price %v", sent[1].GasPrice())
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sent[1].Hash().Bytes(), job.CompletionTxHash)
+	nonce, err := p.nonces.next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce)
+}
+
+func TestSubmitJobCompletionNonceTooLowAfterTimeout(t *testing.T) {
+	// The first try times out after the node took the transaction, which is mined before the retry is refused
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful,
+		sendErrs: []error{errors.New("request timeout"), errors.New("nonce too low")}}
+	p := newFakeNodeProcessor(t, node)
+	p.submitAttempts, p.submitRetryDelay = 3, time.Millisecond
+
+	require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+		&jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: testJobSignature}))
+
+	// The mined transaction counts as sent, so nothing was signed again under a refreshed nonce
+	assert.Len(t, node.sentTransactions(), 1)
+	assert.Len(t, p.signer.(*fakeSigner).signed, 2)
+	nonce, err := p.nonces.next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce)
+}
+
+func TestSubmitJobCompletionDryRun(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobInfo := &jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: signature}
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	p.dryRun = true
+
+	require.NoError(t, p.submitJobCompletion(context.Background(), a, jobInfo))
+	assert.Empty(t, node.sentTransactions())
+}
+
+func TestSubmitOldJobsSkipsDryRun(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	scanner, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	p.boltDB, p.dryRun, scanner.dryRun = boltDB, true, true
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, scanner, map[common.Address]string{jobAddress: jobFundedState})
+
+	// The first scan's dry run is recorded, so the second has nothing left to log
+	attempts := 0
+	for scan := 0; scan < 2; scan++ {
+		scanner.submitOldJobsForCompletion()
+		for len(scanner.jobCompletionQueue) > 0 {
+			jobInfo := <-scanner.jobCompletionQueue
+			require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI, jobInfo))
+			scanner.inFlight.remove(jobInfo.jobAddressBytes)
+			attempts++
+		}
+	}
+	assert.Equal(t, 1, attempts)
+	assert.Empty(t, node.sentTransactions())
+
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.True(t, job.DryRun)
+	assert.Nil(t, job.CompletionTxHash)
+
+	// Once dry runs are over the job is completed for real
+	scanner.dryRun = false
+	scanner.submitOldJobsForCompletion()
+	assert.Len(t, scanner.jobCompletionQueue, 1)
+}
+
+// histogramCount returns the number of observations made by h
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	metric := &dto.Metric{}
+	require.NoError(t, h.Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	metric := &dto.Metric{}
+	require.NoError(t, g.Write(metric))
+	return metric.GetGauge().GetValue()
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	require.NoError(t, c.Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestCompletionLatencyMetrics(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+		test.inFlight
+	p.ctx = test.ctx
+
+	queued, confirmed := histogramCount(t, completionQueueLatency), histogramCount(t, completionConfirmationLatency)
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobInfo := &jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: signature}
+	p.enqueueJobCompletion(jobInfo)
+	assert.False(t, jobInfo.enqueuedAt.IsZero())
+
+	close(p.jobCompletionQueue)
+	p.processJobCompletions()
+	require.Len(t, node.sentTransactions(), 1)
+
+	assert.Equal(t, queued+1, histogramCount(t, completionQueueLatency))
+	assert.Equal(t, confirmed+1, histogramCount(t, completionConfirmationLatency))
+}
+
+func TestSubmitJobCompletionCancelled(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobInfo := &jobInfo{jobAddressBytes: common.HexToAddress("0x1234").Bytes(), jobSignatureBytes: signature}
+
+	node := &FakeNode{unmined: true}
+	p := newFakeNodeProcessor(t, node)
+	p.confirmationTimeout = time.Minute
+
+	// Cancelled before anything is sent, the submission fails without sending a transaction
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, p.submitJobCompletion(ctx, a, jobInfo))
+	assert.Empty(t, node.sentTransactions())
+
+	// Cancelled while waiting for the transaction to be mined, the wait is abandoned, leaving the job for replay
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	assert.Equal(t, errSubmissionCancelled, p.submitJobCompletion(ctx, a, jobInfo))
+	assert.True(t, time.Since(start) < 10*time.Second)
+	assert.Len(t, node.sentTransactions(), 1)
+}
+
+func TestMaxInFlightConfirmations(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, unmined: true}
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+		test.inFlight
+	p.ctx = test.ctx
+	p.confirmationTimeout = time.Minute
+	p.confirmations = newConfirmationLimit(2)
+
+	for _, address := range []string{"0x01", "0x02", "0x03"} {
+		p.enqueueJobCompletion(&jobInfo{jobAddressBytes: common.HexToAddress(address).Bytes(),
+			jobSignatureBytes: testJobSignature})
+	}
+	close(p.jobCompletionQueue)
+
+	done := make(chan struct{})
+	go func() {
+		p.processJobCompletions()
+		close(done)
+	}()
+
+	// Two transactions are sent and waited on together; the third waits for one of them to be mined
+	for deadline := time.Now().Add(5 * time.Second); len(node.sentTransactions()) < 2; {
+		require.True(t, time.Now().Before(deadline), "completions not sent")
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, node.sentTransactions(), 2)
+
+	node.mutex.Lock()
+	node.unmined = false
+	node.mutex.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("job completions not confirmed")
+	}
+	assert.Len(t, node.sentTransactions(), 3)
+
+	entries, err := db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCompletionWorkers(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, unmined: true}
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+		test.inFlight
+	p.ctx, p.loops = test.ctx, &sync.WaitGroup{}
+	p.confirmationTimeout = time.Minute
+	p.completionWorkers = 3
+
+	for _, address := range []string{"0x01", "0x02", "0x03"} {
+		p.enqueueJobCompletion(&jobInfo{jobAddressBytes: common.HexToAddress(address).Bytes(),
+			jobSignatureBytes: testJobSignature})
+	}
+	close(p.jobCompletionQueue)
+
+	done := make(chan struct{})
+	p.runCompletionWorkers()
+	go func() {
+		p.loops.Wait()
+		close(done)
+	}()
+
+	// Each worker sends its job's transaction while the others are still waiting on theirs to be mined
+	for deadline := time.Now().Add(5 * time.Second); len(node.sentTransactions()) < 3; {
+		require.True(t, time.Now().Before(deadline), "completions not sent in parallel")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	node.mutex.Lock()
+	node.unmined = false
+	node.mutex.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("job completions not confirmed")
+	}
+
+	// The transactions drew distinct nonces, and every job was completed once
+	nonces, jobs := map[uint64]bool{}, map[string]bool{}
+	for _, tx := range node.sentTransactions() {
+		nonces[tx.Nonce()] = true
+		jobs[hexutil.Encode(tx.Data())] = true
+	}
+	assert.Len(t, nonces, 3)
+	assert.Len(t, jobs, 3)
+
+	entries, err := db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/config"
+)
+
+// dialEthereum connects to the Ethereum JSON-RPC endpoint. HTTP endpoints may be reached through an HTTP or SOCKS5
+// proxy and with a custom TLS configuration; the websocket client of the pinned go-ethereum can't be given either,
+// so other endpoints connect directly.
+func dialEthereum(endpoint, proxy string, tlsConfig *tls.Config) (*rpc.Client, error) {
+	if (proxy == "" && tlsConfig == nil) || !config.IsHTTPEndpoint(endpoint) {
+		return rpc.Dial(endpoint)
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing proxy URL")
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return rpc.DialHTTPWithClient(endpoint, &http.Client{Transport: transport})
+}
+
+// ethereumTLSConfig builds the TLS configuration for an Ethereum endpoint behind mutual TLS, or returns nil if none
+// is configured. The client certificate is optional, and a CA bundle is trusted in addition to the system roots
+// rather than instead of them.
+func ethereumTLSConfig(certPath, keyPath, caPath string) (*tls.Config, error) {
+	if certPath == "" && caPath == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if certPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if caPath != "" {
+		caBytes, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading CA bundle")
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("no certificates found in CA bundle")
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialEthereumThroughProxy(t *testing.T) {
+	// A forward proxy receives the absolute URL of the endpoint; this one answers every call itself
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		proxied = append(proxied, req.URL.String())
+		fmt.Fprintln(resp, `{"jsonrpc":"2.0","id":1,"result":"0x2a"}`)
+	}))
+	defer proxy.Close()
+
+	client, err := dialEthereum("http://ethereum.invalid:8545", proxy.URL, nil)
+	require.NoError(t, err)
+
+	var blockNumber string
+	require.NoError(t, client.CallContext(context.Background(), &blockNumber, "eth_blockNumber"))
+	assert.Equal(t, "0x2a", blockNumber)
+	assert.Equal(t, []string{"http://ethereum.invalid:8545/"}, proxied)
+}
+
+func TestDialEthereumDirect(t *testing.T) {
+	var requests int
+	node := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requests++
+		fmt.Fprintln(resp, `{"jsonrpc":"2.0","id":1,"result":"0x2a"}`)
+	}))
+	defer node.Close()
+
+	client, err := dialEthereum(node.URL, "", nil)
+	require.NoError(t, err)
+
+	var blockNumber string
+	require.NoError(t, client.CallContext(context.Background(), &blockNumber, "eth_blockNumber"))
+	assert.Equal(t, 1, requests)
+}
+
+// writeTestCertificate writes a self-signed certificate and its key to dir as PEM files
+func writeTestCertificate(t *testing.T, dir string) (cert *x509.Certificate, certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "snetd test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(certBytes)
+	require.NoError(t, err)
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: certBytes}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: keyBytes}), 0600))
+	return cert, certPath, keyPath
+}
+
+func TestEthereumTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snetd-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tlsConfig, err := ethereumTLSConfig("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	// The node requires a client certificate, and its own is only trusted through the CA bundle
+	clientCert, certPath, keyPath := writeTestCertificate(t, dir)
+	node := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(resp, `{"jsonrpc":"2.0","id":1,"result":"0x2a"}`)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	node.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	node.StartTLS()
+	defer node.Close()
+
+	caPath := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: node.Certificate().Raw}), 0600))
+
+	tlsConfig, err = ethereumTLSConfig(certPath, keyPath, caPath)
+	require.NoError(t, err)
+	require.Len(t, tlsConfig.Certificates, 1)
+	assert.Equal(t, clientCert.Raw, tlsConfig.Certificates[0].Certificate[0])
+	require.NotNil(t, tlsConfig.RootCAs)
+
+	client, err := dialEthereum(node.URL, "", tlsConfig)
+	require.NoError(t, err)
+	var blockNumber string
+	require.NoError(t, client.CallContext(context.Background(), &blockNumber, "eth_blockNumber"))
+	assert.Equal(t, "0x2a", blockNumber)
+
+	// Only a CA bundle: no client certificate is presented, so this node turns the client away
+	tlsConfig, err = ethereumTLSConfig("", "", caPath)
+	require.NoError(t, err)
+	assert.Empty(t, tlsConfig.Certificates)
+	client, err = dialEthereum(node.URL, "", tlsConfig)
+	require.NoError(t, err)
+	assert.Error(t, client.CallContext(context.Background(), &blockNumber, "eth_blockNumber"))
+}
@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// The kinds of ProcessingError, for listeners to tell failures apart
+var (
+	// ErrSignatureParse is a job completion failure caused by a job signature that can't be parsed
+	ErrSignatureParse = errors.New("unable to parse job signature")
+	// ErrSubmission is a job completion that failed to send or whose transaction reverted
+	ErrSubmission = errors.New("unable to submit job completion")
+	// ErrRPCUnavailable is a failure to reach the Ethereum node, or a node too loaded to answer
+	ErrRPCUnavailable = errors.New("Ethereum node unavailable")
+	// ErrEventProcessing is any other failure to follow job events into the db
+	ErrEventProcessing = errors.New("unable to process job events")
+)
+
+// errorListenerBuffer is the number of failures held for a listener before further ones are dropped
+const errorListenerBuffer = 100
+
+// ProcessingError is a failure the processor recovers from on its own, by retrying or moving on, reported to
+// ErrorListeners so the host can react to it, e.g. by alerting or restarting
+type ProcessingError struct {
+	// Kind is one of ErrSignatureParse, ErrSubmission, ErrRPCUnavailable or ErrEventProcessing
+	Kind error
+	// JobAddress is the job the failure concerns, or the zero address for a failure not tied to a job
+	JobAddress common.Address
+	// Err is the underlying failure
+	Err error
+}
+
+func (e *ProcessingError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// ErrorListener is notified of processing failures. Notifications are delivered in order from a goroutine per
+// listener; a listener that falls too far behind misses failures rather than holding up processing.
+type ErrorListener interface {
+	ProcessingFailed(err *ProcessingError)
+}
+
+// errorListeners fans failures out to the registered listeners. It is shared by all copies of a Processor.
+type errorListeners struct {
+	mutex    sync.RWMutex
+	channels []chan *ProcessingError
+}
+
+// AddErrorListener registers listener for processing failures until the processor stops
+func (p Processor) AddErrorListener(listener ErrorListener) {
+	p.errorListeners.add(p.ctx, listener)
+}
+
+func (l *errorListeners) add(ctx context.Context, listener ErrorListener) {
+	failures := make(chan *ProcessingError, errorListenerBuffer)
+
+	l.mutex.Lock()
+	l.channels = append(l.channels, failures)
+	l.mutex.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case failure := <-failures:
+				recoverPanic("error listener", func() { listener.ProcessingFailed(failure) })
+			}
+		}
+	}()
+}
+
+// emit queues failure for every listener without waiting on any of them
+func (l *errorListeners) emit(failure *ProcessingError) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, channel := range l.channels {
+		select {
+		case channel <- failure:
+		default:
+			log.WithField("kind", failure.Kind).Warn("error listener falling behind; dropping failure")
+		}
+	}
+}
+
+// reportEventError reports a failure to follow job events, as ErrRPCUnavailable if the node couldn't be reached
+func (p Processor) reportEventError(err error) {
+	kind := ErrEventProcessing
+	if isTransientRPCError(err) {
+		kind = ErrRPCUnavailable
+	}
+	p.errorListeners.emit(&ProcessingError{Kind: kind, Err: err})
+}
+
+// reportCompletionError reports a failure to complete the job at jobAddress. A permanent failure can only come from
+// the job's signature, which is parsed before anything is sent.
+func (p Processor) reportCompletionError(jobAddress common.Address, err error) {
+	kind := ErrSubmission
+	if _, permanent := err.(permanentError); permanent {
+		kind = ErrSignatureParse
+	} else if isTransientRPCError(err) {
+		kind = ErrRPCUnavailable
+	}
+	p.errorListeners.emit(&ProcessingError{Kind: kind, JobAddress: jobAddress, Err: err})
+}
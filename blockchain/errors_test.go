@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorRecorder is an ErrorListener sending each failure to the channel
+type errorRecorder chan *ProcessingError
+
+func (r errorRecorder) ProcessingFailed(err *ProcessingError) {
+	r <- err
+}
+
+func (r errorRecorder) next(t *testing.T) *ProcessingError {
+	select {
+	case err := <-r:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("no processing error reported")
+		return nil
+	}
+}
+
+func TestErrorListenerEvents(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.errorListeners = &errorListeners{}
+	recorder := make(errorRecorder, 10)
+	p.AddErrorListener(recorder)
+
+	node := &downNode{err: errors.New("dial tcp: connection refused")}
+	p.ethClient, p.rawClient = node, node
+	require.False(t, p.pollEvents(testEvents))
+
+	failure := recorder.next(t)
+	assert.Equal(t, ErrRPCUnavailable, failure.Kind)
+	assert.Equal(t, common.Address{}, failure.JobAddress)
+	assert.Equal(t, node.err, failure.Err)
+	assert.Equal(t, "Ethereum node unavailable: dial tcp: connection refused", failure.Error())
+}
+
+func TestErrorListenerCompletions(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		signature []byte
+		agentErr  error
+		kind      error
+	}{
+		{name: "unparseable signature", signature: []byte{1, 2, 3}, kind: ErrSignatureParse},
+		{name: "reverted", signature: testJobSignature, agentErr: errors.New("execution reverted"), kind: ErrSubmission},
+		{name: "node down", signature: testJobSignature, agentErr: errors.New("connection reset by peer"),
+			kind: ErrRPCUnavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			boltDB, cleanup := newTestDB(t)
+			defer cleanup()
+
+			agent := &fakeAgent{}
+			if tt.agentErr != nil {
+				agent.errs = []error{tt.agentErr}
+			}
+			p := newFakeAgentProcessor(agent)
+			test, cancel := newTestProcessor(boltDB)
+			defer cancel()
+			p.boltDB, p.ctx, p.inFlight, p.queueMutex = boltDB, test.ctx, test.inFlight, test.queueMutex
+			p.errorListeners = &errorListeners{}
+			recorder := make(errorRecorder, 10)
+			p.AddErrorListener(recorder)
+
+			jobAddress := common.HexToAddress("0x1234")
+			p.processJobCompletion(testAgentABI, &jobInfo{jobAddressBytes: jobAddress.Bytes(),
+				jobSignatureBytes: tt.signature})
+
+			failure := recorder.next(t)
+			assert.Equal(t, tt.kind, failure.Kind)
+			assert.Equal(t, jobAddress, failure.JobAddress)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// agentABIVersions maps each AGENT_ABI_VERSION to the agent contract ABI the processor decodes events and packs calls
+// with, so a deployment can follow an upgraded contract whose job events changed. Version "1" is the contract the
+// bindings were generated from.
+var agentABIVersions = map[string]string{
+	"1": AgentABI,
+}
+
+// jobEventData holds the decoded arguments of a job event. Every job event carries the job address; only JobCreated
+// carries the consumer, which is left zero for the others. Amount is the funded amount of a JobFunded event from a
+// contract version whose event carries it, and nil otherwise.
+type jobEventData struct {
+	Job      common.Address
+	Consumer common.Address
+	Amount   *big.Int
+}
+
+// decode unpacks the arguments of the named job event from jobLog according to the agent ABI. Indexed arguments are
+// read in order from the log's topics after the event ID, and the rest from its data. The address arguments are
+// matched to jobEventData by position rather than name, so a contract that renames them still decodes; the first
+// unsigned integer argument is the amount.
+func (e jobEvents) decode(event string, jobLog types.Log) (jobEventData, error) {
+	data := jobEventData{}
+	inputs := e.agentABI.Events[event].Inputs
+
+	values, err := inputs.UnpackValues(jobLog.Data)
+	if err != nil {
+		return data, errors.Wrap(err, "error unpacking event data")
+	}
+
+	var topics []common.Hash
+	if len(jobLog.Topics) > 0 {
+		topics = jobLog.Topics[1:]
+	}
+
+	addresses := make([]common.Address, 0, len(inputs))
+	for _, input := range inputs {
+		if input.Type.T != abi.AddressTy && input.Type.T != abi.UintTy {
+			return data, errors.Errorf("unsupported type %v for event argument %q", input.Type, input.Name)
+		}
+
+		var value interface{}
+		if input.Indexed {
+			if len(topics) == 0 {
+				return data, errors.Errorf("missing topic for indexed event argument %q", input.Name)
+			}
+			if input.Type.T == abi.AddressTy {
+				value = common.BytesToAddress(topics[0].Bytes())
+			} else {
+				value = new(big.Int).SetBytes(topics[0].Bytes())
+			}
+			topics = topics[1:]
+		} else {
+			value, values = values[0], values[1:]
+		}
+
+		if input.Type.T == abi.UintTy {
+			amount, ok := toBigInt(value)
+			if !ok {
+				return data, errors.Errorf("unexpected value %v for event argument %q", value, input.Name)
+			}
+			if data.Amount == nil {
+				data.Amount = amount
+			}
+			continue
+		}
+
+		address, ok := value.(common.Address)
+		if !ok {
+			return data, errors.Errorf("unexpected value %v for event argument %q", value, input.Name)
+		}
+		addresses = append(addresses, address)
+	}
+
+	if len(addresses) == 0 {
+		return data, errors.New("event has no job argument")
+	}
+	data.Job = addresses[0]
+	if len(addresses) > 1 {
+		data.Consumer = addresses[1]
+	}
+	return data, nil
+}
+
+// toBigInt converts an unpacked unsigned integer argument, which is a *big.Int or a fixed size uint for the smaller
+// types, to a *big.Int
+func toBigInt(value interface{}) (*big.Int, bool) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, true
+	case uint8:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case uint16:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case uint64:
+		return new(big.Int).SetUint64(v), true
+	}
+	return nil, false
+}
+
+// carriesFundedAmount reports whether the agent ABI's JobFunded event has an amount argument for decode to read
+func (e jobEvents) carriesFundedAmount() bool {
+	for _, input := range e.agentABI.Events["JobFunded"].Inputs {
+		if input.Type.T == abi.UintTy {
+			return true
+		}
+	}
+	return false
+}
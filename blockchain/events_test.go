@@ -0,0 +1,170 @@
+package blockchain
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexedAgentABI declares the job events with their job address indexed, and JobCompleted with both arguments
+// indexed, as a contract upgrade might
+const indexedAgentABI = `[
+	{"anonymous":false,"name":"JobCreated","type":"event","inputs":[
+		{"indexed":true,"name":"jobAddress","type":"address"},{"indexed":false,"name":"consumer","type":"address"}]},
+	{"anonymous":false,"name":"JobFunded","type":"event","inputs":[
+		{"indexed":true,"name":"jobAddress","type":"address"}]},
+	{"anonymous":false,"name":"JobCompleted","type":"event","inputs":[
+		{"indexed":true,"name":"jobAddress","type":"address"},{"indexed":true,"name":"consumer","type":"address"}]}
+]`
+
+// fundedAgentABI declares JobFunded with the amount the job was funded with, as a contract upgrade might
+const fundedAgentABI = `[
+	{"anonymous":false,"name":"JobCreated","type":"event","inputs":[
+		{"indexed":false,"name":"job","type":"address"},{"indexed":false,"name":"consumer","type":"address"}]},
+	{"anonymous":false,"name":"JobFunded","type":"event","inputs":[
+		{"indexed":false,"name":"job","type":"address"},{"indexed":false,"name":"amount","type":"uint256"}]},
+	{"anonymous":false,"name":"JobCompleted","type":"event","inputs":[
+		{"indexed":false,"name":"job","type":"address"}]}
+]`
+
+func TestDecodeJobEvents(t *testing.T) {
+	job := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	consumer := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	for _, tt := range []struct {
+		name    string
+		event   string
+		log     types.Log
+		want    jobEventData
+		wantErr bool
+	}{
+		{name: "created", event: "JobCreated",
+			log:  types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(job), word(consumer)...)},
+			want: jobEventData{Job: job, Consumer: consumer}},
+		{name: "funded", event: "JobFunded",
+			log:  types.Log{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(job)},
+			want: jobEventData{Job: job}},
+		{name: "completed", event: "JobCompleted",
+			log:  types.Log{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(job)},
+			want: jobEventData{Job: job}},
+		{name: "truncated", event: "JobCreated",
+			log:     types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: word(job)[:20]},
+			wantErr: true},
+		{name: "empty", event: "JobFunded",
+			log:     types.Log{Topics: []common.Hash{testEvents.jobFundedID}},
+			wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := testEvents.decode(tt.event, tt.log)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, data)
+		})
+	}
+}
+
+func TestDecodeIndexedJobEvents(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(indexedAgentABI))
+	require.NoError(t, err)
+	events := newJobEvents(a)
+
+	job := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	consumer := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	topic := func(a common.Address) common.Hash { return common.BytesToHash(a.Bytes()) }
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	for _, tt := range []struct {
+		name    string
+		event   string
+		log     types.Log
+		want    jobEventData
+		wantErr bool
+	}{
+		{name: "created", event: "JobCreated",
+			log:  types.Log{Topics: []common.Hash{events.jobCreatedID, topic(job)}, Data: word(consumer)},
+			want: jobEventData{Job: job, Consumer: consumer}},
+		{name: "funded", event: "JobFunded",
+			log:  types.Log{Topics: []common.Hash{events.jobFundedID, topic(job)}},
+			want: jobEventData{Job: job}},
+		{name: "completed", event: "JobCompleted",
+			log:  types.Log{Topics: []common.Hash{events.jobCompletedID, topic(job), topic(consumer)}},
+			want: jobEventData{Job: job, Consumer: consumer}},
+		{name: "missing topic", event: "JobCreated",
+			log:     types.Log{Topics: []common.Hash{events.jobCreatedID}, Data: word(consumer)},
+			wantErr: true},
+		{name: "missing data", event: "JobCreated",
+			log:     types.Log{Topics: []common.Hash{events.jobCreatedID, topic(job)}},
+			wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := events.decode(tt.event, tt.log)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, data)
+		})
+	}
+}
+
+func TestAgentABIVersion(t *testing.T) {
+	agentABIVersions["indexed"] = indexedAgentABI
+	defer delete(agentABIVersions, "indexed")
+	defer config.Vip().Set(config.AgentABIVersionKey, config.GetString(config.AgentABIVersionKey))
+
+	job := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	consumer := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	// The default version decodes the original contract's events
+	p, err := NewProcessor(nil)
+	require.NoError(t, err)
+	assert.Equal(t, testEvents.jobCreatedID, p.events.jobCreatedID)
+	data, err := p.events.decode("JobCreated", types.Log{Topics: []common.Hash{p.events.jobCreatedID},
+		Data: append(word(job), word(consumer)...)})
+	require.NoError(t, err)
+	assert.Equal(t, jobEventData{Job: job, Consumer: consumer}, data)
+
+	// The upgraded contract's JobCompleted has a new signature and carries its arguments in topics
+	config.Vip().Set(config.AgentABIVersionKey, "indexed")
+	p, err = NewProcessor(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, testEvents.jobCompletedID, p.events.jobCompletedID)
+	data, err = p.events.decode("JobCompleted", types.Log{Topics: []common.Hash{p.events.jobCompletedID,
+		common.BytesToHash(job.Bytes()), common.BytesToHash(consumer.Bytes())}})
+	require.NoError(t, err)
+	assert.Equal(t, jobEventData{Job: job, Consumer: consumer}, data)
+
+	config.Vip().Set(config.AgentABIVersionKey, "0")
+	_, err = NewProcessor(nil)
+	assert.Error(t, err)
+
+	// A minimum funding can only be enforced if JobFunded carries the amount
+	agentABIVersions["funded"] = fundedAgentABI
+	defer delete(agentABIVersions, "funded")
+	defer config.Vip().Set(config.MinJobFundingKey, config.GetString(config.MinJobFundingKey))
+	config.Vip().Set(config.MinJobFundingKey, "100")
+	config.Vip().Set(config.AgentABIVersionKey, "1")
+	_, err = NewProcessor(nil)
+	assert.Error(t, err)
+	config.Vip().Set(config.AgentABIVersionKey, "funded")
+	p, err = NewProcessor(nil)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), p.minJobFunding)
+	data, err = p.events.decode("JobFunded", types.Log{Topics: []common.Hash{p.events.jobFundedID},
+		Data: append(word(job), common.LeftPadBytes(big.NewInt(250).Bytes(), 32)...)})
+	require.NoError(t, err)
+	assert.Equal(t, jobEventData{Job: job, Amount: big.NewInt(250)}, data)
+}
@@ -0,0 +1,215 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// failoverEndpoint is one of the Ethereum endpoints a failoverClient routes calls to
+type failoverEndpoint struct {
+	url string
+	eth EthereumClient
+	raw RawCaller
+}
+
+// failoverClient is an EthereumClient and RawCaller that sends each call to the active one of several endpoints,
+// starting with the first. Once calls to the active endpoint have failed transiently threshold times in a row it
+// fails over to the next, retrying the failing call there. While failed over, the first endpoint is tried again at
+// most once per failback interval and becomes active again as soon as it answers. It is safe for concurrent use.
+type failoverClient struct {
+	endpoints        []failoverEndpoint
+	threshold        int
+	failbackInterval time.Duration
+
+	mutex        sync.Mutex
+	active       int
+	failures     int
+	lastFailback time.Time
+}
+
+func newFailoverClient(endpoints []failoverEndpoint, threshold int, failbackInterval time.Duration) *failoverClient {
+	return &failoverClient{endpoints: endpoints, threshold: threshold, failbackInterval: failbackInterval}
+}
+
+// do makes call against the active endpoint, failing over or back as needed. Only transient errors count against an
+// endpoint; any other error is the call's own and returned as is.
+func (c *failoverClient) do(call func(failoverEndpoint) error) error {
+	c.mutex.Lock()
+	active := c.active
+	probe := active != 0 && c.failbackInterval > 0 && time.Since(c.lastFailback) >= c.failbackInterval
+	if probe {
+		c.lastFailback = time.Now()
+	}
+	c.mutex.Unlock()
+
+	if probe {
+		if err := call(c.endpoints[0]); err == nil || !isTransientRPCError(err) {
+			c.failBack()
+			return err
+		}
+	}
+
+	err := call(c.endpoints[active])
+	if err == nil || !isTransientRPCError(err) {
+		c.succeed(active)
+		return err
+	}
+
+	if next, ok := c.fail(active, err); ok {
+		return call(c.endpoints[next])
+	}
+	return err
+}
+
+func (c *failoverClient) succeed(index int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if index == c.active {
+		c.failures = 0
+	}
+}
+
+// fail records a transient failure of the endpoint at index, returning the endpoint to retry the call on if it's no
+// longer the active one
+func (c *failoverClient) fail(index int, err error) (int, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another call has already failed over
+	if index != c.active {
+		return c.active, true
+	}
+
+	c.failures++
+	if c.failures < c.threshold || len(c.endpoints) < 2 {
+		return index, false
+	}
+
+	c.active, c.failures, c.lastFailback = (index+1)%len(c.endpoints), 0, time.Now()
+	log.WithError(err).
+		WithField("from", c.endpoints[index].url).
+		WithField("to", c.endpoints[c.active].url).
+		Warn("Ethereum endpoint failing; failing over to the next endpoint")
+	return c.active, true
+}
+
+func (c *failoverClient) failBack() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.active == 0 {
+		return
+	}
+	log.WithField("from", c.endpoints[c.active].url).
+		WithField("to", c.endpoints[0].url).
+		Info("primary Ethereum endpoint answering again; failing back")
+	c.active, c.failures = 0, 0
+}
+
+func (c *failoverClient) CallContext(ctx context.Context, result interface{}, method string,
+	args ...interface{}) error {
+	return c.do(func(e failoverEndpoint) error { return e.raw.CallContext(ctx, result, method, args...) })
+}
+
+func (c *failoverClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		logs, err = e.eth.FilterLogs(ctx, query)
+		return err
+	})
+	return logs, err
+}
+
+func (c *failoverClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery,
+	ch chan<- types.Log) (sub ethereum.Subscription, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		sub, err = e.eth.SubscribeFilterLogs(ctx, query, ch)
+		return err
+	})
+	return sub, err
+}
+
+func (c *failoverClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) (code []byte,
+	err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		code, err = e.eth.CodeAt(ctx, contract, blockNumber)
+		return err
+	})
+	return code, err
+}
+
+func (c *failoverClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) (result []byte,
+	err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		result, err = e.eth.CallContract(ctx, call, blockNumber)
+		return err
+	})
+	return result, err
+}
+
+func (c *failoverClient) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		code, err = e.eth.PendingCodeAt(ctx, account)
+		return err
+	})
+	return code, err
+}
+
+func (c *failoverClient) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		nonce, err = e.eth.PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+func (c *failoverClient) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		price, err = e.eth.SuggestGasPrice(ctx)
+		return err
+	})
+	return price, err
+}
+
+func (c *failoverClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (gas uint64, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		gas, err = e.eth.EstimateGas(ctx, call)
+		return err
+	})
+	return gas, err
+}
+
+func (c *failoverClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return c.do(func(e failoverEndpoint) error { return e.eth.SendTransaction(ctx, tx) })
+}
+
+func (c *failoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		header, err = e.eth.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+func (c *failoverClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (balance *big.Int,
+	err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		balance, err = e.eth.BalanceAt(ctx, account, blockNumber)
+		return err
+	})
+	return balance, err
+}
+
+func (c *failoverClient) NetworkID(ctx context.Context) (id *big.Int, err error) {
+	err = c.do(func(e failoverEndpoint) (err error) {
+		id, err = e.eth.NetworkID(ctx)
+		return err
+	})
+	return id, err
+}
@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// downNode is an endpoint whose raw calls and log queries fail with err, counting the calls
+type downNode struct {
+	EthereumClient
+	err   error
+	calls int
+}
+
+func (d *downNode) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	d.calls++
+	return d.err
+}
+
+func (d *downNode) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	d.calls++
+	return nil, d.err
+}
+
+func (d *downNode) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	d.calls++
+	return nil, d.err
+}
+
+func TestFailoverClient(t *testing.T) {
+	primary := &downNode{err: errors.New("Post http://primary: dial tcp: connection refused")}
+	secondary := fakeRawCaller{"eth_blockNumber": "0x2a"}
+	client := newFailoverClient([]failoverEndpoint{
+		{url: "http://primary", eth: primary, raw: primary},
+		{url: "http://secondary", eth: &fakeEthClient{}, raw: secondary},
+	}, 2, time.Hour)
+
+	// Below the threshold the failure is returned
+	var block string
+	assert.Error(t, client.CallContext(context.Background(), &block, "eth_blockNumber"))
+
+	// At the threshold the call fails over and is answered by the secondary, which answers the calls after it
+	require.NoError(t, client.CallContext(context.Background(), &block, "eth_blockNumber"))
+	assert.Equal(t, "0x2a", block)
+	block = ""
+	require.NoError(t, client.CallContext(context.Background(), &block, "eth_blockNumber"))
+	assert.Equal(t, "0x2a", block)
+	assert.Equal(t, 2, primary.calls)
+
+	// Once the failback interval has passed the primary is tried again, and kept once it answers
+	primary.err = errors.New("execution reverted")
+	client.mutex.Lock()
+	client.lastFailback = time.Now().Add(-time.Hour)
+	client.mutex.Unlock()
+	assert.EqualError(t, client.CallContext(context.Background(), &block, "eth_blockNumber"), "execution reverted")
+	assert.Equal(t, 0, client.active)
+
+	// Errors from the call itself don't count against the endpoint
+	for i := 0; i < 5; i++ {
+		assert.Error(t, client.CallContext(context.Background(), &block, "eth_blockNumber"))
+	}
+	assert.Equal(t, 0, client.active)
+	assert.Equal(t, 8, primary.calls)
+}
+
+func TestFailoverClientProcessor(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	primary := &downNode{err: errors.New("503 Service Unavailable")}
+	secondary := &fakeEthClient{
+		head: &types.Header{Number: big.NewInt(20), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}},
+		logs: []types.Log{{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress),
+			word(consumer)...), BlockNumber: 20}},
+	}
+	client := newFailoverClient([]failoverEndpoint{
+		{url: "http://primary", eth: primary, raw: primary},
+		{url: "http://secondary", eth: secondary, raw: fakeRawCaller{}},
+	}, 1, time.Hour)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.ethClient, p.rawClient = client, client
+	p.logScanChunkSize = 100
+	p.status = &processorStatus{}
+
+	// The primary being down is invisible to the poll
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, 1, primary.calls)
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+}
@@ -0,0 +1,103 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// processorStatus tracks liveness of the processor loops. It is shared by all copies of a Processor.
+type processorStatus struct {
+	mutex    sync.RWMutex
+	lastPoll time.Time
+	// backfilling is set while polls are held back by the per-poll block limit from reaching the confirmed head
+	backfilling bool
+	// behind is how far the confirmed chain was ahead of the event cursor at the last poll
+	behind int64
+}
+
+// recordPoll marks that the event loop just completed a successful iteration
+func (s *processorStatus) recordPoll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastPoll = time.Now()
+}
+
+func (s *processorStatus) lastPollTime() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.lastPoll
+}
+
+// recordBackfill records whether the last poll stopped short of the confirmed head
+func (s *processorStatus) recordBackfill(backfilling bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.backfilling = backfilling
+}
+
+func (s *processorStatus) isBackfilling() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.backfilling
+}
+
+// recordBlocksBehind records how far the confirmed chain is ahead of the event cursor
+func (s *processorStatus) recordBlocksBehind(behind int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.behind = behind
+}
+
+func (s *processorStatus) blocksBehind() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.behind
+}
+
+// HealthHandler returns an HTTP handler that responds 200 only when blockchain processing is enabled, the event loop
+// completed a successful poll within the staleness window, and the Ethereum node answers eth_blockNumber. A healthy
+// response reports the last block processed for events on the line after "ok".
+func (p Processor) HealthHandler(staleness time.Duration) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if err := p.checkHealth(req.Context(), staleness); err != nil {
+			http.Error(resp, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(resp, "ok")
+		if lastBlock, err := p.LastProcessedBlock(); err != nil {
+			log.WithError(err).Warn("error reading event cursor for health check")
+		} else if lastBlock != nil {
+			fmt.Fprintf(resp, "lastBlock %v\n", lastBlock)
+		}
+	})
+}
+
+func (p Processor) checkHealth(ctx context.Context, staleness time.Duration) error {
+	if !p.enabled {
+		return fmt.Errorf("blockchain processing disabled")
+	}
+
+	if lastPoll := p.status.lastPollTime(); time.Since(lastPoll) > staleness {
+		return fmt.Errorf("no successful poll since %v", lastPoll)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var currentBlockHex string
+	if err := p.rawClient.CallContext(ctx, &currentBlockHex, "eth_blockNumber"); err != nil {
+		return fmt.Errorf("error determining current block: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BlockNode answers eth_blockNumber, or fails it with err
+type BlockNode struct {
+	err error
+}
+
+func (n *BlockNode) BlockNumber() (*hexutil.Big, error) {
+	return (*hexutil.Big)(big.NewInt(20)), n.err
+}
+
+func TestHealthHandler(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		disabled bool
+		polled   bool
+		nodeErr  error
+		code     int
+	}{
+		{name: "healthy", polled: true, code: http.StatusOK},
+		{name: "disabled", disabled: true, polled: true, code: http.StatusServiceUnavailable},
+		{name: "no recent poll", code: http.StatusServiceUnavailable},
+		{name: "node unreachable", polled: true, nodeErr: errors.New("connection refused"),
+			code: http.StatusServiceUnavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := rpc.NewServer()
+			require.NoError(t, server.RegisterName("eth", &BlockNode{err: tt.nodeErr}))
+			p := Processor{enabled: !tt.disabled, rawClient: rpc.DialInProc(server), status: &processorStatus{}}
+			if tt.polled {
+				p.status.recordPoll()
+			}
+
+			resp := httptest.NewRecorder()
+			p.HealthHandler(time.Minute).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+			assert.Equal(t, tt.code, resp.Code)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package blockchain
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// GetJobHistory returns the recorded history of the job at jobAddress, oldest entry first: each state transition seen
+// in its events, with the transaction that emitted it, each completion transaction sent for it, and its failure or
+// retry through the daemon. Transitions seen in blocks later reorganized out stay recorded, as seen, while an event
+// seen again, e.g. by a re-scan, ReplayLogs or Resync, adds nothing; jobs the db has no record of have no history. The
+// history outlives the record of a completed job, so its history is still available, but is pruned along with an
+// expired job. A history altered since it was written is an error.
+func (p Processor) GetJobHistory(jobAddress common.Address) ([]db.HistoryEntry, error) {
+	if p.boltDB == nil {
+		return nil, errors.New("blockchain processing disabled")
+	}
+	return db.GetJobHistory(p.boltDB, jobAddress.Bytes())
+}
@@ -0,0 +1,99 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historyStates returns the from and to states and transaction of each of entries
+func historyStates(entries []db.HistoryEntry) [][3]string {
+	states := make([][3]string, len(entries))
+	for i, entry := range entries {
+		states[i] = [3]string{entry.FromState, entry.ToState, common.BytesToHash(entry.TxHash).Hex()}
+	}
+	return states
+}
+
+func TestJobHistory(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	createdTx, fundedTx, completionTx := common.Hash{1}, common.Hash{2}, common.Hash{3}
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...),
+			BlockNumber: 5, TxHash: createdTx},
+		// Seeing the creation again changes nothing and isn't recorded
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...),
+			BlockNumber: 6, TxHash: common.Hash{9}},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress), BlockNumber: 7, TxHash: fundedTx},
+	}, big.NewInt(7), common.Hash{}))
+
+	// The completion is sent, then its event seen; the job is deleted but its history kept
+	_, err := p.markJobCompleted(jobAddress.Bytes(), testJobSignature, false)
+	require.NoError(t, err)
+	p.recordCompletionTx(completionTx, jobAddress.Bytes())
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(jobAddress), BlockNumber: 9,
+			TxHash: completionTx},
+	}, big.NewInt(9), common.Hash{}))
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	entries, err := p.GetJobHistory(jobAddress)
+	require.NoError(t, err)
+	assert.Equal(t, [][3]string{
+		{"", jobPendingState, createdTx.Hex()},
+		{jobPendingState, jobFundedState, fundedTx.Hex()},
+		{jobFundedState, jobFundedState, completionTx.Hex()},
+		{jobFundedState, jobCompletedState, completionTx.Hex()},
+	}, historyStates(entries))
+	for i := 1; i < len(entries); i++ {
+		assert.False(t, entries[i].Timestamp.Before(entries[i-1].Timestamp))
+	}
+
+	// Re-scanning the events adds nothing, and a job completed without ever being seen gets no history
+	otherAddress := common.HexToAddress("0x9999")
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...),
+			BlockNumber: 5, TxHash: createdTx},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress), BlockNumber: 7, TxHash: fundedTx},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(jobAddress), BlockNumber: 9,
+			TxHash: completionTx},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(otherAddress), BlockNumber: 10,
+			TxHash: common.Hash{8}},
+	}, big.NewInt(10), common.Hash{}))
+	rescanned, err := p.GetJobHistory(jobAddress)
+	require.NoError(t, err)
+	assert.Equal(t, entries, rescanned)
+
+	// A job that fails to complete, and is retried by hand, records both without a transaction
+	failedAddress := common.HexToAddress("0x5678")
+	putCompletedJobs(t, p, map[common.Address]string{failedAddress: jobFundedState})
+	_, failed, err := p.recordCompletionAttempt(failedAddress.Bytes(), true)
+	require.NoError(t, err)
+	require.True(t, failed)
+	_, err = p.markJobCompleted(failedAddress.Bytes(), testJobSignature, true)
+	require.NoError(t, err)
+	entries, err = p.GetJobHistory(failedAddress)
+	require.NoError(t, err)
+	assert.Equal(t, [][3]string{
+		{jobFundedState, jobFailedState, common.Hash{}.Hex()},
+		{jobFailedState, jobFundedState, common.Hash{}.Hex()},
+	}, historyStates(entries))
+
+	entries, err = p.GetJobHistory(otherAddress)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
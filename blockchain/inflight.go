@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlightJobs is the set of jobs queued or being completed, so that a job enqueued from more than one place (e.g. the
+// startup scan and a new invocation) is completed at most once at a time. It is safe for concurrent use.
+type inFlightJobs struct {
+	mutex sync.Mutex
+	jobs  map[string]struct{}
+}
+
+func newInFlightJobs() *inFlightJobs {
+	return &inFlightJobs{jobs: make(map[string]struct{})}
+}
+
+// add marks the job in flight, reporting false if it already was
+func (f *inFlightJobs) add(jobAddressBytes []byte) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.jobs[string(jobAddressBytes)]; ok {
+		return false
+	}
+	f.jobs[string(jobAddressBytes)] = struct{}{}
+	return true
+}
+
+// has reports whether the job is in flight
+func (f *inFlightJobs) has(jobAddressBytes []byte) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, ok := f.jobs[string(jobAddressBytes)]
+	return ok
+}
+
+// remove clears the job once its completion is confirmed, failed or left unconfirmed
+func (f *inFlightJobs) remove(jobAddressBytes []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.jobs, string(jobAddressBytes))
+}
+
+// confirmationLimit bounds how many job completion transactions are waited on at once: a slot is taken before each
+// transaction is sent and given back once it is mined or left unconfirmed, so sending pauses while the limit is
+// reached. It is safe for concurrent use.
+type confirmationLimit chan struct{}
+
+func newConfirmationLimit(n int) confirmationLimit {
+	return make(confirmationLimit, n)
+}
+
+// acquire takes a slot, waiting for one to be released if none is free. It reports false if ctx is done first.
+func (l confirmationLimit) acquire(ctx context.Context) bool {
+	select {
+	case l <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release gives back a slot taken by acquire
+func (l confirmationLimit) release() {
+	<-l
+}
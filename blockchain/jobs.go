@@ -0,0 +1,287 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/config"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// jobView is the JSON representation of a stored job, with hex-encoded addresses and signature
+type jobView struct {
+	JobAddress   string     `json:"jobAddress"`
+	JobSignature string     `json:"jobSignature,omitempty"`
+	JobState     string     `json:"jobState"`
+	Consumer     string     `json:"consumer,omitempty"`
+	Completed    bool       `json:"completed"`
+	AgentAddress string     `json:"agentAddress,omitempty"`
+	FundedAmount string     `json:"fundedAmount,omitempty"`
+	CompletionTx string     `json:"completionTxHash,omitempty"`
+	CompletedAt  *uint64    `json:"completedAtBlock,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"`
+}
+
+func newJobView(job db.Job) jobView {
+	view := jobView{
+		JobAddress:  common.BytesToAddress(job.JobAddress).Hex(),
+		JobState:    job.JobState,
+		Completed:   job.Completed,
+		CompletedAt: job.CompletedAtBlock,
+	}
+	if job.JobSignature != nil {
+		view.JobSignature = "0x" + hex.EncodeToString(job.JobSignature)
+	}
+	if job.Consumer != nil {
+		view.Consumer = common.BytesToAddress(job.Consumer).Hex()
+	}
+	if job.AgentAddress != nil {
+		view.AgentAddress = common.BytesToAddress(job.AgentAddress).Hex()
+	}
+	if job.FundedAmount != nil {
+		view.FundedAmount = job.FundedAmount.String()
+	}
+	if job.CompletionTxHash != nil {
+		view.CompletionTx = common.BytesToHash(job.CompletionTxHash).Hex()
+	}
+	// Jobs stored before timestamps were recorded have none to report
+	if !job.CreatedAt.IsZero() {
+		view.CreatedAt = &job.CreatedAt
+	}
+	if !job.UpdatedAt.IsZero() {
+		view.UpdatedAt = &job.UpdatedAt
+	}
+	return view
+}
+
+// historyView is the JSON representation of a job history entry, with hex-encoded hashes
+type historyView struct {
+	Timestamp time.Time `json:"timestamp"`
+	FromState string    `json:"fromState"`
+	ToState   string    `json:"toState"`
+	TxHash    string    `json:"txHash,omitempty"`
+	Hash      string    `json:"hash"`
+}
+
+func newHistoryView(entry db.HistoryEntry) historyView {
+	view := historyView{
+		Timestamp: entry.Timestamp,
+		FromState: entry.FromState,
+		ToState:   entry.ToState,
+		Hash:      "0x" + hex.EncodeToString(entry.Hash),
+	}
+	// A job's failure and retry are recorded by the daemon rather than from a transaction
+	if common.BytesToHash(entry.TxHash) != (common.Hash{}) {
+		view.TxHash = common.BytesToHash(entry.TxHash).Hex()
+	}
+	return view
+}
+
+// JobsHandler returns an HTTP handler for inspecting the jobs stored in the db. GET /jobs lists jobs, optionally
+// filtered with ?state=PENDING, FUNDED or FAILED; GET /jobs/<address> returns a single job, and GET
+// /jobs/<address>/history its history as returned by GetJobHistory.
+func (p Processor) JobsHandler() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if p.boltDB == nil {
+			http.Error(resp, "blockchain processing disabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		if req.Method != http.MethodGet {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := strings.TrimSuffix(req.URL.Path, "/")
+		switch {
+		case path == "/jobs":
+			state := req.URL.Query().Get("state")
+			switch state {
+			case "", jobPendingState, jobFundedState, jobFailedState:
+				p.listJobs(resp, state)
+			default:
+				http.Error(resp, "unrecognized job state", http.StatusBadRequest)
+			}
+		case strings.HasPrefix(path, "/jobs/") && strings.HasSuffix(path, "/history"):
+			p.getJobHistory(resp, strings.TrimSuffix(strings.TrimPrefix(path, "/jobs/"), "/history"))
+		case strings.HasPrefix(path, "/jobs/"):
+			p.getJob(resp, strings.TrimPrefix(path, "/jobs/"))
+		default:
+			http.NotFound(resp, req)
+		}
+	})
+}
+
+// AdminHandler returns an HTTP handler for the operations that spend gas or change the processor's state, kept apart
+// from the read-only JobsHandler since neither authenticates its callers; serve it only where operators can reach it,
+// e.g. on localhost. POST /jobs/<address>/complete with a JSON body of {"signature": "0x..."} forces completion of a
+// job and returns the hash of the transaction sent. POST /resync with a JSON body of {"fromBlock": 123} re-scans job
+// events from that block. POST /reload re-reads the config file and applies its hot-reloadable settings, as listed by
+// Reload; a SIGHUP does the same.
+func (p Processor) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if p.boltDB == nil {
+			http.Error(resp, "blockchain processing disabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		if req.Method != http.MethodPost {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := strings.TrimSuffix(req.URL.Path, "/")
+		switch {
+		case path == "/resync":
+			p.resync(resp, req)
+		case path == "/reload":
+			p.reload(resp)
+		case strings.HasPrefix(path, "/jobs/") && strings.HasSuffix(path, "/complete"):
+			p.completeJob(resp, req, strings.TrimSuffix(strings.TrimPrefix(path, "/jobs/"), "/complete"))
+		default:
+			http.NotFound(resp, req)
+		}
+	})
+}
+
+func (p Processor) listJobs(resp http.ResponseWriter, state string) {
+	jobs, err := db.ListJobs(p.boltDB, state)
+	if err != nil {
+		log.WithError(err).Error("error listing jobs")
+		http.Error(resp, "error listing jobs", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]jobView, len(jobs))
+	for i, job := range jobs {
+		views[i] = newJobView(job)
+	}
+	writeJSON(resp, views)
+}
+
+func (p Processor) getJob(resp http.ResponseWriter, address string) {
+	if !common.IsHexAddress(address) {
+		http.Error(resp, "invalid job address", http.StatusBadRequest)
+		return
+	}
+
+	job, err := db.GetJob(p.boltDB, common.HexToAddress(address).Bytes())
+	if err != nil {
+		log.WithError(err).Error("error retrieving job")
+		http.Error(resp, "error retrieving job", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(resp, "job not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(resp, newJobView(*job))
+}
+
+func (p Processor) getJobHistory(resp http.ResponseWriter, address string) {
+	if !common.IsHexAddress(address) {
+		http.Error(resp, "invalid job address", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := p.GetJobHistory(common.HexToAddress(address))
+	if err != nil {
+		log.WithError(err).WithField("jobAddress", address).Error("error retrieving job history")
+		http.Error(resp, "error retrieving job history", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]historyView, len(entries))
+	for i, entry := range entries {
+		views[i] = newHistoryView(entry)
+	}
+	writeJSON(resp, views)
+}
+
+func (p Processor) completeJob(resp http.ResponseWriter, req *http.Request, address string) {
+	if !common.IsHexAddress(address) {
+		http.Error(resp, "invalid job address", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(resp, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(body.Signature, "0x"))
+	if err != nil {
+		http.Error(resp, "invalid job signature", http.StatusBadRequest)
+		return
+	}
+
+	txHash, err := p.SubmitJobForCompletion(req.Context(), common.HexToAddress(address).Bytes(), signature)
+	if err != nil {
+		log.WithError(err).WithField("jobAddress", address).Warn("error completing job on request")
+		http.Error(resp, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(resp, struct {
+		TxHash string `json:"txHash"`
+	}{txHash.Hex()})
+}
+
+func (p Processor) resync(resp http.ResponseWriter, req *http.Request) {
+	var body struct {
+		FromBlock *big.Int `json:"fromBlock"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(resp, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := p.Resync(body.FromBlock); err != nil {
+		log.WithError(err).WithField("fromBlock", body.FromBlock).Warn("error resyncing on request")
+		http.Error(resp, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(resp, body)
+}
+
+func (p Processor) reload(resp http.ResponseWriter) {
+	if err := config.Reload(); err != nil {
+		log.WithError(err).Warn("error reloading configuration on request")
+		http.Error(resp, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := p.Reload(); err != nil {
+		log.WithError(err).Warn("error applying reloaded configuration on request")
+		http.Error(resp, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(resp, struct {
+		PollSleep          string  `json:"pollSleep"`
+		GasPriceMultiplier float64 `json:"gasPriceMultiplier"`
+	}{config.GetDuration(config.PollSleepKey).String(), p.settings.gasMultiplier()})
+}
+
+func writeJSON(resp http.ResponseWriter, v interface{}) {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(v); err != nil {
+		log.WithError(err).Error("error encoding response")
+		http.Error(resp, "error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	body.WriteTo(resp)
+}
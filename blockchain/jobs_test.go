@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsHandler(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	jobAddress := common.HexToAddress("0x1234")
+	jobBytes, err := json.Marshal(db.Job{JobAddress: jobAddress.Bytes(), JobState: jobFundedState})
+	require.NoError(t, err)
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.JobBucketName).Put(jobAddress.Bytes(), jobBytes)
+	}))
+
+	handler := Processor{boltDB: boltDB}.JobsHandler()
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp
+	}
+
+	var jobs []jobView
+	resp := get("/jobs?state=" + jobFundedState)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &jobs))
+	assert.Equal(t, []jobView{{JobAddress: jobAddress.Hex(), JobState: jobFundedState}}, jobs)
+
+	resp = get("/jobs?state=" + jobPendingState)
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &jobs))
+	assert.Empty(t, jobs)
+
+	var job jobView
+	resp = get("/jobs/" + jobAddress.Hex())
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &job))
+	assert.Equal(t, jobAddress.Hex(), job.JobAddress)
+
+	assert.Equal(t, http.StatusBadRequest, get("/jobs?state=NOPE").Code)
+	assert.Equal(t, http.StatusNotFound, get("/jobs/"+common.HexToAddress("0x5678").Hex()).Code)
+	assert.Equal(t, http.StatusBadRequest, get("/jobs/nope").Code)
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return db.AppendJobHistory(tx, jobAddress.Bytes(), db.HistoryEntry{Timestamp: time.Now(),
+			FromState: jobPendingState, ToState: jobFundedState, TxHash: common.Hash{1}.Bytes()})
+	}))
+	var history []historyView
+	resp = get("/jobs/" + jobAddress.Hex() + "/history")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &history))
+	require.Len(t, history, 1)
+	assert.Equal(t, jobFundedState, history[0].ToState)
+	assert.Equal(t, common.Hash{1}.Hex(), history[0].TxHash)
+	assert.Equal(t, http.StatusBadRequest, get("/jobs/nope/history").Code)
+}
+
+func TestAdminHandler(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	serve := func(handler http.Handler, method, path, body string) int {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return resp.Code
+	}
+
+	jobPath := "/jobs/" + common.HexToAddress("0x1234").Hex() + "/complete"
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(p.AdminHandler(), http.MethodGet, jobPath, ""))
+	assert.Equal(t, http.StatusBadRequest, serve(p.AdminHandler(), http.MethodPost, "/jobs/nope/complete", "{}"))
+	assert.Equal(t, http.StatusBadRequest, serve(p.AdminHandler(), http.MethodPost, jobPath, "nope"))
+	assert.Equal(t, http.StatusNotFound, serve(p.AdminHandler(), http.MethodPost, "/jobs", ""))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(p.AdminHandler(), http.MethodGet, "/resync", ""))
+	assert.Equal(t, http.StatusBadRequest, serve(p.AdminHandler(), http.MethodPost, "/resync", "nope"))
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(p.AdminHandler(), http.MethodPost, "/resync",
+		`{"fromBlock": 0}`))
+	assert.Equal(t, http.StatusOK, serve(p.AdminHandler(), http.MethodPost, "/resync", `{"fromBlock": 7}`))
+	block, _ := getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(6), block)
+
+	// The read-only jobs API doesn't serve the operations that spend gas or change the processor's state
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(p.JobsHandler(), http.MethodPost, jobPath,
+		`{"signature": "0x01"}`))
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(p.JobsHandler(), http.MethodPost, "/resync",
+		`{"fromBlock": 7}`))
+}
@@ -0,0 +1,35 @@
+// Package jobsapi holds the gRPC definitions of the jobs API served by the blockchain processor, and the generated
+// client for it.
+package jobsapi
+
+//go:generate protoc --go_out=plugins=grpc:. jobs.proto
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec is the protobuf codec the jobs API is served with. The daemon replaces the default "proto" codec with one
+// passing raw frames through to the proxied service, so the jobs server sets this one explicitly.
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("object %+v not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("object %+v not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (Codec) String() string {
+	return "proto"
+}
@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/blockchain/jobsapi"
+	"github.com/singnet/snet-daemon/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// JobsGrpcServer returns a gRPC server serving the jobs API of jobsapi.JobsServer, the gRPC counterpart of
+// JobsHandler
+func (p Processor) JobsGrpcServer() *grpc.Server {
+	server := grpc.NewServer(grpc.CustomCodec(jobsapi.Codec{}))
+	jobsapi.RegisterJobsServer(server, jobsServer{p})
+	return server
+}
+
+// jobsServer implements the jobs gRPC API on a processor
+type jobsServer struct {
+	p Processor
+}
+
+func (s jobsServer) GetJob(ctx context.Context, req *jobsapi.GetJobRequest) (*jobsapi.Job, error) {
+	if s.p.boltDB == nil {
+		return nil, status.Error(codes.Unavailable, "blockchain processing disabled")
+	}
+	if !common.IsHexAddress(req.JobAddress) {
+		return nil, status.Error(codes.InvalidArgument, "invalid job address")
+	}
+
+	job, err := db.GetJob(s.p.boltDB, common.HexToAddress(req.JobAddress).Bytes())
+	if err != nil {
+		log.WithError(err).Error("error retrieving job")
+		return nil, status.Error(codes.Internal, "error retrieving job")
+	}
+	if job == nil {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+
+	return newJobMessage(*job), nil
+}
+
+func (s jobsServer) ListJobs(ctx context.Context, req *jobsapi.ListJobsRequest) (*jobsapi.ListJobsResponse, error) {
+	if s.p.boltDB == nil {
+		return nil, status.Error(codes.Unavailable, "blockchain processing disabled")
+	}
+	switch req.State {
+	case "", jobPendingState, jobFundedState, jobFailedState:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "unrecognized job state")
+	}
+
+	jobs, err := db.ListJobs(s.p.boltDB, req.State)
+	if err != nil {
+		log.WithError(err).Error("error listing jobs")
+		return nil, status.Error(codes.Internal, "error listing jobs")
+	}
+
+	resp := &jobsapi.ListJobsResponse{Jobs: make([]*jobsapi.Job, len(jobs))}
+	for i, job := range jobs {
+		resp.Jobs[i] = newJobMessage(job)
+	}
+	return resp, nil
+}
+
+func (s jobsServer) GetProcessorStatus(ctx context.Context, req *jobsapi.GetProcessorStatusRequest) (
+	*jobsapi.ProcessorStatus, error) {
+	resp := &jobsapi.ProcessorStatus{Enabled: s.p.enabled, QueueDepth: int32(len(s.p.jobCompletionQueue))}
+	if s.p.boltDB == nil {
+		return resp, nil
+	}
+
+	lastBlock, err := s.p.LastProcessedBlock()
+	if err != nil {
+		log.WithError(err).Error("error reading event cursor")
+		return nil, status.Error(codes.Internal, "error reading event cursor")
+	}
+	if lastBlock != nil {
+		resp.LastBlock = lastBlock.Uint64()
+	}
+	if s.p.status != nil {
+		resp.BlocksBehind = s.p.status.blocksBehind()
+	}
+	return resp, nil
+}
+
+func (s jobsServer) CompleteJob(ctx context.Context, req *jobsapi.CompleteJobRequest) (*jobsapi.CompleteJobResponse,
+	error) {
+	if !s.p.grpcCompleteEnabled {
+		return nil, status.Error(codes.PermissionDenied, "job completion over gRPC not enabled")
+	}
+	if !common.IsHexAddress(req.JobAddress) {
+		return nil, status.Error(codes.InvalidArgument, "invalid job address")
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(req.JobSignature, "0x"))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid job signature")
+	}
+
+	txHash, err := s.p.SubmitJobForCompletion(ctx, common.HexToAddress(req.JobAddress).Bytes(), signature)
+	if err != nil {
+		log.WithError(err).WithField("jobAddress", req.JobAddress).Warn("error completing job on request")
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return &jobsapi.CompleteJobResponse{TxHash: txHash.Hex()}, nil
+}
+
+// newJobMessage converts a stored job to its gRPC representation, formatted as the jobs HTTP API formats it
+func newJobMessage(job db.Job) *jobsapi.Job {
+	view := newJobView(job)
+	message := &jobsapi.Job{
+		JobAddress:       view.JobAddress,
+		JobSignature:     view.JobSignature,
+		JobState:         view.JobState,
+		Consumer:         view.Consumer,
+		Completed:        view.Completed,
+		AgentAddress:     view.AgentAddress,
+		FundedAmount:     view.FundedAmount,
+		CompletionTxHash: view.CompletionTx,
+	}
+	if view.CompletedAt != nil {
+		message.CompletedAtBlock = *view.CompletedAt
+	}
+	if view.CreatedAt != nil {
+		message.CreatedAt = view.CreatedAt.Unix()
+	}
+	if view.UpdatedAt != nil {
+		message.UpdatedAt = view.UpdatedAt.Unix()
+	}
+	return message
+}
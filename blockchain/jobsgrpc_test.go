@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/blockchain/jobsapi"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialJobsServer serves p's jobs gRPC API over an in-memory listener and returns a client for it
+func dialJobsServer(t *testing.T, p Processor) (jobsapi.JobsClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := p.JobsGrpcServer()
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn,
+		error) {
+		return lis.Dial()
+	}))
+	require.NoError(t, err)
+
+	return jobsapi.NewJobsClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestJobsGrpcServer(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	p.enabled, p.boltDB, p.status = true, boltDB, &processorStatus{}
+	p.jobCompletionQueue = make(chan *jobInfo, 1)
+	p.queueMutex, p.inFlight = &sync.RWMutex{}, newInFlightJobs()
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	defer p.cancel()
+	go p.processJobCompletions()
+	defer close(p.jobCompletionQueue)
+
+	client, stop := dialJobsServer(t, p)
+	defer stop()
+	ctx := context.Background()
+
+	jobAddress, pendingAddress := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFundedState, pendingAddress: jobPendingState})
+	stored, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+
+	job, err := client.GetJob(ctx, &jobsapi.GetJobRequest{JobAddress: jobAddress.Hex()})
+	require.NoError(t, err)
+	assert.Equal(t, jobAddress.Hex(), job.JobAddress)
+	assert.Equal(t, jobFundedState, job.JobState)
+	assert.Equal(t, common.BytesToAddress(stored.Consumer).Hex(), job.Consumer)
+	assert.True(t, job.Completed)
+
+	_, err = client.GetJob(ctx, &jobsapi.GetJobRequest{JobAddress: common.HexToAddress("0x9999").Hex()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetJob(ctx, &jobsapi.GetJobRequest{JobAddress: "nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	jobs, err := client.ListJobs(ctx, &jobsapi.ListJobsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, jobs.Jobs, 2)
+	jobs, err = client.ListJobs(ctx, &jobsapi.ListJobsRequest{State: jobPendingState})
+	require.NoError(t, err)
+	require.Len(t, jobs.Jobs, 1)
+	assert.Equal(t, pendingAddress.Hex(), jobs.Jobs[0].JobAddress)
+	_, err = client.ListJobs(ctx, &jobsapi.ListJobsRequest{State: "NOPE"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(42), common.Hash{1})
+	}))
+	p.status.recordBlocksBehind(3)
+	processorStatus, err := client.GetProcessorStatus(ctx, &jobsapi.GetProcessorStatusRequest{})
+	require.NoError(t, err)
+	assert.True(t, processorStatus.Enabled)
+	assert.Equal(t, uint64(42), processorStatus.LastBlock)
+	assert.Equal(t, int64(3), processorStatus.BlocksBehind)
+	assert.Equal(t, int32(0), processorStatus.QueueDepth)
+
+	// Manual completion has to be enabled, and then returns the hash of the transaction sent
+	_, err = client.CompleteJob(ctx, &jobsapi.CompleteJobRequest{JobAddress: jobAddress.Hex(),
+		JobSignature: "0x" + hex.EncodeToString(stored.JobSignature)})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Empty(t, node.sentTransactions())
+
+	stop()
+	p.grpcCompleteEnabled = true
+	client, stop = dialJobsServer(t, p)
+	defer stop()
+	_, err = client.CompleteJob(ctx, &jobsapi.CompleteJobRequest{JobAddress: jobAddress.Hex(), JobSignature: "0x01"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	completed, err := client.CompleteJob(ctx, &jobsapi.CompleteJobRequest{JobAddress: jobAddress.Hex(),
+		JobSignature: "0x" + hex.EncodeToString(stored.JobSignature)})
+	require.NoError(t, err)
+	sent := node.sentTransactions()
+	require.Len(t, sent, 1)
+	assert.Equal(t, sent[0].Hash().Hex(), completed.TxHash)
+}
@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// jobCompletedState is the state reported to listeners for a job whose JobCompleted event was seen; completed jobs are
+// deleted from the db rather than stored in it
+const jobCompletedState = "COMPLETED"
+
+// jobListenerBuffer is the number of transitions held for a listener before further ones are dropped
+const jobListenerBuffer = 100
+
+// JobTransition is a change in a job's state caused by an agent event. States are PENDING, FUNDED, FAILED and
+// COMPLETED; OldState is empty for a job not seen before.
+type JobTransition struct {
+	JobAddress common.Address
+	OldState   string
+	NewState   string
+	// TxHash is the transaction that emitted the event
+	TxHash common.Hash
+	// completedByDaemon marks a COMPLETED transition of a job this daemon submitted the completion of
+	completedByDaemon bool
+	// unknown marks a COMPLETED transition of a job the db has no record of, which has no history to record it in
+	unknown bool
+}
+
+// JobStateListener is notified of job state transitions once the events causing them are committed to the db.
+// Notifications are delivered in order from a goroutine per listener; a listener that falls too far behind misses
+// transitions rather than holding up event processing.
+type JobStateListener interface {
+	JobStateChanged(transition JobTransition)
+}
+
+// jobListeners fans transitions out to the registered listeners. It is shared by all copies of a Processor.
+type jobListeners struct {
+	mutex    sync.RWMutex
+	channels []chan JobTransition
+}
+
+// AddJobStateListener registers listener for job state transitions until the processor stops
+func (p Processor) AddJobStateListener(listener JobStateListener) {
+	p.listeners.add(p.ctx, listener)
+}
+
+func (l *jobListeners) add(ctx context.Context, listener JobStateListener) {
+	transitions := make(chan JobTransition, jobListenerBuffer)
+
+	l.mutex.Lock()
+	l.channels = append(l.channels, transitions)
+	l.mutex.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case transition := <-transitions:
+				recoverPanic("job state listener", func() { listener.JobStateChanged(transition) })
+			}
+		}
+	}()
+}
+
+// emit queues transitions for every listener without waiting on any of them
+func (l *jobListeners) emit(transitions []JobTransition) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, channel := range l.channels {
+		for _, transition := range transitions {
+			select {
+			case channel <- transition:
+			default:
+				log.WithField("jobAddress", transition.JobAddress.Hex()).WithField("newState", transition.NewState).
+					Warn("job state listener falling behind; dropping transition")
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transitionRecorder is a JobStateListener passing transitions on to a channel
+type transitionRecorder chan JobTransition
+
+func (r transitionRecorder) JobStateChanged(transition JobTransition) {
+	r <- transition
+}
+
+// blockingListener is a JobStateListener that never returns
+type blockingListener struct{}
+
+func (blockingListener) JobStateChanged(transition JobTransition) {
+	select {}
+}
+
+func TestJobStateListener(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.listeners = &jobListeners{}
+
+	// A stuck listener must not starve the others
+	p.AddJobStateListener(blockingListener{})
+	recorder := make(transitionRecorder, 10)
+	p.AddJobStateListener(recorder)
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	jobLogs := []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(common.LeftPadBytes(jobAddress.Bytes(), 32),
+			common.LeftPadBytes(consumer.Bytes(), 32)...)},
+		// Seeing the creation again changes nothing
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(common.LeftPadBytes(jobAddress.Bytes(), 32),
+			common.LeftPadBytes(consumer.Bytes(), 32)...)},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+	}
+	require.NoError(t, p.commitJobLogs(testEvents, jobLogs, big.NewInt(2), common.Hash{}))
+
+	var received []JobTransition
+	for len(received) < 3 {
+		select {
+		case transition := <-recorder:
+			received = append(received, transition)
+		case <-time.After(time.Second):
+			t.Fatalf("received %v of 3 transitions", len(received))
+		}
+	}
+	assert.Equal(t, []JobTransition{
+		{JobAddress: jobAddress, OldState: "", NewState: jobPendingState},
+		{JobAddress: jobAddress, OldState: jobPendingState, NewState: jobFundedState},
+		{JobAddress: jobAddress, OldState: jobFundedState, NewState: jobCompletedState},
+	}, received)
+}
+
+func TestJobListenersDontBlock(t *testing.T) {
+	p, cancel := newTestProcessor(nil)
+	defer cancel()
+	p.listeners = &jobListeners{}
+	p.AddJobStateListener(blockingListener{})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*jobListenerBuffer; i++ {
+			p.listeners.emit([]JobTransition{{NewState: jobPendingState}})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emitting transitions blocked on a stuck listener")
+	}
+}
@@ -0,0 +1,40 @@
+package blockchain
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// logger is the blockchain processor's own logger, so its level and format can be set apart from the rest of the
+// daemon's
+var logger = logrus.New()
+
+// log is what the package logs through; every message is tagged with the component it came from
+var log = logrus.NewEntry(logger).WithField("component", "blockchain")
+
+// configureLogger sets the level and format of the processor's logger. An empty level follows the daemon's
+// LOG_LEVEL; format is either "text" or "json".
+func configureLogger(level, format string) error {
+	logLevel := logrus.GetLevel()
+	if level != "" {
+		parsed, err := logrus.ParseLevel(level)
+		if err != nil {
+			return errors.Wrap(err, "error parsing BLOCKCHAIN_LOG_LEVEL")
+		}
+		logLevel = parsed
+	}
+
+	var formatter logrus.Formatter
+	switch format {
+	case "text":
+		formatter = &logrus.TextFormatter{}
+	case "json":
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return errors.Errorf("unrecognized BLOCKCHAIN_LOG_FORMAT '%v'", format)
+	}
+
+	logger.SetLevel(logLevel)
+	logger.Formatter = formatter
+	return nil
+}
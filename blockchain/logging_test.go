@@ -0,0 +1,38 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureLogger(t *testing.T) {
+	out, level, formatter := logger.Out, logger.Level, logger.Formatter
+	defer func() {
+		logger.Out, logger.Level, logger.Formatter = out, level, formatter
+	}()
+
+	buf := &bytes.Buffer{}
+	logger.Out = buf
+
+	require.NoError(t, configureLogger("info", "json"))
+	log.Debug("suppressed")
+	assert.Empty(t, buf.String())
+
+	log.WithField("jobAddress", "0x1234").Info("logged")
+	assert.Contains(t, buf.String(), `"component":"blockchain"`)
+	assert.Contains(t, buf.String(), `"jobAddress":"0x1234"`)
+
+	// Without a level of its own the processor follows the daemon's
+	daemonLevel := logrus.GetLevel()
+	defer logrus.SetLevel(daemonLevel)
+	logrus.SetLevel(logrus.WarnLevel)
+	require.NoError(t, configureLogger("", "text"))
+	assert.Equal(t, logrus.WarnLevel, logger.Level)
+
+	assert.Error(t, configureLogger("loud", "text"))
+	assert.Error(t, configureLogger("info", "xml"))
+}
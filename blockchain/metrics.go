@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	eventsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "events_processed_total",
+		Help:      "Number of agent job events applied to the db, by event.",
+	}, []string{"event"})
+	jobsCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "jobs_completed_total",
+		Help:      "Number of jobs seen completed on chain, by completedBy (daemon or other).",
+	}, []string{"completedBy"})
+	jobsQueued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "jobs_queued_total",
+		Help:      "Number of jobs submitted to the job completion queue.",
+	})
+	completionQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "completion_queue_depth",
+		Help:      "Number of jobs waiting in the job completion queue.",
+	})
+	completionTransactions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "completion_transactions_total",
+		Help:      "Number of CompleteJob transactions sent, including gas price replacements.",
+	})
+	completionFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "completion_failures_total",
+		Help:      "Number of job completions abandoned due to an error.",
+	})
+	completionReceipts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "completion_receipts_total",
+		Help:      "Number of CompleteJob transactions mined, by txStatus (success or reverted).",
+	}, []string{"txStatus"})
+	completionQueueLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "completion_queue_latency_seconds",
+		Help:      "Time jobs wait in the job completion queue before being submitted.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+	completionConfirmationLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "completion_confirmation_latency_seconds",
+		Help:      "Time from sending a CompleteJob transaction to it being mined, including gas price replacements.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	})
+	lastBlockHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "last_block",
+		Help:      "Last block processed for job events.",
+	})
+	blocksBehind = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "blocks_behind",
+		Help:      "Number of confirmed blocks not yet scanned for job events, as of the last poll.",
+	})
+	lowBalance = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "low_balance",
+		Help:      "1 if the signing account's balance was too low for the last job completion transaction, else 0.",
+	})
+	circuitBreakerState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "snetd",
+		Subsystem: "blockchain",
+		Name:      "circuit_breaker_state",
+		Help:      "State of the eth RPC circuit breaker around event polling: 0 closed, 1 open, 2 half-open.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(eventsProcessed, jobsCompleted, jobsQueued, completionQueueDepth, completionTransactions,
+		completionFailures, completionReceipts, completionQueueLatency, completionConfirmationLatency, lastBlockHeight,
+		blocksBehind, lowBalance, circuitBreakerState)
+}
+
+// serveMetrics exposes the registered metrics at /metrics on the given address
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.WithField("address", address).Debug("starting metrics listener")
+	if err := http.ListenAndServe(address, mux); err != nil {
+		log.WithError(err).Error("error serving metrics")
+	}
+}
@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// multicallAggregateID is the selector of aggregate((address,bytes)[]) on the Multicall contract, which makes each
+// call in turn and reverts unless all of them succeed
+var multicallAggregateID = crypto.Keccak256([]byte("aggregate((address,bytes)[])"))[:4]
+
+// multicallCall is a single call made by Multicall's aggregate
+type multicallCall struct {
+	target   common.Address
+	callData []byte
+}
+
+// packAggregate ABI-encodes an aggregate call. The pinned go-ethereum can't pack tuples, so the encoding is built by
+// hand: the offset of the call array, its length, the offset of each call relative to the first, then each call as
+// its target, the offset of its call data and the length-prefixed, padded call data.
+func packAggregate(calls []multicallCall) []byte {
+	word := func(n int) []byte { return common.LeftPadBytes(big.NewInt(int64(n)).Bytes(), 32) }
+
+	tuples := make([][]byte, len(calls))
+	for i, call := range calls {
+		tuple := append(common.LeftPadBytes(call.target.Bytes(), 32), word(64)...)
+		tuple = append(tuple, word(len(call.callData))...)
+		tuples[i] = append(tuple, common.RightPadBytes(call.callData, (len(call.callData)+31)/32*32)...)
+	}
+
+	data := append(append([]byte{}, multicallAggregateID...), word(32)...)
+	data = append(data, word(len(calls))...)
+	offset := 32 * len(calls)
+	for _, tuple := range tuples {
+		data = append(data, word(offset)...)
+		offset += len(tuple)
+	}
+	for _, tuple := range tuples {
+		data = append(data, tuple...)
+	}
+	return data
+}
+
+// submitJobCompletionBatch sends the CompleteJob calls of several jobs in one Multicall aggregate transaction and waits
+// for it to be mined, reporting its hash to each job. The agents must accept completions from the Multicall contract,
+// as they are not made by the daemon's account directly.
+//
+// As with a single completion, an error means the jobs' completions weren't made, so the caller can submit them one by
+// one instead, except for errCompletionUnconfirmed for a transaction sent but not mined in time and
+// errSubmissionCancelled for one cut short by ctx. A job with a completion transaction already sent is completed on its
+// own, so the transaction is waited on rather than sent again in the batch.
+func (p Processor) submitJobCompletionBatch(ctx context.Context, a abi.ABI, jobInfos []*jobInfo) error {
+	log := log.WithField("jobs", len(jobInfos)).WithField("multicallAddress", p.multicallAddress.Hex())
+
+	calls := make([]multicallCall, len(jobInfos))
+	jobAddresses := make([][]byte, len(jobInfos))
+	for i, jobInfo := range jobInfos {
+		if p.completionSent(jobInfo.jobAddressBytes) {
+			return errors.Errorf("job %v already has a completion transaction sent",
+				common.BytesToAddress(jobInfo.jobAddressBytes).Hex())
+		}
+		v, r, s, err := parseSignature(jobInfo.jobSignatureBytes)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing signature of job %v",
+				common.BytesToAddress(jobInfo.jobAddressBytes).Hex())
+		}
+
+		callData, err := a.Pack("completeJob", common.BytesToAddress(jobInfo.jobAddressBytes), v, r, s)
+		if err != nil {
+			return errors.Wrap(err, "error packing completeJob call")
+		}
+		agent, err := p.agentFor(jobInfo.agentAddressBytes)
+		if err != nil {
+			return errors.Wrapf(err, "error finding agent contract of job %v",
+				common.BytesToAddress(jobInfo.jobAddressBytes).Hex())
+		}
+		calls[i] = multicallCall{target: agent.address, callData: callData}
+		jobAddresses[i] = jobInfo.jobAddressBytes
+	}
+	input := packAggregate(calls)
+
+	gasLimit := p.jobCompletionGasLimit * uint64(len(calls))
+	if gasLimit == 0 {
+		estimated, err := p.estimateGasLimit(ctx, p.multicallAddress, input)
+		if err != nil {
+			return errors.Wrap(err, "error estimating gas to complete jobs")
+		}
+		gasLimit = estimated
+	}
+
+	// Unlike a contract binding, a hand-built transaction can't leave the gas price to go-ethereum
+	gasPrice, err := p.completeJobGasPrice(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error determining gas price to complete jobs")
+	}
+
+	if p.dryRun {
+		log.WithField("input", hex.EncodeToString(input)).
+			WithField("gasLimit", gasLimit).
+			WithField("gasPrice", gasPrice).
+			Info("dry run; not submitting transaction to complete jobs")
+		p.recordCompletionDryRun(jobAddresses...)
+		for _, jobInfo := range jobInfos {
+			jobInfo.report(common.Hash{}, nil)
+		}
+		return nil
+	}
+
+	if err := p.checkBalance(ctx, gasLimit, gasPrice); err != nil {
+		return err
+	}
+
+	nonce, err := p.nonces.next(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error determining nonce to complete jobs")
+	}
+
+	from := common.HexToAddress(p.address)
+	txn, err := signerFn(p.signer)(types.HomesteadSigner{}, from,
+		types.NewTransaction(nonce, p.multicallAddress, big.NewInt(0), gasLimit, gasPrice, input))
+	if err == nil {
+		err = p.sendWithRetry(ctx, func(ctx context.Context) error { return p.ethClient.SendTransaction(ctx, txn) })
+	}
+	p.nonces.release(nonce)
+	if err != nil {
+		// The nonce was not consumed; resync with the node before the next submission
+		p.nonces.reset()
+		return errors.Wrap(err, "error submitting transaction to complete jobs")
+	}
+	completionTransactions.Inc()
+	submittedAt := time.Now()
+	log.WithField("txHash", txn.Hash().Hex()).WithField("nonce", nonce).Info("submitted transaction to complete jobs")
+	p.recordCompletionTx(txn.Hash(), jobAddresses...)
+
+	// The hash is only reported once the batch can no longer fall back to single submission
+	report := func() {
+		for _, jobInfo := range jobInfos {
+			jobInfo.report(txn.Hash(), nil)
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, p.confirmationTimeout)
+	defer cancel()
+
+	receipt, err := p.waitMined(waitCtx, []common.Hash{txn.Hash()})
+	if err != nil && ctx.Err() != nil {
+		log.WithField("txHash", txn.Hash().Hex()).
+			Warn("submission cancelled; no longer waiting for transaction to complete jobs")
+		report()
+		return errSubmissionCancelled
+	}
+	if err != nil {
+		log.WithError(err).WithField("txHash", txn.Hash().Hex()).
+			Warn("transaction to complete jobs not mined before timeout; leaving it to the old job scan")
+		report()
+		return errCompletionUnconfirmed
+	}
+
+	confirmationLatency := time.Since(submittedAt)
+	completionConfirmationLatency.Observe(confirmationLatency.Seconds())
+	logReceipt(log.WithField("confirmationLatency", confirmationLatency), receipt)
+
+	// Multicall reverts the whole batch if any completion fails, leaving every job open on chain
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return errors.Errorf("transaction %v to complete jobs reverted", receipt.TxHash.Hex())
+	}
+	p.recordCompletionMined(receipt.blockNumber, jobAddresses...)
+	report()
+	return nil
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackAggregate(t *testing.T) {
+	assert.Equal(t, "252dba42", hex.EncodeToString(multicallAggregateID))
+
+	target := common.HexToAddress("0xaa")
+	expected := "252dba42" +
+		// offset of the call array, and its length
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		// offset of the first call
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		// its target, the offset of its call data, and the call data
+		"00000000000000000000000000000000000000000000000000000000000000aa" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0102000000000000000000000000000000000000000000000000000000000000"
+	assert.Equal(t, expected, hex.EncodeToString(packAggregate([]multicallCall{{target: target,
+		callData: []byte{1, 2}}})))
+}
+
+// newBatchingProcessor returns a processor over node that completes up to three jobs per transaction, with three
+// jobs queued
+func newBatchingProcessor(t *testing.T, node *FakeNode) (Processor, []*jobInfo, func()) {
+	boltDB, cleanup := newTestDB(t)
+
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+		test.inFlight
+	p.ctx = test.ctx
+	p.batchCompletionSize = 3
+	p.multicallAddress = common.HexToAddress("0xca11")
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobInfos := []*jobInfo{
+		{jobAddressBytes: common.HexToAddress("0x01").Bytes(), jobSignatureBytes: signature},
+		{jobAddressBytes: common.HexToAddress("0x02").Bytes(), jobSignatureBytes: signature},
+		{jobAddressBytes: common.HexToAddress("0x03").Bytes(), jobSignatureBytes: signature},
+	}
+	for _, jobInfo := range jobInfos {
+		p.enqueueJobCompletion(jobInfo)
+	}
+
+	return p, jobInfos, func() {
+		cancel()
+		cleanup()
+	}
+}
+
+func TestBatchedJobCompletion(t *testing.T) {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p, jobInfos, cleanup := newBatchingProcessor(t, node)
+	defer cleanup()
+
+	close(p.jobCompletionQueue)
+	p.processJobCompletions()
+
+	sent := node.sentTransactions()
+	require.Len(t, sent, 1)
+	assert.Equal(t, p.multicallAddress, *sent[0].To())
+	assert.Equal(t, 3*p.jobCompletionGasLimit, sent[0].Gas())
+	assert.True(t, bytes.HasPrefix(sent[0].Data(), multicallAggregateID))
+
+	// Every job's completeJob call is made through the agent
+	for _, jobInfo := range jobInfos {
+		v, r, s, err := parseSignature(jobInfo.jobSignatureBytes)
+		require.NoError(t, err)
+		callData, err := a.Pack("completeJob", common.BytesToAddress(jobInfo.jobAddressBytes), v, r, s)
+		require.NoError(t, err)
+		assert.True(t, bytes.Contains(sent[0].Data(), callData))
+	}
+
+	entries, err := db.ListOutbox(p.boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestBatchedJobCompletionFallback(t *testing.T) {
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p, jobInfos, cleanup := newBatchingProcessor(t, node)
+	defer cleanup()
+	node.revertTo = &p.multicallAddress
+
+	close(p.jobCompletionQueue)
+	p.processJobCompletions()
+
+	// The reverted batch is followed by a CompleteJob transaction per job
+	sent := node.sentTransactions()
+	require.Len(t, sent, 1+len(jobInfos))
+	assert.Equal(t, p.multicallAddress, *sent[0].To())
+	for _, txn := range sent[1:] {
+		assert.Equal(t, p.agents[0].address, *txn.To())
+	}
+
+	entries, err := db.ListOutbox(p.boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// nonceTracker hands out sequential account nonces for transactions sent from the daemon's address. The pending nonce
+// is read from the node once and then incremented locally, so back-to-back submissions never reuse a nonce before the
+// previous transaction is mined. It is safe for concurrent use.
+type nonceTracker struct {
+	mutex   sync.Mutex
+	client  EthereumClient
+	address common.Address
+	nonce   uint64
+	synced  bool
+	// sending holds the nonces handed out whose transactions the node has neither taken nor refused yet
+	sending map[uint64]struct{}
+}
+
+func newNonceTracker(client EthereumClient, address common.Address) *nonceTracker {
+	return &nonceTracker{client: client, address: address, sending: make(map[uint64]struct{})}
+}
+
+// next returns the nonce to use for the next transaction, fetching the pending nonce from the node if the tracker has
+// not been synced yet (or was reset)
+func (t *nonceTracker) next(ctx context.Context) (uint64, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.synced {
+		nonce, err := t.client.PendingNonceAt(ctx, t.address)
+		if err != nil {
+			return 0, err
+		}
+		// The node's pending nonce doesn't count transactions still on their way to it, e.g. from another completion
+		// worker, so it is never taken below a nonce handed out for one
+		for sending := range t.sending {
+			if sending >= nonce {
+				nonce = sending + 1
+			}
+		}
+		t.nonce = nonce
+		t.synced = true
+	}
+
+	nonce := t.nonce
+	t.nonce++
+	t.sending[nonce] = struct{}{}
+	return nonce, nil
+}
+
+// release marks the transaction sent under a nonce from next as taken or refused by the node. It must be called once
+// for every nonce handed out, as until then a resync stays above it.
+func (t *nonceTracker) release(nonce uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.sending, nonce)
+}
+
+// reset discards the locally tracked nonce so the next call to next re-reads it from the node. It should be called
+// whenever a transaction using a handed-out nonce was not accepted.
+func (t *nonceTracker) reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.synced = false
+}
@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NonceNode reports nonce as the pending nonce of every account
+type NonceNode struct {
+	mutex sync.Mutex
+	nonce uint64
+}
+
+func (n *NonceNode) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return hexutil.Uint64(n.nonce)
+}
+
+// accept takes a transaction sent under nonce, moving the pending nonce past it
+func (n *NonceNode) accept(nonce uint64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if nonce >= n.nonce {
+		n.nonce = nonce + 1
+	}
+}
+
+func TestNonceTracker(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &NonceNode{nonce: 7}))
+	tracker := newNonceTracker(ethclient.NewClient(rpc.DialInProc(server)), common.HexToAddress("0x5e1f"))
+
+	// Nonces are handed out in sequence from the node's pending nonce
+	for _, expected := range []uint64{7, 8, 9} {
+		nonce, err := tracker.next(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, expected, nonce)
+		tracker.release(nonce)
+	}
+
+	// Concurrent submissions never share a nonce
+	var wg sync.WaitGroup
+	nonces := make(chan uint64, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if nonce, err := tracker.next(context.Background()); err == nil {
+				tracker.release(nonce)
+				nonces <- nonce
+			}
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+	seen := map[uint64]bool{}
+	for nonce := range nonces {
+		assert.False(t, seen[nonce], "nonce %v handed out twice", nonce)
+		seen[nonce] = true
+	}
+	assert.Len(t, seen, 20)
+
+	// After a reset the next nonce is read from the node again
+	tracker.reset()
+	nonce, err := tracker.next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), nonce)
+}
+
+func TestNonceTrackerResetWhileSending(t *testing.T) {
+	node := &NonceNode{nonce: 7}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", node))
+	tracker := newNonceTracker(ethclient.NewClient(rpc.DialInProc(server)), common.HexToAddress("0x5e1f"))
+
+	// A reset while a transaction is still on its way to the node doesn't hand its nonce out again
+	sending, err := tracker.next(context.Background())
+	require.NoError(t, err)
+	failed, err := tracker.next(context.Background())
+	require.NoError(t, err)
+	tracker.release(failed)
+	tracker.reset()
+	nonce, err := tracker.next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), nonce)
+	tracker.release(sending)
+	tracker.release(nonce)
+
+	// Concurrent senders, every third of which has its transaction refused and resets the tracker; each transaction
+	// the node takes has a nonce of its own
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	accepted := map[uint64]int{}
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nonce, err := tracker.next(context.Background())
+			if !assert.NoError(t, err) {
+				return
+			}
+			time.Sleep(time.Millisecond)
+			if i%3 == 0 {
+				tracker.release(nonce)
+				tracker.reset()
+				return
+			}
+			node.accept(nonce)
+			tracker.release(nonce)
+			mutex.Lock()
+			accepted[nonce]++
+			mutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	for nonce, times := range accepted {
+		assert.Equal(t, 1, times, "nonce %v taken %v times", nonce, times)
+	}
+	assert.Len(t, accepted, 20)
+}
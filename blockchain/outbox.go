@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"encoding/json"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// putOutbox records a job completion as queued so it is replayed if the daemon stops before the completion resolves.
+// Failing to record it only costs durability, so errors are logged rather than returned.
+func (p Processor) putOutbox(jobInfo *jobInfo) {
+	entryBytes, err := json.Marshal(db.OutboxEntry{
+		JobAddress:   jobInfo.jobAddressBytes,
+		JobSignature: jobInfo.jobSignatureBytes,
+		AgentAddress: jobInfo.agentAddressBytes,
+	})
+	if err == nil {
+		err = p.boltDB.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(db.OutboxBucketName).Put(jobInfo.jobAddressBytes, entryBytes)
+		})
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("jobAddress", common.BytesToAddress(jobInfo.jobAddressBytes).Hex()).
+			Error("error recording queued job completion in outbox")
+	}
+}
+
+// deleteOutbox removes a job completion from the outbox once it has been sent or has failed for good
+func (p Processor) deleteOutbox(jobAddressBytes []byte) {
+	if err := p.boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.OutboxBucketName).Delete(jobAddressBytes)
+	}); err != nil {
+		log.WithError(err).WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+			Error("error deleting resolved job completion from outbox")
+	}
+}
+
+// replayOutbox queues the job completions a previous run left unresolved in the outbox. A completion whose transaction
+// was sent before the previous run stopped, and has since been mined, is resolved instead of being sent again; one the
+// node still has pending is waited on once the job reaches the front of the queue, and only signed again, under a new
+// nonce, when the node no longer knows it.
+func (p Processor) replayOutbox() {
+	entries, err := db.ListOutbox(p.boltDB)
+	if err != nil {
+		log.WithError(errors.Wrap(err, "error replaying outbox")).Error("error replaying job completions")
+		return
+	}
+
+	var jobInfos []*jobInfo
+	for _, entry := range entries {
+		log := log.WithField("jobAddress", common.BytesToAddress(entry.JobAddress).Hex())
+		if p.completionMined(entry.JobAddress) {
+			log.Info("job completion in outbox already mined; not replaying")
+			p.deleteOutbox(entry.JobAddress)
+			continue
+		}
+
+		log.Debug("replaying job completion from outbox")
+		jobInfos = append(jobInfos, &jobInfo{jobAddressBytes: entry.JobAddress, jobSignatureBytes: entry.JobSignature,
+			agentAddressBytes: entry.AgentAddress})
+	}
+
+	p.enqueueJobCompletions(jobInfos)
+}
+
+// completionMined reports whether the last completion transaction sent for the job is known to have been mined
+// successfully, recording it as mined if its receipt is only found now. A job without a transaction, or whose receipt
+// can't be retrieved, counts as not mined.
+func (p Processor) completionMined(jobAddressBytes []byte) bool {
+	job, err := db.GetJob(p.boltDB, jobAddressBytes)
+	if err != nil || job == nil || job.CompletionTxHash == nil {
+		return false
+	}
+	if job.CompletionMinedAtBlock != nil {
+		return true
+	}
+
+	ctx, cancel := p.rpcContext()
+	defer cancel()
+	receipt, err := p.transactionReceipt(ctx, common.BytesToHash(job.CompletionTxHash))
+	if err != nil || receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+		return false
+	}
+	p.recordCompletionMined(receipt.blockNumber, jobAddressBytes)
+	return true
+}
@@ -0,0 +1,225 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayOutboxAfterCrash(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// Jobs are queued, then the daemon dies before the completion loop gets to them
+	crashed, cancel := newTestProcessor(boltDB)
+	queued := []*jobInfo{
+		{jobAddressBytes: common.HexToAddress("0x01").Bytes(), jobSignatureBytes: []byte{1},
+			agentAddressBytes: common.HexToAddress("0xa1").Bytes()},
+		{jobAddressBytes: common.HexToAddress("0x02").Bytes(), jobSignatureBytes: []byte{2}},
+	}
+	for _, jobInfo := range queued {
+		crashed.enqueueJobCompletion(jobInfo)
+	}
+	cancel()
+
+	restarted, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	restarted.replayOutbox()
+
+	require.Len(t, restarted.jobCompletionQueue, len(queued))
+	replayed := map[common.Address]*jobInfo{}
+	for range queued {
+		jobInfo := <-restarted.jobCompletionQueue
+		replayed[common.BytesToAddress(jobInfo.jobAddressBytes)] = jobInfo
+	}
+	for _, jobInfo := range queued {
+		replayedInfo := replayed[common.BytesToAddress(jobInfo.jobAddressBytes)]
+		require.NotNil(t, replayedInfo)
+
+		// The replayed job is enqueued afresh, so only its enqueue time differs
+		assert.False(t, replayedInfo.enqueuedAt.Before(jobInfo.enqueuedAt))
+		replayedInfo.enqueuedAt = jobInfo.enqueuedAt
+		assert.Equal(t, jobInfo, replayedInfo)
+	}
+}
+
+func TestOutboxClearedOnCompletion(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful}
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+		test.inFlight
+	p.ctx = test.ctx
+
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	jobAddress := common.HexToAddress("0x1234").Bytes()
+	p.enqueueJobCompletion(&jobInfo{jobAddressBytes: jobAddress, jobSignatureBytes: signature})
+
+	entries, err := db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// With the queue closed the completion loop returns once it has handled the queued job
+	close(p.jobCompletionQueue)
+	p.processJobCompletions()
+	assert.Len(t, node.sentTransactions(), 1)
+
+	entries, err = db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestOutboxKeptOnShutdown(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, unmined: true}
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+		test.inFlight
+	p.ctx, p.confirmationTimeout = test.ctx, time.Minute
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, test, map[common.Address]string{jobAddress: jobFundedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	p.enqueueJobCompletion(&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: job.JobSignature})
+
+	// The daemon stops while the sent transaction waits to be mined
+	close(p.jobCompletionQueue)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	p.processJobCompletions()
+	require.Len(t, node.sentTransactions(), 1)
+	entries, err := db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Mined by the time the daemon restarts, the completion is resolved rather than sent again
+	node.mutex.Lock()
+	node.unmined = false
+	node.mutex.Unlock()
+	restarted, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	restarted.rawClient = p.rawClient
+	restarted.replayOutbox()
+	assert.Empty(t, restarted.jobCompletionQueue)
+	entries, err = db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job.CompletionMinedAtBlock)
+	assert.Equal(t, uint64(2), *job.CompletionMinedAtBlock)
+}
+
+func TestOutboxKeptWhenUnconfirmed(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, unmined: true}
+	p := newFakeNodeProcessor(t, node)
+	test, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.boltDB, p.inFlight, p.ctx, p.confirmationTimeout = boltDB, test.inFlight, test.ctx, 200*time.Millisecond
+
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, test, map[common.Address]string{jobAddress: jobFundedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	jobInfo := &jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: job.JobSignature}
+
+	// The transaction isn't mined in time, so the job stays in the outbox with it but leaves the in-flight set
+	assertUnconfirmed := func() {
+		require.True(t, p.inFlight.add(jobAddress.Bytes()))
+		p.putOutbox(jobInfo)
+		p.processJobCompletion(testAgentABI, jobInfo)
+
+		sent := node.sentTransactions()
+		require.Len(t, sent, 1)
+		entries, err := db.ListOutbox(boltDB)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+		job, err := db.GetJob(boltDB, jobAddress.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, sent[0].Hash().Bytes(), job.CompletionTxHash)
+	}
+	assertUnconfirmed()
+
+	// Picked up again while still pending, the transaction is waited on rather than sent again under a new nonce
+	assertUnconfirmed()
+
+	// Once mined the completion resolves without another transaction
+	node.mutex.Lock()
+	node.unmined = false
+	node.mutex.Unlock()
+	require.True(t, p.inFlight.add(jobAddress.Bytes()))
+	p.processJobCompletion(testAgentABI, jobInfo)
+	assert.Len(t, node.sentTransactions(), 1)
+	entries, err := db.ListOutbox(boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job.CompletionMinedAtBlock)
+	assert.Equal(t, uint64(2), *job.CompletionMinedAtBlock)
+}
+
+func TestReplayOutboxWaitsOnSentTransaction(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// The transaction sent before the daemon stops is still pending when it restarts
+	node := &FakeNode{receiptStatus: types.ReceiptStatusSuccessful, unmined: true}
+	newProcessor := func() (Processor, func()) {
+		p := newFakeNodeProcessor(t, node)
+		test, cancel := newTestProcessor(boltDB)
+		p.boltDB, p.jobCompletionQueue, p.queueMutex, p.inFlight = boltDB, test.jobCompletionQueue, test.queueMutex,
+			test.inFlight
+		p.ctx, p.confirmationTimeout = test.ctx, 200*time.Millisecond
+		return p, cancel
+	}
+	crashed, cancel := newProcessor()
+	jobAddress := common.HexToAddress("0x1234")
+	putCompletedJobs(t, crashed, map[common.Address]string{jobAddress: jobFundedState})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	crashed.enqueueJobCompletion(&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: job.JobSignature})
+	crashed.processJobCompletion(testAgentABI, <-crashed.jobCompletionQueue)
+	cancel()
+	require.Len(t, node.sentTransactions(), 1)
+
+	restarted, cancel := newProcessor()
+	defer cancel()
+	replay := func() {
+		restarted.replayOutbox()
+		require.Len(t, restarted.jobCompletionQueue, 1)
+		restarted.processJobCompletion(testAgentABI, <-restarted.jobCompletionQueue)
+	}
+
+	// The replayed completion waits on the transaction while the node knows it, rather than signing another
+	replay()
+	assert.Len(t, node.sentTransactions(), 1)
+
+	// Once the node has forgotten it, the completion is signed again, here at a higher gas price
+	node.mutex.Lock()
+	node.dropped, node.gasPrice = true, big.NewInt(2)
+	node.mutex.Unlock()
+	replay()
+	sent := node.sentTransactions()
+	require.Len(t, sent, 2)
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sent[1].Hash().Bytes(), job.CompletionTxHash)
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// pendingFunding is a JobFunded event seen in the node's pending block, which may yet be mined or dropped
+type pendingFunding struct {
+	// block is the number the pending block had when the funding was last seen in it
+	block  *big.Int
+	amount *big.Int
+}
+
+// pendingFundings tracks the jobs funded in the pending block but not yet confirmed, so their invocations can be
+// served before the funding is mined. It is safe for concurrent use, and a nil pendingFundings tracks none.
+type pendingFundings struct {
+	mutex sync.RWMutex
+	jobs  map[common.Address]pendingFunding
+}
+
+func newPendingFundings() *pendingFundings {
+	return &pendingFundings{jobs: map[common.Address]pendingFunding{}}
+}
+
+// lookup returns the pending funding of job, if one has been seen
+func (f *pendingFundings) lookup(job common.Address) (pendingFunding, bool) {
+	if f == nil {
+		return pendingFunding{}, false
+	}
+
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	funding, ok := f.jobs[job]
+	return funding, ok
+}
+
+// scanPending rescans the pending block for JobFunded events every poll interval
+func (p Processor) scanPending() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(p.pollInterval.current()):
+		}
+
+		recoverPanic("pending funding scan", func() {
+			if err := p.scanPendingFundings(); err != nil {
+				log.WithError(err).Warn("error scanning pending block for job fundings")
+			}
+		})
+	}
+}
+
+// scanPendingFundings records the JobFunded events in the node's pending block and reconciles the fundings seen
+// before. A funding no longer pending is dropped once the event cursor shows it confirmed, or once the cursor has
+// passed the block it was pending in without it, in which case the funding was never mined and a loud warning is
+// logged, since the job may have been served and even completed against it.
+func (p Processor) scanPendingFundings() error {
+	ctx, cancel := p.rpcContext()
+	defer cancel()
+
+	var header struct {
+		Number *hexutil.Big `json:"number"`
+	}
+	if err := p.rawClient.CallContext(ctx, &header, "eth_getBlockByNumber", "pending", false); err != nil {
+		return errors.Wrap(err, "error retrieving pending block")
+	}
+	if header.Number == nil {
+		return errors.New("pending block not found")
+	}
+	pendingBlock := (*big.Int)(header.Number)
+
+	// Pending logs have no block hash or number, which types.Log leaves empty
+	var jobLogs []types.Log
+	if err := p.rawClient.CallContext(ctx, &jobLogs, "eth_getLogs", map[string]interface{}{
+		"fromBlock": "pending",
+		"toBlock":   "pending",
+		"address":   p.agentAddresses(),
+		"topics":    [][]common.Hash{{p.events.jobFundedID}},
+	}); err != nil {
+		return errors.Wrap(err, "error retrieving pending job events")
+	}
+
+	seen := map[common.Address]pendingFunding{}
+	for _, jobLog := range jobLogs {
+		if len(jobLog.Topics) == 0 || jobLog.Topics[0] != p.events.jobFundedID {
+			continue
+		}
+		data, err := p.events.decode("JobFunded", jobLog)
+		if err != nil {
+			log.WithError(err).WithField("txHash", jobLog.TxHash.Hex()).Warn("error decoding pending JobFunded event")
+			continue
+		}
+		seen[data.Job] = pendingFunding{block: pendingBlock, amount: data.Amount}
+	}
+
+	lastBlock, err := p.LastProcessedBlock()
+	if err != nil {
+		return err
+	}
+
+	p.pendingFundings.mutex.Lock()
+	previous := p.pendingFundings.jobs
+	p.pendingFundings.mutex.Unlock()
+
+	updated := map[common.Address]pendingFunding{}
+	for job, funding := range previous {
+		if _, ok := seen[job]; ok {
+			continue
+		}
+		log := log.WithField("jobAddress", job.Hex()).WithField("pendingBlock", funding.block)
+		switch {
+		case p.jobFundedInDB(job):
+			log.Info("pending job funding confirmed")
+		case lastBlock != nil && lastBlock.Cmp(funding.block) >= 0:
+			log.Warn("ACT_ON_PENDING: job funding seen pending was never confirmed; the job may have been served " +
+				"unfunded")
+		default:
+			// Mined but not yet confirmed, or dropped back into the pool; the cursor settles which
+			updated[job] = funding
+		}
+	}
+	for job, funding := range seen {
+		if _, ok := previous[job]; !ok {
+			log.WithField("jobAddress", job.Hex()).WithField("pendingBlock", funding.block).
+				Info("job funding seen in pending block")
+		}
+		updated[job] = funding
+	}
+
+	p.pendingFundings.mutex.Lock()
+	p.pendingFundings.jobs = updated
+	p.pendingFundings.mutex.Unlock()
+	return nil
+}
+
+// jobFundedInDB reports whether the db holds the job as funded or past funding, i.e. its funding has been confirmed
+func (p Processor) jobFundedInDB(job common.Address) bool {
+	funded := false
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		jobBytes := tx.Bucket(db.JobBucketName).Get(job.Bytes())
+		if jobBytes == nil {
+			return nil
+		}
+		record := &db.Job{}
+		if err := json.Unmarshal(jobBytes, record); err == nil {
+			funded = record.JobState != "" && record.JobState != jobPendingState
+		}
+		return nil
+	})
+	return funded
+}
+
+// fundedPending reports whether job has a pending funding and was created by consumer, so its invocation can be
+// served before the funding is confirmed
+func (p Processor) fundedPending(job *db.Job, consumer common.Address) bool {
+	if len(job.Consumer) == 0 || !bytes.Equal(consumer.Bytes(), job.Consumer) {
+		return false
+	}
+	_, ok := p.pendingFundings.lookup(common.BytesToAddress(job.JobAddress))
+	return ok
+}
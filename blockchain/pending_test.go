@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActOnPending(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.agents[0].sigHasher = func(i []byte) []byte { return crypto.Keccak256(i) }
+	p.pendingFundings = newPendingFundings()
+	raw := fakeRawCaller{"eth_getBlockByNumber": map[string]string{"number": "0xb"}}
+	p.rawClient = raw
+
+	consumerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	consumer := crypto.PubkeyToAddress(consumerKey.PublicKey)
+	jobAddress, droppedAddress := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	funded := func(job common.Address) types.Log {
+		return types.Log{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(job)}
+	}
+	signature, err := crypto.Sign(p.agents[0].sigHasher(jobAddress.Bytes()), consumerKey)
+	require.NoError(t, err)
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...)},
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(droppedAddress), word(consumer)...)},
+	}, big.NewInt(10), common.Hash{}))
+
+	// Both fundings are seen in pending block 11, and the created job is served on its pending funding
+	raw["eth_getLogs"] = []types.Log{funded(jobAddress), funded(droppedAddress)}
+	require.NoError(t, p.scanPendingFundings())
+	assert.True(t, p.IsValidJobInvocation(jobAddress.Bytes(), signature))
+
+	// Only the consumer's signature is accepted
+	created, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.False(t, p.fundedPending(created, common.HexToAddress("0xc2")))
+
+	// Block 11 is mined with only the first funding; until it is confirmed both stay tracked
+	raw["eth_getBlockByNumber"] = map[string]string{"number": "0xc"}
+	raw["eth_getLogs"] = []types.Log{}
+	require.NoError(t, p.scanPendingFundings())
+	_, ok := p.pendingFundings.lookup(jobAddress)
+	assert.True(t, ok)
+	_, ok = p.pendingFundings.lookup(droppedAddress)
+	assert.True(t, ok)
+
+	// Once block 11 is confirmed, the mined funding is reconciled with the db and the dropped one forgotten
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{funded(jobAddress)}, big.NewInt(11), common.Hash{}))
+	require.NoError(t, p.scanPendingFundings())
+	_, ok = p.pendingFundings.lookup(jobAddress)
+	assert.False(t, ok)
+	_, ok = p.pendingFundings.lookup(droppedAddress)
+	assert.False(t, ok)
+
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobFundedState, job.JobState)
+	assert.True(t, p.IsValidJobInvocation(jobAddress.Bytes(), signature))
+
+	dropped, err := db.GetJob(boltDB, droppedAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobPendingState, dropped.JobState)
+}
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// pollInterval adapts the sleep between event polls to chain activity: it halves after a poll that found new blocks,
+// down to floor, and doubles after one that found the cursor caught up, up to ceiling. With floor and ceiling equal to
+// the base interval it stays fixed. It is safe for concurrent use, and a nil pollInterval ignores adjustments.
+type pollInterval struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	floor    time.Duration
+	ceiling  time.Duration
+}
+
+func newPollInterval(base, floor, ceiling time.Duration) *pollInterval {
+	return &pollInterval{interval: base, floor: floor, ceiling: ceiling}
+}
+
+// current returns the sleep before the next poll
+func (i *pollInterval) current() time.Duration {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.interval
+}
+
+// advanced shortens the interval after a poll that scanned new blocks
+func (i *pollInterval) advanced() {
+	if i == nil {
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.interval /= 2; i.interval < i.floor {
+		i.interval = i.floor
+	}
+}
+
+// caughtUp lengthens the interval after a poll that had no new blocks to scan
+func (i *pollInterval) caughtUp() {
+	if i == nil {
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.interval *= 2; i.interval > i.ceiling {
+		i.interval = i.ceiling
+	}
+}
+
+// reset restarts adaptation from a new base interval and bounds, e.g. after the configuration is reloaded
+func (i *pollInterval) reset(base, floor, ceiling time.Duration) {
+	if i == nil {
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.interval, i.floor, i.ceiling = base, floor, ceiling
+}
+
+// jitter spreads d uniformly over d ± fraction of d, so daemons polling a shared node with the same interval drift
+// apart rather than polling in step; a zero fraction returns d as it is
+func jitter(d time.Duration, fraction float64) time.Duration {
+	spread := int64(float64(d) * fraction)
+	if spread <= 0 {
+		return d
+	}
+	return d - time.Duration(spread) + time.Duration(rand.Int63n(2*spread+1))
+}
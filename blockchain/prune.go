@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// jobPruneInterval is how often the db is swept for expired jobs
+const jobPruneInterval = time.Hour
+
+// pruneJobs periodically removes jobs that have sat pending or funded for longer than the job TTL, e.g. completed
+// locally but never confirmed on chain, with no completion left to wait on
+func (p Processor) pruneJobs() {
+	ticker := time.NewTicker(jobPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		recoverPanic("job pruning", func() {
+			if err := p.pruneExpiredJobs(time.Now().Add(-p.jobTTL)); err != nil {
+				log.WithError(err).Error("error pruning expired jobs")
+			}
+		})
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneExpiredJobs deletes pending and funded jobs last written before cutoff, along with their histories. A job whose
+// completion is still under way is kept however old it is: one in flight or in the outbox, with a completion
+// transaction sent that may yet be mined, or seen completed on chain and waiting for its event to be confirmed. Jobs
+// stored before timestamps were recorded are stamped now instead, so they expire a full TTL after the upgrade rather
+// than immediately.
+func (p Processor) pruneExpiredJobs(cutoff time.Time) error {
+	return p.boltDB.Update(func(tx *bolt.Tx) error {
+		bucket, outbox := tx.Bucket(db.JobBucketName), tx.Bucket(db.OutboxBucketName)
+
+		var expired [][]byte
+		stamped := make(map[string][]byte)
+		if err := bucket.ForEach(func(k, v []byte) error {
+			job := &db.Job{}
+			if err := json.Unmarshal(v, job); err != nil {
+				return nil
+			}
+			if job.JobState != jobPendingState && job.JobState != jobFundedState {
+				return nil
+			}
+			if job.CompletionTxHash != nil || job.CompletionMinedAtBlock != nil || job.CompletedAtBlock != nil ||
+				outbox.Get(k) != nil || p.inFlight.has(k) {
+				return nil
+			}
+
+			if job.UpdatedAt.IsZero() {
+				job.Touch(time.Now())
+				jobBytes, err := json.Marshal(job)
+				if err != nil {
+					return err
+				}
+				stamped[string(k)] = jobBytes
+			} else if job.UpdatedAt.Before(cutoff) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// The bucket can't be modified while iterating it
+		for k, jobBytes := range stamped {
+			if err := bucket.Put([]byte(k), jobBytes); err != nil {
+				return err
+			}
+		}
+		for _, k := range expired {
+			log.WithField("jobAddress", common.BytesToAddress(k).Hex()).Info("pruning expired job")
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			if err := db.DeleteJobHistory(tx, k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneExpiredJobs(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	now, old := time.Now(), time.Now().Add(-48*time.Hour)
+	block := uint64(10)
+	// Completed locally but never confirmed on chain, with nothing left to wait on
+	unconfirmed := db.Job{JobAddress: []byte{1}, JobState: jobFundedState, Completed: true, UpdatedAt: old}
+	fresh := db.Job{JobAddress: []byte{2}, JobState: jobPendingState, UpdatedAt: now}
+	legacy := db.Job{JobAddress: []byte{3}, JobState: jobPendingState}
+	// As old, but with a completion still under way
+	sent := db.Job{JobAddress: []byte{4}, JobState: jobFundedState, Completed: true, CompletionTxHash: []byte{4},
+		UpdatedAt: old}
+	mined := db.Job{JobAddress: []byte{5}, JobState: jobFundedState, Completed: true, CompletionMinedAtBlock: &block,
+		UpdatedAt: old}
+	completed := db.Job{JobAddress: []byte{6}, JobState: jobFundedState, Completed: true, CompletedAtBlock: &block,
+		UpdatedAt: old}
+	queued := db.Job{JobAddress: []byte{7}, JobState: jobFundedState, Completed: true, UpdatedAt: old}
+	inFlight := db.Job{JobAddress: []byte{8}, JobState: jobFundedState, Completed: true, UpdatedAt: old}
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		for _, job := range []db.Job{unconfirmed, fresh, legacy, sent, mined, completed, queued, inFlight} {
+			jobBytes, err := json.Marshal(job)
+			require.NoError(t, err)
+			require.NoError(t, tx.Bucket(db.JobBucketName).Put(job.JobAddress, jobBytes))
+			require.NoError(t, db.AppendJobHistory(tx, job.JobAddress, db.HistoryEntry{Timestamp: job.UpdatedAt,
+				ToState: job.JobState}))
+		}
+		return tx.Bucket(db.OutboxBucketName).Put(queued.JobAddress, []byte("{}"))
+	}))
+
+	p := Processor{boltDB: boltDB, inFlight: newInFlightJobs()}
+	p.inFlight.add(inFlight.JobAddress)
+	require.NoError(t, p.pruneExpiredJobs(now.Add(-24*time.Hour)))
+
+	// The pruned job's history goes with it
+	job, err := db.GetJob(boltDB, unconfirmed.JobAddress)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	entries, err := db.GetJobHistory(boltDB, unconfirmed.JobAddress)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	for _, kept := range []db.Job{fresh, sent, mined, completed, queued, inFlight} {
+		job, err = db.GetJob(boltDB, kept.JobAddress)
+		require.NoError(t, err)
+		assert.NotNil(t, job, "job %v pruned", kept.JobAddress)
+		entries, err = db.GetJobHistory(boltDB, kept.JobAddress)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	}
+
+	// Jobs without a timestamp are stamped rather than pruned
+	job, err = db.GetJob(boltDB, legacy.JobAddress)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.False(t, job.UpdatedAt.IsZero())
+}
@@ -0,0 +1,164 @@
+package blockchain
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// rateLimiter is a token bucket bounding the rate of node calls, so polls that make many calls stay within a hosted
+// provider's request limit. It refills at rate tokens per second up to a burst of one second's worth. It is safe for
+// concurrent use.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := math.Max(1, math.Ceil(rate))
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait takes a token, blocking until one is available or ctx is done. A call that has to wait reserves its token
+// up front, so concurrent callers are let through in turn at the configured rate.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mutex.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	deficit := -l.tokens
+	l.mutex.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedEthClient is an EthereumClient whose calls each wait on limiter first
+type rateLimitedEthClient struct {
+	EthereumClient
+	limiter *rateLimiter
+}
+
+func (c rateLimitedEthClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.FilterLogs(ctx, query)
+}
+
+func (c rateLimitedEthClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery,
+	ch chan<- types.Log) (ethereum.Subscription, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.SubscribeFilterLogs(ctx, query, ch)
+}
+
+func (c rateLimitedEthClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte,
+	error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.CodeAt(ctx, contract, blockNumber)
+}
+
+func (c rateLimitedEthClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte,
+	error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.CallContract(ctx, call, blockNumber)
+}
+
+func (c rateLimitedEthClient) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.PendingCodeAt(ctx, account)
+}
+
+func (c rateLimitedEthClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+	return c.EthereumClient.PendingNonceAt(ctx, account)
+}
+
+func (c rateLimitedEthClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.SuggestGasPrice(ctx)
+}
+
+func (c rateLimitedEthClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+	return c.EthereumClient.EstimateGas(ctx, call)
+}
+
+func (c rateLimitedEthClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := c.limiter.wait(ctx); err != nil {
+		return err
+	}
+	return c.EthereumClient.SendTransaction(ctx, tx)
+}
+
+func (c rateLimitedEthClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.HeaderByNumber(ctx, number)
+}
+
+func (c rateLimitedEthClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int,
+	error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.BalanceAt(ctx, account, blockNumber)
+}
+
+func (c rateLimitedEthClient) NetworkID(ctx context.Context) (*big.Int, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.EthereumClient.NetworkID(ctx)
+}
+
+// rateLimitedRawCaller is a RawCaller whose calls each wait on limiter first, sharing it with the processor's
+// rateLimitedEthClient so the limit holds across both
+type rateLimitedRawCaller struct {
+	RawCaller
+	limiter *rateLimiter
+}
+
+func (c rateLimitedRawCaller) CallContext(ctx context.Context, result interface{}, method string,
+	args ...interface{}) error {
+	if err := c.limiter.wait(ctx); err != nil {
+		return err
+	}
+	return c.RawCaller.CallContext(ctx, result, method, args...)
+}
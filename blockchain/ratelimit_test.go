@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedClients(t *testing.T) {
+	limiter := newRateLimiter(20)
+	ethClient := rateLimitedEthClient{&fakeEthClient{}, limiter}
+	rawClient := rateLimitedRawCaller{fakeRawCaller{"eth_blockNumber": "0x1"}, limiter}
+	query := ethereum.FilterQuery{FromBlock: big.NewInt(1), ToBlock: big.NewInt(1)}
+
+	// The first second's worth of calls go straight through
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		_, err := ethClient.FilterLogs(context.Background(), query)
+		require.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) < 200*time.Millisecond, "burst throttled: %v", time.Since(start))
+
+	// Past the burst, calls through either client share the limit of 20 per second
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		var block string
+		require.NoError(t, rawClient.CallContext(context.Background(), &block, "eth_blockNumber"))
+		_, err := ethClient.FilterLogs(context.Background(), query)
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 900*time.Millisecond, "20 calls took %v", elapsed)
+	assert.True(t, elapsed < 2*time.Second, "20 calls took %v", elapsed)
+
+	// A call waiting on the limiter gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	limiter = newRateLimiter(0.1)
+	limiter.tokens = 0
+	_, err := rateLimitedEthClient{&fakeEthClient{}, limiter}.HeaderByNumber(ctx, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
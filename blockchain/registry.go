@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// registryAgentAddressID is the selector of agentAddress(bytes32) on the registry contract, which returns the address
+// of the agent currently registered under an ID, or the zero address if there is none
+var registryAgentAddressID = crypto.Keccak256([]byte("agentAddress(bytes32)"))[:4]
+
+// agentRegistry tracks the agent registered under agentID in the registry contract at address. Every agent it has
+// resolved stays watched, so jobs created on an agent that has since been replaced are still followed and completed;
+// the last one resolved is the current agent. It is safe for concurrent use.
+type agentRegistry struct {
+	address  common.Address
+	agentID  [32]byte
+	interval time.Duration
+
+	mutex   sync.RWMutex
+	agents  []*agentContract
+	version uint64
+}
+
+func newAgentRegistry(address common.Address, agentID string, interval time.Duration) *agentRegistry {
+	r := &agentRegistry{address: address, interval: interval}
+	copy(r.agentID[:], agentID)
+	return r
+}
+
+// resolved returns the agents resolved so far, oldest first
+func (r *agentRegistry) resolved() []*agentContract {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.agents
+}
+
+// current returns the agent most recently resolved
+func (r *agentRegistry) current() *agentContract {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.agents[len(r.agents)-1]
+}
+
+// changedSince reports whether an agent has been resolved since the given version of the registry
+func (r *agentRegistry) changedSince(version uint64) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.version != version
+}
+
+// currentVersion returns the version of the registry, which changes whenever a new agent is resolved
+func (r *agentRegistry) currentVersion() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.version
+}
+
+// lookupRegistryAgent reads the address currently registered under the registry's agent ID
+func (p Processor) lookupRegistryAgent() (common.Address, error) {
+	ctx, cancel := p.rpcContext()
+	defer cancel()
+
+	result, err := p.ethClient.CallContract(ctx, ethereum.CallMsg{To: &p.registry.address,
+		Data: append(append([]byte{}, registryAgentAddressID...), p.registry.agentID[:]...)}, nil)
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "error calling agent registry")
+	}
+	if len(result) != 32 {
+		return common.Address{}, errors.Errorf("unexpected %v byte response from agent registry", len(result))
+	}
+
+	address := common.BytesToAddress(result)
+	if address == (common.Address{}) {
+		return common.Address{}, errors.Errorf("no agent registered under REGISTRY_AGENT_ID '%v'",
+			string(bytes.TrimRight(p.registry.agentID[:], "\x00")))
+	}
+	return address, nil
+}
+
+// refreshRegistryAgent resolves the agent registered in the registry, starting to watch it if it hasn't been resolved
+// before. A previously resolved agent being registered again becomes current without being bound a second time.
+func (p Processor) refreshRegistryAgent() error {
+	address, err := p.lookupRegistryAgent()
+	if err != nil {
+		return err
+	}
+
+	p.registry.mutex.RLock()
+	agents := p.registry.agents
+	p.registry.mutex.RUnlock()
+
+	if len(agents) > 0 && agents[len(agents)-1].address == address {
+		return nil
+	}
+
+	var agent *agentContract
+	for _, a := range agents {
+		if a.address == address {
+			agent = a
+		}
+	}
+	if agent == nil {
+		if agent, err = p.newAgentContract(address); err != nil {
+			return err
+		}
+	}
+
+	updated := make([]*agentContract, 0, len(agents)+1)
+	for _, a := range agents {
+		if a != agent {
+			updated = append(updated, a)
+		}
+	}
+
+	p.registry.mutex.Lock()
+	p.registry.agents = append(updated, agent)
+	p.registry.version++
+	p.registry.mutex.Unlock()
+
+	log.WithField("agentAddress", address.Hex()).
+		WithField("previouslyResolved", len(agents) > len(updated)).
+		Info("resolved agent contract from registry")
+	return nil
+}
+
+// refreshRegistry re-resolves the registered agent every registry refresh interval, so a migrated agent contract is
+// picked up without a restart. A failed refresh keeps the agents already resolved and is retried at the next interval.
+func (p Processor) refreshRegistry() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(p.registry.interval):
+		}
+
+		recoverPanic("agent registry refresh", func() {
+			if err := p.refreshRegistryAgent(); err != nil {
+				log.WithError(err).WithField("registryAddress", p.registry.address.Hex()).
+					Error("error refreshing agent contract from registry")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/singnet/snet-daemon/config"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry is a fakeEthClient whose registry contract calls return agent, and whose log queries are recorded
+type fakeRegistry struct {
+	fakeEthClient
+	mutex   sync.Mutex
+	agent   common.Address
+	calls   []ethereum.CallMsg
+	queries []ethereum.FilterQuery
+}
+
+func (f *fakeRegistry) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte,
+	error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = append(f.calls, call)
+	return common.LeftPadBytes(f.agent.Bytes(), 32), nil
+}
+
+func (f *fakeRegistry) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	f.mutex.Lock()
+	f.queries = append(f.queries, query)
+	f.mutex.Unlock()
+	return f.fakeEthClient.FilterLogs(ctx, query)
+}
+
+func (f *fakeRegistry) setAgent(agent common.Address) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.agent = agent
+}
+
+func TestAgentRegistry(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	registryAddress := common.HexToAddress("0x7e61")
+	agentA, agentB := common.HexToAddress("0xaaaa"), common.HexToAddress("0xbbbb")
+	for key, value := range map[string]interface{}{config.BlockchainEnabledKey: true,
+		config.AgentContractAddressKey: "", config.UseRegistryKey: true,
+		config.RegistryAddressKey: registryAddress.Hex(), config.RegistryAgentIDKey: "my-agent",
+		config.RegistryRefreshIntervalKey: "10ms"} {
+		defer config.Vip().Set(key, config.Vip().Get(key))
+		config.Vip().Set(key, value)
+	}
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	registry := &fakeRegistry{agent: agentA, fakeEthClient: fakeEthClient{
+		head: &types.Header{Number: big.NewInt(20), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}},
+		logs: []types.Log{{Address: agentB, Topics: []common.Hash{testEvents.jobCreatedID},
+			Data: append(word(jobAddress), word(consumer)...), BlockNumber: 20}},
+	}}
+
+	p, err := NewProcessorWithClients(boltDB, registry, fakeRawCaller{})
+	require.NoError(t, err)
+	defer p.cancel()
+
+	// The agent is resolved at startup by its ID, right-padded to 32 bytes
+	require.Len(t, registry.calls, 1)
+	assert.Equal(t, registryAddress, *registry.calls[0].To)
+	assert.Equal(t, append(append([]byte{}, registryAgentAddressID...),
+		common.RightPadBytes([]byte("my-agent"), 32)...), registry.calls[0].Data)
+	assert.Equal(t, []common.Address{agentA}, p.agentAddresses())
+	agent, err := p.agentFor(nil)
+	require.NoError(t, err)
+	assert.Equal(t, agentA, agent.address)
+
+	// An unchanged registration doesn't change the watched agents
+	version := p.registry.currentVersion()
+	require.NoError(t, p.refreshRegistryAgent())
+	assert.False(t, p.registry.changedSince(version))
+
+	// Once the agent is migrated, the refresh loop picks up the new address while still watching the old one
+	registry.setAgent(agentB)
+	p.runLoop("agent registry refresh", p.refreshRegistry)
+	for deadline := time.Now().Add(5 * time.Second); !p.registry.changedSince(version); {
+		require.True(t, time.Now().Before(deadline), "registry not refreshed")
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []common.Address{agentA, agentB}, p.agentAddresses())
+	agent, err = p.agentFor(nil)
+	require.NoError(t, err)
+	assert.Equal(t, agentB, agent.address)
+	agent, err = p.agentFor(agentA.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, agentA, agent.address)
+
+	// Events are then scanned on both agents
+	require.True(t, p.pollEvents(p.events))
+	registry.mutex.Lock()
+	assert.Equal(t, []common.Address{agentA, agentB}, registry.queries[len(registry.queries)-1].Addresses)
+	registry.mutex.Unlock()
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, agentB.Bytes(), job.AgentAddress)
+
+	// An unregistered agent fails startup
+	registry.setAgent(common.Address{})
+	_, err = NewProcessorWithClients(boltDB, registry, fakeRawCaller{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// relayerRequestTimeout bounds each request to the relayer
+const relayerRequestTimeout = 30 * time.Second
+
+// relayerPollInterval is how often the relayer is asked whether it has sent a relayed completion
+var relayerPollInterval = 2 * time.Second
+
+// relayerClient submits job completions through a meta-transaction relayer, which signs, pays for and sends the
+// transaction in place of the daemon's account.
+//
+// With the "json" format a request of {"from", "to", "data", "gasLimit"} is POSTed to the relayer URL, which answers
+// {"id"}; GET <url>/<id> then answers {"txHash", "error"}, the hash being empty until the transaction is sent. With
+// the "defender" format, that of OpenZeppelin Defender relayers, {"to", "data", "gasLimit", "speed"} is POSTed to
+// <url>/txs, which answers {"transactionId"}, and GET <url>/txs/<id> answers {"hash", "status"}.
+type relayerClient struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+func newRelayerClient(url, format string) *relayerClient {
+	return &relayerClient{url: strings.TrimSuffix(url, "/"), format: format,
+		client: &http.Client{Timeout: relayerRequestTimeout}}
+}
+
+// relay submits a call of to with data on behalf of from, returning the relayer's ID for the request
+func (r *relayerClient) relay(ctx context.Context, from, to common.Address, data []byte, gasLimit uint64) (string,
+	error) {
+	var reply struct {
+		ID            string `json:"id"`
+		TransactionID string `json:"transactionId"`
+	}
+
+	if r.format == "defender" {
+		err := r.do(ctx, http.MethodPost, r.url+"/txs", map[string]interface{}{"to": to.Hex(),
+			"data": hexutil.Encode(data), "gasLimit": gasLimit, "speed": "fast"}, &reply)
+		return reply.TransactionID, err
+	}
+
+	err := r.do(ctx, http.MethodPost, r.url, map[string]interface{}{"from": from.Hex(), "to": to.Hex(),
+		"data": hexutil.Encode(data), "gasLimit": gasLimit}, &reply)
+	return reply.ID, err
+}
+
+// transactionHash returns the hash of the transaction the relayer sent for the request with the given ID, or the zero
+// hash if it hasn't sent one yet. A request the relayer reports failed is an error.
+func (r *relayerClient) transactionHash(ctx context.Context, id string) (common.Hash, error) {
+	var reply struct {
+		TxHash string `json:"txHash"`
+		Error  string `json:"error"`
+		Hash   string `json:"hash"`
+		Status string `json:"status"`
+	}
+
+	if r.format == "defender" {
+		if err := r.do(ctx, http.MethodGet, r.url+"/txs/"+id, nil, &reply); err != nil {
+			return common.Hash{}, err
+		}
+		if reply.Status == "failed" {
+			return common.Hash{}, errors.Errorf("relayer reported transaction %v failed", id)
+		}
+		return parseRelayedHash(reply.Hash)
+	}
+
+	if err := r.do(ctx, http.MethodGet, r.url+"/"+id, nil, &reply); err != nil {
+		return common.Hash{}, err
+	}
+	if reply.Error != "" {
+		return common.Hash{}, errors.Errorf("relayer reported request %v failed: %v", id, reply.Error)
+	}
+	return parseRelayedHash(reply.TxHash)
+}
+
+// parseRelayedHash parses a transaction hash reported by the relayer, empty meaning none has been sent yet
+func parseRelayedHash(hash string) (common.Hash, error) {
+	if hash == "" {
+		return common.Hash{}, nil
+	}
+	decoded, err := hexutil.Decode(hash)
+	if err != nil || len(decoded) != common.HashLength {
+		return common.Hash{}, errors.Errorf("invalid transaction hash '%v' from relayer", hash)
+	}
+	return common.BytesToHash(decoded), nil
+}
+
+// waitSent polls the relayer until it reports the transaction sent for the request with the given ID, or ctx is done
+func (r *relayerClient) waitSent(ctx context.Context, id string) (common.Hash, error) {
+	for {
+		txHash, err := r.transactionHash(ctx, id)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if txHash != (common.Hash{}) {
+			return txHash, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return common.Hash{}, ctx.Err()
+		case <-time.After(relayerPollInterval):
+		}
+	}
+}
+
+// do sends a request to the relayer with body, if any, encoded as JSON, and decodes its JSON reply into reply
+func (r *relayerClient) do(ctx context.Context, method, url string, body interface{}, reply interface{}) error {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return errors.Wrap(err, "error marshaling relayer request")
+		}
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(encoded))
+	if err != nil {
+		return errors.Wrap(err, "error creating relayer request")
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error calling relayer")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("relayer responded %v", resp.Status)
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(reply), "error decoding relayer response")
+}
+
+// relayJobCompletion submits a single job's completion through the relayer and waits for it to report the transaction
+// it sent, the relayer counterpart of sending it directly in sendJobCompletion. The relayer chooses the gas price and
+// replaces the transaction if it gets stuck, so what is returned is only waited on to be mined. A relayer that doesn't
+// report a transaction within the confirmation timeout is left with errCompletionUnconfirmed, like a transaction that
+// isn't mined in time.
+func (p Processor) relayJobCompletion(ctx context.Context, a abi.ABI, sent *sentCompletion, gasLimit uint64) (
+	*sentCompletion, error) {
+	input, err := a.Pack("completeJob", sent.jobAddress, sent.v, sent.r, sent.s)
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error packing completeJob call")
+	}
+
+	sent.log.WithField("gasLimit", gasLimit).Debug("submitting job completion to relayer")
+	id, err := p.relayer.relay(ctx, common.HexToAddress(p.address), sent.agent.address, input, gasLimit)
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error submitting job completion to relayer")
+	}
+	sent.log = sent.log.WithField("relayerID", id)
+
+	waitCtx, cancel := context.WithTimeout(ctx, p.confirmationTimeout)
+	defer cancel()
+	txHash, err := p.relayer.waitSent(waitCtx, id)
+	if err != nil && ctx.Err() != nil {
+		sent.log.Warn("submission cancelled; no longer waiting for relayer to send transaction to complete job")
+		return nil, errSubmissionCancelled
+	}
+	if err != nil && waitCtx.Err() != nil {
+		sent.log.WithError(err).Warn("relayer sent no transaction to complete job in time; leaving it to old job scan")
+		sent.jobInfo.report(common.Hash{}, errors.New("relayer sent no transaction before timeout"))
+		return nil, errCompletionUnconfirmed
+	}
+	if err != nil {
+		completionFailures.Inc()
+		return nil, errors.Wrap(err, "error relaying job completion")
+	}
+
+	completionTransactions.Inc()
+	sent.txHash, sent.submittedAt = txHash, time.Now()
+	sent.log.WithField("txHash", txHash.Hex()).Info("relayer sent transaction to complete job")
+	p.recordCompletionTx(txHash, sent.jobInfo.jobAddressBytes)
+	sent.jobInfo.report(txHash, nil)
+	return sent, nil
+}
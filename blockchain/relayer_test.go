@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRelayer is a relayer in either format that reports the transaction sent on the second poll, or fails every
+// request with failure
+type fakeRelayer struct {
+	mutex    sync.Mutex
+	requests []map[string]interface{}
+	polls    int
+	txHash   common.Hash
+	failure  string
+}
+
+func (f *fakeRelayer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case req.Method == http.MethodPost && (req.URL.Path == "/" || req.URL.Path == "/txs"):
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		f.requests = append(f.requests, body)
+		json.NewEncoder(resp).Encode(map[string]string{"id": "r1", "transactionId": "r1"})
+	case req.Method == http.MethodGet && (req.URL.Path == "/r1" || req.URL.Path == "/txs/r1"):
+		f.polls++
+		hash := ""
+		if f.polls > 1 {
+			hash = f.txHash.Hex()
+		}
+		status := "pending"
+		if f.failure != "" {
+			status = "failed"
+		}
+		json.NewEncoder(resp).Encode(map[string]string{"txHash": hash, "hash": hash, "error": f.failure,
+			"status": status})
+	default:
+		http.NotFound(resp, req)
+	}
+}
+
+func TestRelayerSubmission(t *testing.T) {
+	defer func(interval time.Duration) { relayerPollInterval = interval }(relayerPollInterval)
+	relayerPollInterval = 10 * time.Millisecond
+
+	jobAddress := common.HexToAddress("0x1234")
+	input, err := testAgentABI.Pack("completeJob", jobAddress, uint8(27), [32]byte{1}, [32]byte{1})
+	require.NoError(t, err)
+
+	for _, format := range []string{"json", "defender"} {
+		t.Run(format, func(t *testing.T) {
+			relayer := &fakeRelayer{txHash: common.HexToHash("0x7e1a")}
+			server := httptest.NewServer(relayer)
+			defer server.Close()
+
+			agent := &fakeAgent{}
+			p := newFakeAgentProcessor(agent)
+			p.relayer = newRelayerClient(server.URL+"/", format)
+
+			result := make(chan completionResult, 1)
+			require.NoError(t, p.submitJobCompletion(context.Background(), testAgentABI,
+				&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature, result: result}))
+
+			// The completion went through the relayer rather than being signed and sent directly
+			assert.Equal(t, 0, agent.calls)
+			require.Len(t, relayer.requests, 1)
+			assert.Equal(t, p.agents[0].address.Hex(), relayer.requests[0]["to"])
+			assert.Equal(t, hexutil.Encode(input), relayer.requests[0]["data"])
+			assert.Equal(t, float64(p.jobCompletionGasLimit), relayer.requests[0]["gasLimit"])
+			if format == "json" {
+				assert.Equal(t, p.address, relayer.requests[0]["from"])
+			}
+			assert.Equal(t, 2, relayer.polls)
+			assert.Equal(t, relayer.txHash, (<-result).txHash)
+
+			// A request the relayer reports failed is retried like a completion that failed to send
+			relayer.failure = "insufficient relayer funds"
+			assert.Error(t, p.submitJobCompletion(context.Background(), testAgentABI,
+				&jobInfo{jobAddressBytes: jobAddress.Bytes(), jobSignatureBytes: testJobSignature}))
+		})
+	}
+}
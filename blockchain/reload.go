@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/config"
+)
+
+// liveSettings holds the settings Reload can change while the processor runs. It is safe for concurrent use, and a nil
+// liveSettings holds the defaults: suggested gas prices used as they are, and no consumer allowlist.
+type liveSettings struct {
+	mutex sync.RWMutex
+	// gasPriceMultiplier scales the node's suggested gas price for CompleteJob transactions
+	gasPriceMultiplier float64
+	// consumerAllowlist, if non-empty, limits automatic job completion to jobs created by these consumers; other jobs
+	// are left in the db for manual completion
+	consumerAllowlist map[common.Address]bool
+}
+
+// loadLiveSettings reads the hot-reloadable settings from the configuration
+func loadLiveSettings() (*liveSettings, error) {
+	s := &liveSettings{gasPriceMultiplier: config.GetFloat64(config.GasPriceMultiplierKey)}
+
+	for _, consumer := range config.GetStringSlice(config.ConsumerAllowlistKey) {
+		if !common.IsHexAddress(consumer) {
+			return nil, errors.Errorf("invalid CONSUMER_ALLOWLIST address '%v'", consumer)
+		}
+		if s.consumerAllowlist == nil {
+			s.consumerAllowlist = map[common.Address]bool{}
+		}
+		s.consumerAllowlist[common.HexToAddress(consumer)] = true
+	}
+
+	return s, nil
+}
+
+func (s *liveSettings) gasMultiplier() float64 {
+	if s == nil {
+		return 1
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.gasPriceMultiplier
+}
+
+func (s *liveSettings) consumerAllowed(consumer common.Address) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.consumerAllowlist) == 0 || s.consumerAllowlist[consumer]
+}
+
+// update replaces the settings with those in loaded
+func (s *liveSettings) update(loaded *liveSettings) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.gasPriceMultiplier, s.consumerAllowlist = loaded.gasPriceMultiplier, loaded.consumerAllowlist
+}
+
+// configuredPollInterval returns the base poll interval and the bounds it adapts within; unset bounds keep the interval
+// at POLL_SLEEP
+func configuredPollInterval() (base, floor, ceiling time.Duration) {
+	base = config.GetDuration(config.PollSleepKey)
+	floor, ceiling = base, base
+	if min := config.GetDuration(config.PollSleepMinKey); min > 0 {
+		floor = min
+	}
+	if max := config.GetDuration(config.PollSleepMaxKey); max > 0 {
+		ceiling = max
+	}
+	return base, floor, ceiling
+}
+
+// Reload applies the hot-reloadable settings from the current configuration to the running processor: POLL_SLEEP and
+// its POLL_SLEEP_MIN and POLL_SLEEP_MAX bounds, GAS_PRICE_MULTIPLIER and CONSUMER_ALLOWLIST. The event cursor and the
+// queued and in-flight jobs are untouched, and a completion already being sent keeps the gas price it was given. Every
+// other setting, e.g. the Ethereum endpoint, the signing key or the agent addresses, only takes effect on restart.
+//
+// The configuration is expected to have been re-read and validated, as config.Reload does; an invalid allowlist leaves
+// the running settings unchanged.
+func (p Processor) Reload() error {
+	if !p.enabled {
+		return nil
+	}
+
+	loaded, err := loadLiveSettings()
+	if err != nil {
+		return err
+	}
+
+	p.settings.update(loaded)
+	p.pollInterval.reset(configuredPollInterval())
+
+	log.WithField("pollSleep", config.GetDuration(config.PollSleepKey)).
+		WithField("gasPriceMultiplier", loaded.gasPriceMultiplier).
+		WithField("consumerAllowlist", len(loaded.consumerAllowlist)).
+		Info("reloaded blockchain processor configuration")
+	return nil
+}
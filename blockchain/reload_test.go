@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/singnet/snet-daemon/config"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	consumer := common.HexToAddress("0xc1")
+	for key, value := range map[string]interface{}{config.PollSleepKey: "5s", config.PollSleepMinKey: "",
+		config.PollSleepMaxKey: "", config.GasPriceMultiplierKey: 1.0, config.ConsumerAllowlistKey: ""} {
+		defer config.Vip().Set(key, config.Vip().Get(key))
+		config.Vip().Set(key, value)
+	}
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.enabled = true
+	p.pollInterval = newPollInterval(configuredPollInterval())
+	settings, err := loadLiveSettings()
+	require.NoError(t, err)
+	p.settings = settings
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(42), common.Hash{1})
+	}))
+
+	assert.Equal(t, 5*time.Second, p.pollInterval.current())
+	assert.Equal(t, 1.0, p.settings.gasMultiplier())
+	assert.True(t, p.consumerAllowed(common.HexToAddress("0xc2").Bytes()))
+
+	config.Vip().Set(config.PollSleepKey, "1s")
+	config.Vip().Set(config.PollSleepMaxKey, "4s")
+	config.Vip().Set(config.GasPriceMultiplierKey, 1.25)
+	config.Vip().Set(config.ConsumerAllowlistKey, consumer.Hex())
+	require.NoError(t, p.Reload())
+
+	assert.Equal(t, time.Second, p.pollInterval.current())
+	p.pollInterval.caughtUp()
+	p.pollInterval.caughtUp()
+	p.pollInterval.caughtUp()
+	assert.Equal(t, 4*time.Second, p.pollInterval.current())
+	assert.Equal(t, 1.25, p.settings.gasMultiplier())
+	assert.True(t, p.consumerAllowed(consumer.Bytes()))
+	assert.False(t, p.consumerAllowed(common.HexToAddress("0xc2").Bytes()))
+
+	// The event cursor is kept
+	lastBlock, err := p.LastProcessedBlock()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(42), lastBlock)
+
+	// An invalid allowlist leaves the running settings as they were
+	config.Vip().Set(config.GasPriceMultiplierKey, 2.0)
+	config.Vip().Set(config.ConsumerAllowlistKey, "0xnot-an-address")
+	assert.Error(t, p.Reload())
+	assert.Equal(t, 1.25, p.settings.gasMultiplier())
+	assert.True(t, p.consumerAllowed(consumer.Bytes()))
+
+	resp := httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+
+	// Only the admin API reloads
+	resp = httptest.NewRecorder()
+	p.JobsHandler().ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// cursorLock serializes event cursor updates between the event loop and Resync. resets counts the resyncs so far, so
+// a subscription started before one can tell that the cursor it would advance has been moved under it. A nil
+// cursorLock, as in processors built for tests, does no locking.
+type cursorLock struct {
+	mutex  sync.Mutex
+	resets uint64
+}
+
+func (l *cursorLock) lock() {
+	if l != nil {
+		l.mutex.Lock()
+	}
+}
+
+func (l *cursorLock) unlock() {
+	if l != nil {
+		l.mutex.Unlock()
+	}
+}
+
+// resetSince reports whether the cursor has been reset since the given generation; the lock must be held
+func (l *cursorLock) resetSince(generation uint64) bool {
+	return l != nil && l.resets != generation
+}
+
+// reset records a resync; the lock must be held
+func (l *cursorLock) reset() {
+	if l != nil {
+		l.resets++
+	}
+}
+
+// cursorGeneration returns the number of resyncs so far
+func (p Processor) cursorGeneration() uint64 {
+	if p.cursorLock == nil {
+		return 0
+	}
+
+	p.cursorLock.lock()
+	defer p.cursorLock.unlock()
+	return p.cursorLock.resets
+}
+
+// cursorResetSince reports whether Resync has moved the cursor since the given generation
+func (p Processor) cursorResetSince(generation uint64) bool {
+	return p.cursorGeneration() != generation
+}
+
+// Resync moves the event cursor back to just before fromBlock, so the event loop re-scans forward from there and
+// rebuilds job state from the events it finds; the handlers tolerate events they have already applied. It waits for
+// any poll in progress to finish, and a running log subscription drops and falls back to polling.
+func (p Processor) Resync(fromBlock *big.Int) error {
+	if fromBlock == nil || fromBlock.Sign() <= 0 {
+		return errors.New("resync must start at block 1 or later")
+	}
+
+	p.cursorLock.lock()
+	defer p.cursorLock.unlock()
+
+	lastBlock := new(big.Int).Sub(fromBlock, big.NewInt(1))
+
+	// Without a hash the next poll skips the reorg check against the block that used to be the cursor
+	if err := p.boltDB.Update(func(tx *bolt.Tx) error {
+		if err := restoreCompletedJobs(tx.Bucket(db.JobBucketName), lastBlock); err != nil {
+			return err
+		}
+		if err := deleteArchivedLogs(tx.Bucket(db.LogArchiveBucketName), lastBlock.Uint64()); err != nil {
+			return err
+		}
+		return putCursor(tx.Bucket(db.ChainBucketName), lastBlock, common.Hash{})
+	}); err != nil {
+		return errors.Wrap(err, "error resetting event cursor")
+	}
+
+	p.cursorLock.reset()
+	lastBlockHeight.Set(float64(lastBlock.Int64()))
+	log.WithField("fromBlock", fromBlock).Info("reset event cursor; re-scanning job events")
+	return nil
+}
+
+// LastProcessedBlock returns the event cursor: the last block scanned for job events, or nil if none has been yet
+func (p Processor) LastProcessedBlock() (*big.Int, error) {
+	if p.boltDB == nil {
+		return nil, errors.New("blockchain processing disabled")
+	}
+
+	var lastBlock *big.Int
+	err := p.boltDB.View(func(tx *bolt.Tx) error {
+		if lastBlockBytes := tx.Bucket(db.ChainBucketName).Get(db.LastBlockKey); lastBlockBytes != nil {
+			lastBlock = new(big.Int).SetBytes(lastBlockBytes)
+		}
+		return nil
+	})
+	return lastBlock, errors.Wrap(err, "error reading event cursor")
+}
@@ -0,0 +1,158 @@
+package blockchain
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FakeEventChain serves a chain whose head is at block 20, with the given job logs
+type FakeEventChain struct {
+	logs []types.Log
+}
+
+func (f *FakeEventChain) BlockNumber() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(20))
+}
+
+func (f *FakeEventChain) GetBlockByNumber(number string, full bool) *types.Header {
+	blockNumber := big.NewInt(20)
+	if number != "latest" {
+		blockNumber = new(big.Int).SetBytes(common.FromHex(number))
+	}
+	return &types.Header{Number: blockNumber, Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}}
+}
+
+func (f *FakeEventChain) GetLogs(query map[string]interface{}) []types.Log {
+	from := new(big.Int).SetBytes(common.FromHex(query["fromBlock"].(string))).Uint64()
+	to := new(big.Int).SetBytes(common.FromHex(query["toBlock"].(string))).Uint64()
+
+	logs := []types.Log{}
+	for _, jobLog := range f.logs {
+		if jobLog.BlockNumber >= from && jobLog.BlockNumber <= to {
+			logs = append(logs, jobLog)
+		}
+	}
+	return logs
+}
+
+func getCursor(t *testing.T, boltDB *bolt.DB) (*big.Int, []byte) {
+	var block *big.Int
+	var hash []byte
+	require.NoError(t, boltDB.View(func(tx *bolt.Tx) error {
+		chain := tx.Bucket(db.ChainBucketName)
+		block = new(big.Int).SetBytes(chain.Get(db.LastBlockKey))
+		hash = chain.Get(db.LastBlockHashKey)
+		return nil
+	}))
+	return block, hash
+}
+
+func TestResync(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	server := rpc.NewServer()
+	chain := &FakeEventChain{logs: []types.Log{{
+		Topics:      []common.Hash{testEvents.jobCreatedID},
+		Data:        append(word(jobAddress), word(consumer)...),
+		BlockNumber: 5,
+	}}}
+	require.NoError(t, server.RegisterName("eth", chain))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 100
+	p.status = &processorStatus{}
+	p.cursorLock = &cursorLock{}
+
+	// The cursor is already at the head, but the job created at block 5 is missing from the db
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(20),
+			chain.GetBlockByNumber("0x14", false).Hash())
+	}))
+	require.True(t, p.pollEvents(testEvents))
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	generation := p.cursorGeneration()
+	assert.Error(t, p.Resync(big.NewInt(0)))
+	require.NoError(t, p.Resync(big.NewInt(5)))
+	block, hash := getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(4), block)
+	assert.Nil(t, hash)
+
+	// A subscription from before the resync may not move the cursor past it
+	assert.True(t, p.cursorResetSince(generation))
+	assert.Error(t, p.commitStreamedEvent(testEvents, types.Log{BlockNumber: 20}, generation))
+	block, _ = getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(4), block)
+
+	// Re-scanning rebuilds the job, and doing it again leaves it as it was
+	for i := 0; i < 2; i++ {
+		require.True(t, p.pollEvents(testEvents))
+		block, _ = getCursor(t, boltDB)
+		assert.Equal(t, big.NewInt(20), block)
+
+		job, err = db.GetJob(boltDB, jobAddress.Bytes())
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, jobPendingState, job.JobState)
+		assert.Equal(t, consumer.Bytes(), job.Consumer)
+
+		require.NoError(t, p.Resync(big.NewInt(5)))
+	}
+}
+
+func TestLastProcessedBlock(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &FakeEventChain{}))
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.enabled, p.rawClient, p.status = true, rpc.DialInProc(server), &processorStatus{}
+	p.status.recordPoll()
+
+	health := func() string {
+		resp := httptest.NewRecorder()
+		p.HealthHandler(time.Minute).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+		require.Equal(t, http.StatusOK, resp.Code)
+		return resp.Body.String()
+	}
+
+	// Nothing has been scanned yet
+	lastBlock, err := p.LastProcessedBlock()
+	require.NoError(t, err)
+	assert.Nil(t, lastBlock)
+	assert.Equal(t, "ok\n", health())
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(1234), common.Hash{})
+	}))
+	lastBlock, err = p.LastProcessedBlock()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1234), lastBlock)
+	assert.Equal(t, "ok\nlastBlock 1234\n", health())
+
+	_, err = Processor{}.LastProcessedBlock()
+	assert.Error(t, err)
+}
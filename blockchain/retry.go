@@ -0,0 +1,194 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/sirupsen/logrus"
+)
+
+// maxCompletionRetryDelay caps the doubling delay between job completion retries
+const maxCompletionRetryDelay = time.Hour
+
+// permanentError marks a job completion failure that retrying can't fix
+type permanentError struct {
+	error
+}
+
+// errSubmissionCancelled is returned by a job completion cut short by the processor stopping once its transaction may
+// have been sent. It isn't a failed attempt: the job is left in the outbox, for the replay on the next start to check
+// whether the transaction was mined.
+var errSubmissionCancelled = errors.New("job completion submission cancelled")
+
+// errCompletionUnconfirmed is returned by a job completion whose transaction was sent but not mined before the
+// confirmation timeout. It isn't a failed attempt either: the job stays in the outbox with its transaction recorded,
+// for the next old job scan to wait on that transaction rather than send another while the node still knows it.
+var errCompletionUnconfirmed = errors.New("job completion transaction not mined before timeout")
+
+// retryJobCompletion records a failed job completion and re-enqueues it after a backoff, or marks the job failed once
+// it has used up its attempts. It reports whether a retry was scheduled.
+func (p Processor) retryJobCompletion(jobInfo *jobInfo, cause error) bool {
+	log := log.WithFields(logrus.Fields{
+		"jobAddress":   common.BytesToAddress(jobInfo.jobAddressBytes).Hex(),
+		"jobSignature": hex.EncodeToString(jobInfo.jobSignatureBytes),
+	}).WithError(cause)
+
+	_, permanent := cause.(permanentError)
+	attempts, failed, err := p.recordCompletionAttempt(jobInfo.jobAddressBytes, permanent)
+	if err != nil {
+		log.WithField("recordError", err).Error("error recording failed job completion; not retrying")
+		return false
+	}
+
+	if failed {
+		log.WithField("attempts", attempts).Error("job completion failed permanently; marking job failed")
+		return false
+	}
+
+	delay := completionRetryDelay(p.completionRetryDelay, attempts)
+	log.WithField("attempts", attempts).WithField("retryIn", delay).Warn("job completion failed; retrying")
+	time.AfterFunc(delay, func() { p.queueJobCompletion(jobInfo) })
+	return true
+}
+
+// recordCompletionAttempt persists a failed completion attempt for the job, moving it to the failed state when the
+// failure is permanent or the attempts are used up
+func (p Processor) recordCompletionAttempt(jobAddressBytes []byte, permanent bool) (attempts int, failed bool,
+	err error) {
+	err = p.boltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.JobBucketName)
+
+		job := &db.Job{JobAddress: jobAddressBytes}
+		if jobBytes := bucket.Get(jobAddressBytes); jobBytes != nil {
+			if err := json.Unmarshal(jobBytes, job); err != nil {
+				return errors.Wrap(err, "error unmarshaling job")
+			}
+		}
+
+		job.CompletionAttempts++
+		fromState := job.JobState
+		if permanent || job.CompletionAttempts >= p.maxCompletionAttempts {
+			job.JobState = jobFailedState
+		}
+		job.Touch(time.Now())
+		if job.JobState != fromState {
+			if err := db.AppendJobHistory(tx, jobAddressBytes, db.HistoryEntry{Timestamp: job.UpdatedAt,
+				FromState: fromState, ToState: job.JobState}); err != nil {
+				return err
+			}
+		}
+
+		attempts, failed = job.CompletionAttempts, job.JobState == jobFailedState
+
+		jobBytes, err := json.Marshal(job)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling job")
+		}
+		return errors.Wrap(bucket.Put(jobAddressBytes, jobBytes), "error putting job to db")
+	})
+
+	return attempts, failed, err
+}
+
+// completionRetryDelay returns the delay before retrying a completion that has failed the given number of times
+func completionRetryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxCompletionRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxCompletionRetryDelay {
+		delay = maxCompletionRetryDelay
+	}
+	return delay
+}
+
+// sendWithRetry makes the node call that sends a completion transaction, retrying it with a doubling delay while it
+// fails transiently, up to the configured number of tries. Each try is bounded by the submit timeout. Any other
+// failure, e.g. a nonce that's too low or a reverting call, is returned at once, since sending the same transaction
+// again would fail the same way. A node that reports the transaction already known has it, e.g. from a try that timed
+// out after the node accepted it, so the transaction counts as sent; each try sends the same signed transaction, whose
+// hash the caller already has.
+func (p Processor) sendWithRetry(ctx context.Context, send func(ctx context.Context) error) error {
+	delay := p.submitRetryDelay
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.submitTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, p.submitTimeout)
+		}
+		err := send(attemptCtx)
+		cancel()
+
+		if err != nil && isAlreadyKnownError(err) {
+			log.WithError(err).WithField("attempt", attempt).
+				Info("node already has transaction to complete job; treating it as sent")
+			return nil
+		}
+		if err == nil || attempt >= p.submitAttempts || ctx.Err() != nil || !isTransientRPCError(err) {
+			return err
+		}
+
+		log.WithError(err).WithField("attempt", attempt).WithField("retryIn", delay).
+			Warn("transient error sending transaction to complete job; retrying")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// alreadyKnownErrors are fragments of the errors nodes return for a transaction already in their pool
+var alreadyKnownErrors = []string{
+	"already known",
+	"known transaction",
+}
+
+// isAlreadyKnownError reports whether sending a transaction failed because the node already has it
+func isAlreadyKnownError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, fragment := range alreadyKnownErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// transientRPCErrors are fragments of the errors from a node call that come from the connection to the node or its
+// load rather than from the call itself
+var transientRPCErrors = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"eof",
+	"timeout",
+	"too many requests",
+	"rate limit",
+	"service unavailable",
+	"bad gateway",
+}
+
+// isTransientRPCError reports whether a node call, such as sending a transaction, failed in a way another try may not,
+// including a try that ran out of time
+func isTransientRPCError(err error) bool {
+	if errors.Cause(err) == context.DeadlineExceeded {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, fragment := range transientRPCErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
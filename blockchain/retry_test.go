@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryJobCompletion(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	require.NoError(t, err)
+
+	// FakeEth doesn't serve eth_estimateGas, so every submission fails before a transaction is sent
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &FakeEth{}))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.maxCompletionAttempts, p.completionRetryDelay = 3, time.Millisecond
+
+	jobAddress := common.HexToAddress("0x1234").Bytes()
+	queued := &jobInfo{jobAddressBytes: jobAddress, jobSignatureBytes: make([]byte, 65)}
+
+	for attempt := 1; attempt <= p.maxCompletionAttempts; attempt++ {
+		err := p.submitJobCompletion(context.Background(), a, queued)
+		require.Error(t, err)
+		p.retryJobCompletion(queued, err)
+
+		job, err := db.GetJob(boltDB, jobAddress)
+		require.NoError(t, err)
+		assert.Equal(t, attempt, job.CompletionAttempts)
+
+		select {
+		case requeued := <-p.jobCompletionQueue:
+			require.True(t, attempt < p.maxCompletionAttempts, "requeued after the last attempt")
+			queued = requeued
+		case <-time.After(100 * time.Millisecond):
+			require.Equal(t, p.maxCompletionAttempts, attempt, "not requeued after attempt %v", attempt)
+			assert.Equal(t, jobFailedState, job.JobState)
+		}
+	}
+}
+
+func TestCompletionRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, completionRetryDelay(time.Second, 1))
+	assert.Equal(t, 4*time.Second, completionRetryDelay(time.Second, 3))
+	assert.Equal(t, maxCompletionRetryDelay, completionRetryDelay(time.Minute, 20))
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/db"
+)
+
+// VerifyJobSignature checks that jobSignatureBytes authorizes completion of the job at jobAddressBytes: the signer
+// must be the job's consumer as recorded in the db or, when the consumer isn't known locally, the agent must accept
+// the signature on chain
+func (p Processor) VerifyJobSignature(jobAddressBytes, jobSignatureBytes []byte) error {
+	job, err := db.GetJob(p.boltDB, jobAddressBytes)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		job = &db.Job{}
+	}
+
+	return p.verifyJobSignature(jobAddressBytes, job, jobSignatureBytes)
+}
+
+func (p Processor) verifyJobSignature(jobAddressBytes []byte, job *db.Job, jobSignatureBytes []byte) error {
+	agent, err := p.agentFor(job.AgentAddress)
+	if err != nil {
+		return err
+	}
+
+	signer, err := recoverJobSigner(agent, jobAddressBytes, jobSignatureBytes)
+	if err != nil {
+		return err
+	}
+
+	if job.Consumer == nil {
+		return p.validateJobOnChain(agent, jobAddressBytes, jobSignatureBytes)
+	}
+
+	if !bytes.Equal(signer.Bytes(), job.Consumer) {
+		return errors.Errorf("job signed by %v rather than consumer %v", signer.Hex(),
+			common.BytesToAddress(job.Consumer).Hex())
+	}
+
+	return nil
+}
+
+// recoverJobSigner returns the address that signed the job address with the given agent's signature scheme
+func recoverJobSigner(agent *agentContract, jobAddressBytes, jobSignatureBytes []byte) (common.Address, error) {
+	v, _, _, err := parseSignature(jobSignatureBytes)
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "error parsing signature")
+	}
+
+	pubKey, err := crypto.SigToPub(agent.sigHasher(jobAddressBytes), bytes.Join([][]byte{jobSignatureBytes[0:64],
+		{v % 27}}, []byte{}))
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "error recovering signature")
+	}
+
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// validateJobOnChain asks the agent whether the signature is a valid invocation of the job
+func (p Processor) validateJobOnChain(agent *agentContract, jobAddressBytes, jobSignatureBytes []byte) error {
+	v, r, s, err := parseSignature(jobSignatureBytes)
+	if err != nil {
+		return errors.Wrap(err, "error parsing signature")
+	}
+
+	validated, err := agent.agent.ValidateJobInvocation(&bind.CallOpts{
+		Pending: true,
+		From:    common.HexToAddress(p.address)}, common.BytesToAddress(jobAddressBytes), v, r, s)
+	if err != nil {
+		return errors.Wrap(err, "error validating job on chain")
+	}
+	if !validated {
+		return errors.New("job invocation rejected on chain")
+	}
+
+	return nil
+}
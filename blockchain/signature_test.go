@@ -0,0 +1,41 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyJobSignature(t *testing.T) {
+	sigHasher := func(i []byte) []byte {
+		return crypto.Keccak256(hashPrefix42Bytes, []byte(hex.EncodeToString(i)))
+	}
+	p := Processor{agents: []*agentContract{{sigHasher: sigHasher}}}
+
+	consumerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	jobAddress := common.HexToAddress("0x1234").Bytes()
+	job := &db.Job{JobAddress: jobAddress, Consumer: crypto.PubkeyToAddress(consumerKey.PublicKey).Bytes()}
+
+	signature, err := crypto.Sign(sigHasher(jobAddress), consumerKey)
+	require.NoError(t, err)
+	assert.NoError(t, p.verifyJobSignature(jobAddress, job, signature))
+
+	tampered := append([]byte(nil), signature...)
+	tampered[10] ^= 0xff
+	assert.Error(t, p.verifyJobSignature(jobAddress, job, tampered))
+
+	otherSignature, err := crypto.Sign(sigHasher(jobAddress), otherKey)
+	require.NoError(t, err)
+	assert.Error(t, p.verifyJobSignature(jobAddress, job, otherSignature))
+
+	assert.Error(t, p.verifyJobSignature(jobAddress, job, signature[:64]))
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// TransactionSigner signs the transactions sent from the daemon's account
+type TransactionSigner interface {
+	// Address returns the account transactions are signed for
+	Address() common.Address
+	// SignTx returns tx signed by the account; signer is the signature scheme the transaction is bound for
+	SignTx(signer types.Signer, tx *types.Transaction) (*types.Transaction, error)
+}
+
+// signerFn adapts a TransactionSigner to the callback expected by bind.TransactOpts
+func signerFn(signer TransactionSigner) bind.SignerFn {
+	return func(s types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if address != signer.Address() {
+			return nil, errors.New("not authorized to sign this account")
+		}
+		return signer.SignTx(s, tx)
+	}
+}
+
+// keySigner signs with a private key held in process
+type keySigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+func (k *keySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(k.privateKey.PublicKey)
+}
+
+func (k *keySigner) SignTx(signer types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	return types.SignTx(tx, signer, k.privateKey)
+}
+
+// clefSigner delegates signing to an external signer such as Clef over its account_ JSON-RPC interface, so the key
+// never enters the daemon. The external signer applies its own chain configuration when signing.
+type clefSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// clefSignTimeout bounds a signing request, which may wait on manual approval in the external signer
+const clefSignTimeout = 2 * time.Minute
+
+func newClefSigner(endpoint string, address common.Address) (*clefSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clefSigner{client: client, address: address}, nil
+}
+
+func (c *clefSigner) Address() common.Address {
+	return c.address
+}
+
+// clefTxArgs mirrors the transaction arguments accepted by account_signTransaction
+type clefTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     *hexutil.Bytes  `json:"data"`
+}
+
+func (c *clefSigner) SignTx(signer types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	data := hexutil.Bytes(tx.Data())
+	args := clefTxArgs{
+		From:     c.address,
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     &data,
+		To:       tx.To(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clefSignTimeout)
+	defer cancel()
+
+	var result struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := c.client.CallContext(ctx, &result, "account_signTransaction", args, nil); err != nil {
+		return nil, errors.Wrap(err, "error signing transaction with external signer")
+	}
+
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(result.Raw, signed); err != nil {
+		return nil, errors.Wrap(err, "error decoding transaction from external signer")
+	}
+
+	// The signer must not have changed what was asked to be signed, e.g. through a UI modification
+	if signed.Nonce() != tx.Nonce() || signed.Gas() != tx.Gas() || signed.GasPrice().Cmp(tx.GasPrice()) != 0 ||
+		signed.Value().Cmp(tx.Value()) != 0 || !bytes.Equal(signed.Data(), tx.Data()) ||
+		(signed.To() == nil) != (tx.To() == nil) || (tx.To() != nil && *signed.To() != *tx.To()) {
+		return nil, errors.New("external signer modified the transaction")
+	}
+
+	return signed, nil
+}
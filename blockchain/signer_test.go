@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigner is a TransactionSigner test double that records what it was asked to sign
+type fakeSigner struct {
+	mutex   sync.Mutex
+	address common.Address
+	signed  []*types.Transaction
+}
+
+func (f *fakeSigner) Address() common.Address {
+	return f.address
+}
+
+func (f *fakeSigner) SignTx(signer types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.signed = append(f.signed, tx)
+	return tx, nil
+}
+
+func TestSignerFn(t *testing.T) {
+	signer := &fakeSigner{address: common.HexToAddress("0x1234")}
+	tx := types.NewTransaction(0, common.HexToAddress("0x5678"), big.NewInt(0), 100000, big.NewInt(1), nil)
+
+	_, err := signerFn(signer)(types.HomesteadSigner{}, signer.address, tx)
+	require.NoError(t, err)
+	assert.Equal(t, []*types.Transaction{tx}, signer.signed)
+
+	_, err = signerFn(signer)(types.HomesteadSigner{}, common.HexToAddress("0x9999"), tx)
+	assert.Error(t, err)
+}
+
+// FakeClef serves account_signTransaction with an in-process key; the rpc package only serves exported types
+type FakeClef struct {
+	key      *ecdsa.PrivateKey
+	gasPrice *big.Int
+}
+
+type FakeClefTxArgs clefTxArgs
+
+func (f *FakeClef) SignTransaction(args FakeClefTxArgs, methodSelector *string) (map[string]hexutil.Bytes, error) {
+	gasPrice := (*big.Int)(&args.GasPrice)
+	if f.gasPrice != nil {
+		gasPrice = f.gasPrice
+	}
+
+	tx := types.NewTransaction(uint64(args.Nonce), *args.To, (*big.Int)(&args.Value), uint64(args.Gas),
+		gasPrice, *args.Data)
+	signed, err := types.SignTx(tx, types.HomesteadSigner{}, f.key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := rlp.EncodeToBytes(signed)
+	return map[string]hexutil.Bytes{"raw": raw}, err
+}
+
+func TestClefSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	clef := &FakeClef{key: key}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("account", clef))
+	signer := &clefSigner{client: rpc.DialInProc(server), address: address}
+
+	tx := types.NewTransaction(7, common.HexToAddress("0x5678"), big.NewInt(0), 100000, big.NewInt(1), []byte{1, 2})
+	signed, err := signer.SignTx(types.HomesteadSigner{}, tx)
+	require.NoError(t, err)
+
+	from, err := types.Sender(types.HomesteadSigner{}, signed)
+	require.NoError(t, err)
+	assert.Equal(t, address, from)
+	assert.Equal(t, tx.Nonce(), signed.Nonce())
+	assert.Equal(t, tx.Data(), signed.Data())
+
+	// A transaction changed by the external signer is rejected
+	clef.gasPrice = big.NewInt(1000)
+	_, err = signer.SignTx(types.HomesteadSigner{}, tx)
+	assert.Error(t, err)
+}
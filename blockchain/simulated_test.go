@@ -0,0 +1,328 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAgentCode returns the creation code of a stub agent contract. A completeJob call emits JobCompleted for its job;
+// any other call emits a log whose topic is the first word of the call data and whose data is the rest, which lets a
+// test emit JobCreated and JobFunded with whatever arguments it likes.
+func stubAgentCode(events jobEvents) []byte {
+	op := func(ops ...vm.OpCode) []byte {
+		code := make([]byte, len(ops))
+		for i, o := range ops {
+			code[i] = byte(o)
+		}
+		return code
+	}
+	push1 := func(b byte) []byte { return []byte{byte(vm.PUSH1), b} }
+	push32 := func(word []byte) []byte { return append(op(vm.PUSH32), common.RightPadBytes(word, 32)...) }
+	concat := func(parts ...[]byte) []byte {
+		var code []byte
+		for _, part := range parts {
+			code = append(code, part...)
+		}
+		return code
+	}
+
+	// Dispatch on whether the selector is completeJob's
+	dispatch := concat(push32(testAgentABI.Methods["completeJob"].Id()), push32([]byte{0xff, 0xff, 0xff, 0xff}),
+		push1(0), op(vm.CALLDATALOAD, vm.AND, vm.EQ))
+	emit := concat(
+		// copy calldata[32:] to memory and log it with calldata[:32] as its topic
+		push1(32), op(vm.CALLDATASIZE, vm.SUB, vm.DUP1), push1(32), push1(0), op(vm.CALLDATACOPY),
+		push1(0), op(vm.CALLDATALOAD, vm.SWAP1), push1(0), op(vm.LOG1, vm.STOP))
+	completeJob := concat(
+		// log the job address argument as JobCompleted's data
+		op(vm.JUMPDEST), push1(4), op(vm.CALLDATALOAD), push1(0), op(vm.MSTORE),
+		push32(events.jobCompletedID.Bytes()), push1(32), push1(0), op(vm.LOG1, vm.STOP))
+	completeJobDest := byte(len(dispatch) + 3 + len(emit))
+	runtime := concat(dispatch, push1(completeJobDest), op(vm.JUMPI), emit, completeJob)
+
+	// The constructor returns the runtime code that follows it
+	constructor := concat(push1(byte(len(runtime))), op(vm.DUP1), push1(0), push1(0), op(vm.CODECOPY), push1(0),
+		op(vm.RETURN))
+	constructor[4] = byte(len(constructor))
+	return append(constructor, runtime...)
+}
+
+// SimulatedNode serves the eth_ methods the processor uses from a simulated chain. Every transaction sent is mined
+// into a block of its own. The simulated backend doesn't expose its headers, so blocks are served as synthetic headers
+// that are consistent for each height; logs and receipts are the chain's own.
+type SimulatedNode struct {
+	mutex   sync.Mutex
+	backend *backends.SimulatedBackend
+	head    uint64
+	mined   map[common.Hash]uint64
+}
+
+func newSimulatedNode(alloc core.GenesisAlloc) *SimulatedNode {
+	return &SimulatedNode{backend: backends.NewSimulatedBackend(alloc), mined: map[common.Hash]uint64{}}
+}
+
+func (n *SimulatedNode) header(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(1), Time: big.NewInt(1),
+		Extra: []byte{}}
+}
+
+// send mines tx into a new block
+func (n *SimulatedNode) send(tx *types.Transaction) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if err := n.backend.SendTransaction(context.Background(), tx); err != nil {
+		return err
+	}
+	n.backend.Commit()
+	n.head++
+	n.mined[tx.Hash()] = n.head
+	return nil
+}
+
+func (n *SimulatedNode) BlockNumber() *hexutil.Big {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return (*hexutil.Big)(new(big.Int).SetUint64(n.head))
+}
+
+func (n *SimulatedNode) GetBlockByNumber(number string, full bool) *types.Header {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	height := n.head
+	if number != "latest" && number != "pending" {
+		height = new(big.Int).SetBytes(common.FromHex(number)).Uint64()
+	}
+	if height > n.head {
+		return nil
+	}
+	return n.header(height)
+}
+
+func (n *SimulatedNode) GetLogs(query map[string]interface{}) ([]types.Log, error) {
+	filter := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetBytes(common.FromHex(query["fromBlock"].(string))),
+		ToBlock:   new(big.Int).SetBytes(common.FromHex(query["toBlock"].(string))),
+	}
+	if addresses, ok := query["address"].([]interface{}); ok {
+		for _, address := range addresses {
+			filter.Addresses = append(filter.Addresses, common.HexToAddress(address.(string)))
+		}
+	}
+	if topics, ok := query["topics"].([]interface{}); ok {
+		for _, position := range topics {
+			var alternatives []common.Hash
+			if position, ok := position.([]interface{}); ok {
+				for _, topic := range position {
+					alternatives = append(alternatives, common.HexToHash(topic.(string)))
+				}
+			}
+			filter.Topics = append(filter.Topics, alternatives)
+		}
+	}
+
+	logs, err := n.backend.FilterLogs(context.Background(), filter)
+	if logs == nil {
+		logs = []types.Log{}
+	}
+	return logs, err
+}
+
+func (n *SimulatedNode) GetBalance(address common.Address, block string) (*hexutil.Big, error) {
+	balance, err := n.backend.BalanceAt(context.Background(), address, nil)
+	return (*hexutil.Big)(balance), err
+}
+
+func (n *SimulatedNode) GetTransactionCount(address common.Address, block string) (hexutil.Uint64, error) {
+	nonce, err := n.backend.PendingNonceAt(context.Background(), address)
+	return hexutil.Uint64(nonce), err
+}
+
+func (n *SimulatedNode) GasPrice() (*hexutil.Big, error) {
+	gasPrice, err := n.backend.SuggestGasPrice(context.Background())
+	return (*hexutil.Big)(gasPrice), err
+}
+
+func (n *SimulatedNode) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	tx := &types.Transaction{}
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), n.send(tx)
+}
+
+func (n *SimulatedNode) GetTransactionReceipt(txHash common.Hash) (map[string]interface{}, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	receipt, _ := n.backend.TransactionReceipt(context.Background(), txHash)
+	if receipt == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["blockNumber"] = hexutil.Uint64(n.mined[txHash])
+	return fields, nil
+}
+
+// simulatedChain is a simulated chain with a stub agent deployed, served to a processor over RPC
+type simulatedChain struct {
+	node         *SimulatedNode
+	agentAddress common.Address
+	key          *ecdsa.PrivateKey
+	nonce        uint64
+}
+
+// transact sends a transaction from the chain's account, mined into a block of its own
+func (c *simulatedChain) transact(t *testing.T, to *common.Address, data []byte) *types.Transaction {
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(c.nonce, big.NewInt(0), 1000000, big.NewInt(1), data)
+	} else {
+		tx = types.NewTransaction(c.nonce, *to, big.NewInt(0), 1000000, big.NewInt(1), data)
+	}
+	tx, err := types.SignTx(tx, types.HomesteadSigner{}, c.key)
+	require.NoError(t, err)
+	require.NoError(t, c.node.send(tx))
+	c.nonce++
+	return tx
+}
+
+// emit has the stub agent log an event with the given topic and address arguments
+func (c *simulatedChain) emit(t *testing.T, topic common.Hash, args ...common.Address) {
+	data := topic.Bytes()
+	for _, arg := range args {
+		data = append(data, common.LeftPadBytes(arg.Bytes(), 32)...)
+	}
+	c.transact(t, &c.agentAddress, data)
+}
+
+// newSimulatedProcessor deploys a stub agent to a new simulated chain and returns a processor, ready to start, that
+// follows it and completes jobs with its own funded account
+func newSimulatedProcessor(t *testing.T) (Processor, *simulatedChain, func()) {
+	chainKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	daemonKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	daemonAddress := crypto.PubkeyToAddress(daemonKey.PublicKey)
+
+	funds := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	node := newSimulatedNode(core.GenesisAlloc{
+		crypto.PubkeyToAddress(chainKey.PublicKey): {Balance: funds},
+		daemonAddress: {Balance: funds},
+	})
+	chain := &simulatedChain{node: node, key: chainKey}
+
+	deployment := chain.transact(t, nil, stubAgentCode(testEvents))
+	chain.agentAddress = crypto.CreateAddress(crypto.PubkeyToAddress(chainKey.PublicKey), deployment.Nonce())
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", node))
+	client := rpc.DialInProc(server)
+	ethClient := ethclient.NewClient(client)
+
+	agent, err := NewAgent(chain.agentAddress, ethClient)
+	require.NoError(t, err)
+
+	boltDB, cleanup := newTestDB(t)
+	p, cancel := newTestProcessor(boltDB)
+	p.enabled = true
+	p.rawClient, p.ethClient = client, ethClient
+	p.agents = []*agentContract{{address: chain.agentAddress, agent: agent}}
+	p.signer = &keySigner{daemonKey}
+	p.address = daemonAddress.Hex()
+	p.nonces = newNonceTracker(ethClient, daemonAddress)
+	p.jobCompletionGasLimit, p.batchCompletionSize = 100000, 1
+	p.confirmationTimeout = 5 * time.Second
+	p.maxCompletionAttempts, p.completionRetryDelay = 1, time.Second
+	p.startBlock, p.blockConfirmations, p.logScanChunkSize = big.NewInt(1), 1, 100
+	p.status, p.cursorLock, p.listeners = &processorStatus{}, &cursorLock{}, &jobListeners{}
+	p.pollBackoff, p.pollBackoffMax = &backoff{}, time.Second
+	p.pollInterval = newPollInterval(10*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond)
+	p.loops, p.closeQueue = &sync.WaitGroup{}, &sync.Once{}
+
+	return p, chain, func() {
+		cancel()
+		cleanup()
+	}
+}
+
+// nextTransition waits for the next job state transition seen by recorder, checking that it carries the transaction
+// that emitted the event and clearing it so the transition can be compared
+func nextTransition(t *testing.T, recorder transitionRecorder) JobTransition {
+	select {
+	case transition := <-recorder:
+		assert.NotEqual(t, common.Hash{}, transition.TxHash)
+		transition.TxHash = common.Hash{}
+		return transition
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "timed out waiting for job state transition")
+		return JobTransition{}
+	}
+}
+
+func TestSimulatedJobLifecycle(t *testing.T) {
+	p, chain, cleanup := newSimulatedProcessor(t)
+	defer cleanup()
+
+	recorder := make(transitionRecorder, 10)
+	p.AddJobStateListener(recorder)
+	require.NoError(t, p.StartLoop())
+	defer p.Stop(context.Background())
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	chain.emit(t, testEvents.jobCreatedID, jobAddress, consumer)
+	assert.Equal(t, JobTransition{JobAddress: jobAddress, NewState: jobPendingState}, nextTransition(t, recorder))
+	job, err := db.GetJob(p.boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+	assert.Equal(t, chain.agentAddress.Bytes(), job.AgentAddress)
+
+	chain.emit(t, testEvents.jobFundedID, jobAddress)
+	assert.Equal(t, JobTransition{JobAddress: jobAddress, OldState: jobPendingState, NewState: jobFundedState},
+		nextTransition(t, recorder))
+
+	// The service finishing the job sends a CompleteJob transaction, whose JobCompleted event closes the job
+	signature := make([]byte, 65)
+	signature[0], signature[32], signature[64] = 1, 1, 27
+	p.CompleteJob(jobAddress.Bytes(), signature)
+	assert.Equal(t, JobTransition{JobAddress: jobAddress, OldState: jobFundedState, NewState: jobCompletedState,
+		completedByDaemon: true}, nextTransition(t, recorder))
+
+	job, err = db.GetJob(p.boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	entries, err := db.ListOutbox(p.boltDB)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
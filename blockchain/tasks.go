@@ -4,236 +4,1508 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"math/big"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/bbolt"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
 	"github.com/singnet/snet-daemon/config"
 	"github.com/singnet/snet-daemon/db"
-	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus"
 )
 
-// StartLoops starts background processing for event and job completion routines
-func (p Processor) StartLoop() {
+// StartLoop starts background processing for event and job completion routines. It fails without starting any
+// if the processor has no agent ABI to decode events and pack calls with, i.e. wasn't created by NewProcessor.
+func (p Processor) StartLoop() error {
 	if !p.enabled {
+		return nil
+	}
+
+	if p.events.jobCreatedID == (common.Hash{}) {
+		return errors.New("agent ABI not loaded")
+	}
+
+	if p.dryRun {
+		log.Warn("DRY_RUN enabled; job completion transactions will be logged but not sent")
+	}
+
+	if p.pendingFundings != nil {
+		log.Warn("ACT_ON_PENDING enabled; jobs funded only in the pending block are served and completed before " +
+			"their funding is mined. A funding that is never mined leaves the service call unpaid and its " +
+			"completion transaction reverted.")
+	}
+
+	// Report the persisted cursor rather than 0 until the first poll commits
+	if lastBlock, err := p.LastProcessedBlock(); err != nil {
+		log.WithError(err).Warn("error reading event cursor")
+	} else if lastBlock != nil {
+		lastBlockHeight.Set(float64(lastBlock.Int64()))
+	}
+
+	if metricsListen := config.GetString(config.MetricsListenKey); metricsListen != "" {
+		go serveMetrics(metricsListen)
+	}
+
+	p.runLoop("event processing", p.processEvents)
+
+	if p.registry != nil && p.registry.interval > 0 {
+		p.runLoop("agent registry refresh", p.refreshRegistry)
+	}
+
+	if p.pendingFundings != nil {
+		p.runLoop("pending funding scan", p.scanPending)
+	}
+
+	// An observer only keeps the db in step with the chain
+	if !p.observerMode {
+		p.runCompletionWorkers()
+		p.runLoop("outbox replay", p.replayOutbox)
+		p.runLoop("old job resubmission", p.resubmitOldJobs)
+	}
+
+	if p.jobTTL > 0 {
+		p.runLoop("job pruning", p.pruneJobs)
+	}
+	return nil
+}
+
+// Stop signals the processor loops to exit at their next loop boundary and waits for them until ctx is done. A job
+// completion transaction already sent isn't waited on to be mined: like the jobs still queued, it stays in the outbox
+// and is replayed on the next start, which first checks whether the transaction was mined.
+func (p Processor) Stop(ctx context.Context) error {
+	p.cancel()
+
+	// Senders check the processor context under the read lock, so none can be mid-send once this lock is held
+	p.closeQueue.Do(func() {
+		p.queueMutex.Lock()
+		close(p.jobCompletionQueue)
+		p.queueMutex.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.loops.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "timed out waiting for blockchain processor loops to exit")
+	}
+}
+
+// runLoop runs fn in a goroutine tracked by Stop. The loops recover per iteration; this only keeps a panic outside
+// that from taking down the daemon
+func (p Processor) runLoop(name string, fn func()) {
+	p.loops.Add(1)
+	go func() {
+		defer p.loops.Done()
+		if recoverPanic(name, fn) {
+			log.WithField("loop", name).Error("blockchain processor loop exited after panic")
+		}
+	}()
+}
+
+// recoverPanic runs fn, recovering and logging any panic with its stack so that one bad log or job can't crash the
+// daemon; it reports whether fn panicked
+func recoverPanic(name string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			log.WithField("loop", name).
+				WithField("panic", r).
+				WithField("stack", string(debug.Stack())).
+				Error("recovered from panic in blockchain processor")
+		}
+	}()
+
+	fn()
+	return false
+}
+
+// enqueueJobCompletion records a job in the outbox and submits it to the completion queue unless it is already in
+// flight; once the processor is stopping the job is left for replay on the next start
+func (p Processor) enqueueJobCompletion(jobInfo *jobInfo) {
+	if !p.inFlight.add(jobInfo.jobAddressBytes) {
+		log.WithField("jobAddress", common.BytesToAddress(jobInfo.jobAddressBytes).Hex()).
+			Debug("job completion already in flight; not enqueuing again")
 		return
 	}
 
-	go p.processJobCompletions()
-	go p.processEvents()
-	go p.submitOldJobsForCompletion()
+	p.putOutbox(jobInfo)
+	p.queueJobCompletion(jobInfo)
+}
+
+// tryEnqueueJobCompletion is like enqueueJobCompletion but never waits for room in the queue, reporting false if the
+// job could not be queued because the queue is full
+func (p Processor) tryEnqueueJobCompletion(jobInfo *jobInfo) bool {
+	if !p.inFlight.add(jobInfo.jobAddressBytes) {
+		return true
+	}
+	p.putOutbox(jobInfo)
+
+	p.queueMutex.RLock()
+	defer p.queueMutex.RUnlock()
+
+	if p.ctx.Err() != nil {
+		return true
+	}
+
+	jobInfo.enqueuedAt = time.Now()
+	select {
+	case p.jobCompletionQueue <- jobInfo:
+		jobsQueued.Inc()
+		completionQueueDepth.Set(float64(len(p.jobCompletionQueue)))
+		return true
+	default:
+		p.inFlight.remove(jobInfo.jobAddressBytes)
+		return false
+	}
+}
+
+// queueJobCompletion sends an in-flight job to the completion queue
+func (p Processor) queueJobCompletion(jobInfo *jobInfo) {
+	p.queueMutex.RLock()
+	defer p.queueMutex.RUnlock()
+
+	if p.ctx.Err() != nil {
+		log.WithField("jobAddress", common.BytesToAddress(jobInfo.jobAddressBytes).Hex()).
+			Debug("blockchain processor stopping; deferring job completion until restart")
+		return
+	}
+
+	jobInfo.enqueuedAt = time.Now()
+	select {
+	case p.jobCompletionQueue <- jobInfo:
+		jobsQueued.Inc()
+		completionQueueDepth.Set(float64(len(p.jobCompletionQueue)))
+	case <-p.ctx.Done():
+	}
+}
+
+// runCompletionWorkers starts completionWorkers loops draining the completion queue, at least one. Each job is taken by
+// a single worker, the in-flight set keeping it from being queued twice, and their transactions draw sequential
+// nonces from the shared tracker, so the workers submit in parallel without reusing a job or a nonce.
+func (p Processor) runCompletionWorkers() {
+	workers := p.completionWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		p.runLoop("job completion", p.processJobCompletions)
+	}
 }
 
 func (p Processor) processJobCompletions() {
-	for jobInfo := range p.jobCompletionQueue {
-		log := log.WithFields(log.Fields{"jobAddress": common.BytesToAddress(jobInfo.jobAddressBytes).Hex(),
-			"jobSignature": hex.EncodeToString(jobInfo.jobSignatureBytes)})
+	a := p.agentABI
 
-		v, r, s, err := parseSignature(jobInfo.jobSignatureBytes)
+	// Completions confirmed in the background are waited for, so their outcome is settled once the loop returns
+	var confirming sync.WaitGroup
+	defer confirming.Wait()
 
-		if err != nil {
-			log.WithError(err).Error("error parsing job signature")
+	for next := range p.jobCompletionQueue {
+		// Drain without submitting once stopping; the jobs remain in the outbox
+		if p.ctx.Err() != nil {
+			completionQueueDepth.Set(float64(len(p.jobCompletionQueue)))
+			continue
 		}
 
-		auth := bind.NewKeyedTransactor(p.privateKey)
+		batch := append([]*jobInfo{next}, p.dequeueJobCompletions(p.batchCompletionSize-1)...)
+		completionQueueDepth.Set(float64(len(p.jobCompletionQueue)))
 
-		log.Debug("submitting transaction to complete job")
-		if txn, err := p.agent.CompleteJob(&bind.TransactOpts{
-			From:     common.HexToAddress(p.address),
-			Signer:   auth.Signer,
-			GasLimit: 1000000}, common.BytesToAddress(jobInfo.jobAddressBytes), v, r, s); err != nil {
-			log.WithError(err).Error("error submitting transaction to complete job")
-		} else {
-			isPending := true
+		for _, jobInfo := range batch {
+			queueLatency := time.Since(jobInfo.enqueuedAt)
+			completionQueueLatency.Observe(queueLatency.Seconds())
+			log.WithField("jobAddress", common.BytesToAddress(jobInfo.jobAddressBytes).Hex()).
+				WithField("queueLatency", queueLatency).
+				Debug("submitting job completion")
+		}
 
-			for {
-				if _, isPending, _ = p.ethClient.TransactionByHash(context.Background(), txn.Hash()); !isPending {
-					break
+		if len(batch) > 1 {
+			var batched bool
+			if p.confirmations == nil || p.confirmations.acquire(p.ctx) {
+				recoverPanic("batched job completion", func() { batched = p.processJobCompletionBatch(a, batch) })
+				if p.confirmations != nil {
+					p.confirmations.release()
 				}
-				time.Sleep(time.Second * 1)
+			}
+			if batched {
+				continue
 			}
 		}
+
+		for _, jobInfo := range batch {
+			// Without a limit each completion is confirmed before the next is sent
+			if p.confirmations == nil {
+				p.processJobCompletion(a, jobInfo)
+				continue
+			}
+
+			// Once stopping, the job is left in the outbox for replay
+			if !p.confirmations.acquire(p.ctx) {
+				continue
+			}
+			confirm := p.startJobCompletion(a, jobInfo)
+			if confirm == nil {
+				p.confirmations.release()
+				continue
+			}
+			confirming.Add(1)
+			go func() {
+				defer confirming.Done()
+				defer p.confirmations.release()
+				confirm()
+			}()
+		}
 	}
 }
 
-func (p Processor) processEvents() {
-	sleepSecs := config.GetDuration(config.PollSleepKey)
-	agentContractAddress := config.GetString(config.AgentContractAddressKey)
+// dequeueJobCompletions takes up to n more jobs from the completion queue without waiting for any
+func (p Processor) dequeueJobCompletions(n int) []*jobInfo {
+	var jobInfos []*jobInfo
+	for len(jobInfos) < n {
+		select {
+		case jobInfo, ok := <-p.jobCompletionQueue:
+			if !ok {
+				return jobInfos
+			}
+			jobInfos = append(jobInfos, jobInfo)
+		default:
+			return jobInfos
+		}
+	}
+	return jobInfos
+}
 
-	a, err := abi.JSON(strings.NewReader(AgentABI))
+// processJobCompletion submits a single job's completion, scheduling a retry if it fails
+func (p Processor) processJobCompletion(a abi.ABI, jobInfo *jobInfo) {
+	if confirm := p.startJobCompletion(a, jobInfo); confirm != nil {
+		confirm()
+	}
+}
+
+// startJobCompletion sends a single job's completion, returning a func that waits for it to be confirmed and settles
+// the outcome. It returns nil once the outcome is already settled, e.g. because the transaction failed to send.
+func (p Processor) startJobCompletion(a abi.ABI, jobInfo *jobInfo) func() {
+	var sent *sentCompletion
+	var err error
+	if recoverPanic("job completion", func() { sent, err = p.sendJobCompletion(p.ctx, a, jobInfo) }) ||
+		err != nil || sent == nil {
+		p.settleJobCompletion(jobInfo, err)
+		return nil
+	}
+
+	return func() {
+		var err error
+		recoverPanic("job completion", func() { err = p.confirmJobCompletion(p.ctx, sent) })
+		p.settleJobCompletion(jobInfo, err)
+	}
+}
+
+// settleJobCompletion reports a failed completion, scheduling a retry, and clears the job from the outbox and the
+// in-flight set unless it is to be retried
+func (p Processor) settleJobCompletion(jobInfo *jobInfo, err error) {
+	recoverPanic("job completion", func() {
+		// A job being retried, or left for replay by a shutdown, stays in flight and in the outbox
+		pending := false
+		defer func() {
+			if !pending {
+				p.deleteOutbox(jobInfo.jobAddressBytes)
+				p.inFlight.remove(jobInfo.jobAddressBytes)
+			}
+		}()
+
+		// A transaction that may have been sent is left for the replay to check; the caller already has its hash
+		if err == errSubmissionCancelled {
+			pending = true
+			return
+		}
+		// One not mined in time stays in the outbox too, but leaves the in-flight set for the old job scan to pick up
+		if err == errCompletionUnconfirmed {
+			pending = true
+			p.inFlight.remove(jobInfo.jobAddressBytes)
+			return
+		}
+		if err != nil {
+			jobInfo.report(common.Hash{}, err)
+			p.reportCompletionError(common.BytesToAddress(jobInfo.jobAddressBytes), err)
 
+			// A submission cut short by shutdown isn't a failed attempt; the job stays in the outbox for replay
+			if p.ctx.Err() != nil {
+				pending = true
+				return
+			}
+			pending = p.retryJobCompletion(jobInfo, err)
+		}
+	})
+}
+
+// processJobCompletionBatch submits the completions of several jobs in a single transaction, reporting whether it
+// succeeded. On failure the jobs are left queued for the caller to submit one by one, so a batch is all or nothing.
+func (p Processor) processJobCompletionBatch(a abi.ABI, jobInfos []*jobInfo) bool {
+	err := p.submitJobCompletionBatch(p.ctx, a, jobInfos)
+	// The batch may have been sent, so it must not be sent again one by one; the jobs stay in the outbox for replay, or
+	// for the old job scan once the transaction wasn't mined in time
+	if err == errSubmissionCancelled {
+		return true
+	}
+	if err == errCompletionUnconfirmed {
+		for _, jobInfo := range jobInfos {
+			p.inFlight.remove(jobInfo.jobAddressBytes)
+		}
+		return true
+	}
 	if err != nil {
-		log.WithError(err).Error("error parsing agent ABI")
-		return
+		log.WithError(err).WithField("jobs", len(jobInfos)).
+			Warn("error submitting batched job completion; falling back to single submission")
+		return false
+	}
+
+	for _, jobInfo := range jobInfos {
+		p.deleteOutbox(jobInfo.jobAddressBytes)
+		p.inFlight.remove(jobInfo.jobAddressBytes)
+	}
+	return true
+}
+
+// jobEvents holds the topic IDs of the agent contract's job events, and the contract ABI to decode them with
+type jobEvents struct {
+	jobCreatedID   common.Hash
+	jobFundedID    common.Hash
+	jobCompletedID common.Hash
+	agentABI       abi.ABI
+}
+
+// parseAgentABI parses the agent contract ABI, which must declare the job events the processor follows
+func parseAgentABI(agentABI string) (abi.ABI, error) {
+	a, err := abi.JSON(strings.NewReader(agentABI))
+	if err != nil {
+		return a, errors.Wrap(err, "error parsing agent ABI")
+	}
+
+	for _, event := range []string{"JobCreated", "JobFunded", "JobCompleted"} {
+		if _, ok := a.Events[event]; !ok {
+			return a, errors.Errorf("agent ABI has no %v event", event)
+		}
+	}
+	return a, nil
+}
+
+func newJobEvents(a abi.ABI) jobEvents {
+	return jobEvents{
+		jobCreatedID:   a.Events["JobCreated"].Id(),
+		jobFundedID:    a.Events["JobFunded"].Id(),
+		jobCompletedID: a.Events["JobCompleted"].Id(),
+		agentABI:       a,
 	}
+}
 
-	jobCreatedID := a.Events["JobCreated"].Id()
-	jobFundedID := a.Events["JobFunded"].Id()
-	jobCompletedID := a.Events["JobCompleted"].Id()
+// filterQuery matches all job events emitted by the agent; topic alternatives in the first position match any of them
+func (e jobEvents) filterQuery(agentAddresses []common.Address) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: agentAddresses,
+		Topics:    [][]common.Hash{{e.jobCreatedID, e.jobFundedID, e.jobCompletedID}},
+	}
+}
+
+func (p Processor) processEvents() {
+	events := p.events
 
 	for {
-		time.Sleep(sleepSecs)
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(p.nextPollDelay()):
+		}
 
-		// We have to do a raw call because the standard method of ethClient.HeaderByNumber(ctx, nil) errors on
-		// unmarshaling the response currently. See https://github.com/ethereum/go-ethereum/issues/3230
-		var currentBlockHex string
-		if err = p.rawClient.CallContext(context.Background(), &currentBlockHex, "eth_blockNumber"); err != nil {
-			log.WithError(err).Error("error determining current block")
-			continue
+		if recoverPanic("event processing", func() { p.processEventsOnce(events) }) {
+			p.pollBackoff.fail()
+		}
+	}
+}
+
+// nextPollDelay returns the sleep before the next event poll: the adaptive poll interval spread by the poll jitter,
+// then backed off after consecutive failures
+func (p Processor) nextPollDelay() time.Duration {
+	return p.pollBackoff.delay(jitter(p.pollInterval.current(), p.pollJitter), p.pollBackoffMax)
+}
+
+// processEventsOnce runs a single iteration of the event loop: unless the circuit breaker is open, a poll up to the
+// newest confirmed block followed, if enabled and the poll succeeded, by streaming events until the subscription drops
+func (p Processor) processEventsOnce(events jobEvents) {
+	// An open circuit breaker makes no calls at all until its cooldown is over
+	if !p.breaker.allow(time.Now()) {
+		log.Debug("eth RPC circuit breaker open; skipping event poll")
+		return
+	}
+
+	// A resync from here on must reach the subscription, even one made before the poll sees the reset cursor
+	generation := p.cursorGeneration()
+
+	// Back off on consecutive RPC failures rather than hammering a struggling node at the normal cadence
+	if p.pollEvents(events) {
+		p.pollBackoff.succeed()
+		p.breaker.succeed()
+	} else {
+		p.pollBackoff.fail()
+		p.breaker.fail(time.Now())
+		log.WithField("consecutiveFailures", p.pollBackoff.consecutiveFailures()).Debug("backing off event polling")
+		return
+	}
+
+	// A stream would move the cursor past the blocks a held back poll has yet to scan
+	if p.subscribeEvents && !p.status.isBackfilling() {
+		if err := p.streamEvents(events, generation); err != nil {
+			log.WithError(err).Warn("job event subscription dropped; falling back to polling")
+		}
+	}
+}
+
+// pollEvents processes all job events between the event cursor and the newest confirmed block, reporting whether the
+// poll succeeded. A block is confirmed once it has the configured number of confirmations or, with the finalized tag
+// strategy, once the node reports it finalized.
+func (p Processor) pollEvents(events jobEvents) bool {
+	p.cursorLock.lock()
+	defer p.cursorLock.unlock()
+
+	ctx, cancel := p.rpcContext()
+	var currentBlock *big.Int
+	var currentBlockHash common.Hash
+	var err error
+	if p.finalizedTag {
+		currentBlock, err = p.finalizedBlock(ctx)
+	} else {
+		currentBlock, currentBlockHash, err = p.chainHead(ctx)
+	}
+	cancel()
+	if err != nil {
+		log.WithError(err).Error("error determining current block")
+		p.reportEventError(err)
+		return false
+	}
+
+	// Only scan up to the newest block with the configured number of confirmations; the chain head has one, so
+	// from here on currentBlock refers to that confirmed block rather than the head
+	if !p.finalizedTag && p.blockConfirmations > 1 {
+		currentBlockHash = common.Hash{}
+		currentBlock.Sub(currentBlock, big.NewInt(p.blockConfirmations-1))
+		if currentBlock.Sign() < 0 {
+			return true
+		}
+	}
+
+	// Without a persisted cursor, begin at the configured start block (e.g. the agent's deployment block) so jobs
+	// created before the daemon first ran are picked up; otherwise only new blocks are scanned
+	lastBlock := new(big.Int).Sub(currentBlock, new(big.Int).SetUint64(1))
+	if p.startBlock != nil {
+		lastBlock.Sub(p.startBlock, big.NewInt(1))
+	}
+	var lastBlockHash common.Hash
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.ChainBucketName)
+		lastBlockBytes := bucket.Get(db.LastBlockKey)
+		if lastBlockBytes != nil {
+			lastBlock = new(big.Int).SetBytes(lastBlockBytes)
 		}
+		lastBlockHash = common.BytesToHash(bucket.Get(db.LastBlockHashKey))
+		return nil
+	})
 
-		currentBlockBytes := common.FromHex(currentBlockHex)
-		currentBlock := new(big.Int).SetBytes(currentBlockBytes)
+	// If the block we last processed is no longer part of the canonical chain, events we applied may have been
+	// reorganized away and replacements emitted; walk the cursor back and re-scan
+	if lastBlockHash != (common.Hash{}) {
+		ctx, cancel := p.rpcContext()
+		canonicalHash, err := p.blockHash(ctx, lastBlock)
+		cancel()
+		if err != nil {
+			log.WithError(err).Error("error retrieving last processed block")
+			p.reportEventError(err)
+			return false
+		}
 
-		lastBlock := new(big.Int).Sub(currentBlock, new(big.Int).SetUint64(1))
-		p.boltDB.View(func(tx *bolt.Tx) error {
-			bucket := tx.Bucket(db.ChainBucketName)
-			lastBlockBytes := bucket.Get([]byte("lastBlock"))
-			if lastBlockBytes != nil {
-				lastBlock = new(big.Int).SetBytes(lastBlockBytes)
+		if canonicalHash != lastBlockHash {
+			rewoundBlock := new(big.Int).Sub(lastBlock, big.NewInt(p.reorgRewindDepth))
+			if rewoundBlock.Sign() < 0 {
+				rewoundBlock.SetUint64(0)
 			}
-			return nil
-		})
 
-		// Don't re-scan lastBlock
-		fromBlock := new(big.Int).Add(lastBlock, new(big.Int).SetUint64(1))
-
-		// If fromBlock <= currentBlock
-		// TODO(aiden) invert logic and early return
-		if fromBlock.Cmp(currentBlock) <= 0 {
-			if jobCreatedLogs, err := p.ethClient.FilterLogs(context.Background(), ethereum.FilterQuery{
-				FromBlock: fromBlock,
-				ToBlock:   currentBlock,
-				Addresses: []common.Address{common.HexToAddress(agentContractAddress)},
-				Topics:    [][]common.Hash{{jobCreatedID}}}); err == nil {
-				if len(jobCreatedLogs) > 0 {
-					p.boltDB.Update(func(tx *bolt.Tx) error {
-						bucket := tx.Bucket(db.JobBucketName)
-						for _, jobCreatedLog := range jobCreatedLogs {
-							job := &db.Job{}
-							jobAddressBytes := common.BytesToAddress(jobCreatedLog.Data[0:32]).Bytes()
-							jobConsumerBytes := common.BytesToAddress(jobCreatedLog.Data[32:64]).Bytes()
-
-							log.WithFields(log.Fields{
-								"jobAddress": common.BytesToAddress(jobAddressBytes).Hex(),
-							}).Debug("received JobCreated event; saving to db")
-
-							jobBytes := bucket.Get(jobAddressBytes)
-							if jobBytes != nil {
-								json.Unmarshal(jobBytes, job)
-							}
-							job.JobAddress = jobAddressBytes
-							job.Consumer = jobConsumerBytes
-							job.JobState = jobPendingState
-							if jobBytes, err := json.Marshal(job); err == nil {
-								if err = bucket.Put(jobAddressBytes, jobBytes); err != nil {
-									log.WithError(err).Error("error putting job to db")
-								}
-							} else {
-								log.WithError(err).Error("error marshaling job")
-							}
-						}
-						return nil
-					})
-				}
-			} else {
-				log.WithError(err).Error("error getting job created logs")
-			}
-
-			if jobFundedLogs, err := p.ethClient.FilterLogs(context.Background(), ethereum.FilterQuery{
-				FromBlock: fromBlock,
-				ToBlock:   currentBlock,
-				Addresses: []common.Address{common.HexToAddress(agentContractAddress)},
-				Topics:    [][]common.Hash{{jobFundedID}}}); err == nil {
-				if len(jobFundedLogs) > 0 {
-					p.boltDB.Update(func(tx *bolt.Tx) error {
-						bucket := tx.Bucket(db.JobBucketName)
-						for _, jobFundedLog := range jobFundedLogs {
-							job := &db.Job{}
-							jobAddressBytes := common.BytesToAddress(jobFundedLog.Data[0:32]).Bytes()
-
-							log.WithFields(log.Fields{
-								"jobAddress": common.BytesToAddress(jobAddressBytes).Hex(),
-							}).Debug("received JobFunded event; saving to db")
-
-							jobBytes := bucket.Get(jobAddressBytes)
-							if jobBytes != nil {
-								json.Unmarshal(jobBytes, job)
-							}
-							job.JobAddress = jobAddressBytes
-							job.JobState = jobFundedState
-							if jobBytes, err := json.Marshal(job); err == nil {
-								if err = bucket.Put(jobAddressBytes, jobBytes); err != nil {
-									log.WithError(err).Error("error putting job to db")
-								}
-							} else {
-								log.WithError(err).Error("error marshaling job")
-							}
-						}
-						return nil
-					})
+			log.WithFields(logrus.Fields{
+				"lastBlock":     lastBlock,
+				"lastBlockHash": lastBlockHash.Hex(),
+				"canonicalHash": canonicalHash.Hex(),
+				"rewoundBlock":  rewoundBlock,
+			}).Warn("chain reorganization detected; re-scanning events")
+			lastBlock = rewoundBlock
+
+			if err := p.boltDB.Update(func(tx *bolt.Tx) error {
+				if err := deleteArchivedLogs(tx.Bucket(db.LogArchiveBucketName), lastBlock.Uint64()); err != nil {
+					return err
 				}
-			} else {
-				log.WithError(err).Error("error getting job funded logs")
-			}
-
-			if jobCompletedLogs, err := p.ethClient.FilterLogs(context.Background(), ethereum.FilterQuery{
-				FromBlock: fromBlock,
-				ToBlock:   currentBlock,
-				Addresses: []common.Address{common.HexToAddress(agentContractAddress)},
-				Topics:    [][]common.Hash{{jobCompletedID}}}); err == nil {
-				if len(jobCompletedLogs) > 0 {
-					p.boltDB.Update(func(tx *bolt.Tx) error {
-						bucket := tx.Bucket(db.JobBucketName)
-						for _, jobCompletedLog := range jobCompletedLogs {
-							jobAddressBytes := common.BytesToAddress(jobCompletedLog.Data[0:32]).Bytes()
-
-							log.WithFields(log.Fields{
-								"jobAddress": common.BytesToAddress(jobAddressBytes).Hex(),
-							}).Debug("received JobCompleted event; deleting from db")
-
-							if err = bucket.Delete(jobAddressBytes); err != nil {
-								log.WithError(err).Error("error deleting job from db")
-							}
-						}
-						return nil
-					})
+				return restoreCompletedJobs(tx.Bucket(db.JobBucketName), lastBlock)
+			}); err != nil {
+				log.WithError(err).Error("error restoring jobs completed in reorganized blocks")
+				p.reportEventError(err)
+				return false
+			}
+		}
+	}
+
+	// A cursor ahead of the confirmed block (e.g. after BLOCK_CONFIRMATIONS was raised) counts as caught up
+	behind := new(big.Int).Sub(currentBlock, lastBlock).Int64()
+	if behind < 0 {
+		behind = 0
+	}
+	blocksBehind.Set(float64(behind))
+	p.status.recordBlocksBehind(behind)
+	if p.blocksBehindWarning > 0 && behind > p.blocksBehindWarning {
+		log.WithFields(logrus.Fields{
+			"lastBlock":    lastBlock,
+			"currentBlock": currentBlock,
+			"blocksBehind": behind,
+		}).Warn("event processing is far behind the chain; catching up")
+	}
+
+	// Don't re-scan lastBlock
+	fromBlock := new(big.Int).Add(lastBlock, new(big.Int).SetUint64(1))
+
+	// Nothing to do until the chain advances past the cursor
+	if fromBlock.Cmp(currentBlock) > 0 {
+		p.pollInterval.caughtUp()
+		p.status.recordBackfill(false)
+		p.status.recordPoll()
+		return true
+	}
+
+	// Far behind (e.g. on a first scan from an old START_BLOCK) only a bounded span is scanned per poll, so the loop
+	// yields between polls and the cursor is persisted as the backfill advances; the following polls scan the rest
+	backfilling := false
+	if p.maxBlocksPerPoll > 0 {
+		if limit := new(big.Int).Add(fromBlock, big.NewInt(p.maxBlocksPerPoll-1)); limit.Cmp(currentBlock) < 0 {
+			log.WithFields(logrus.Fields{
+				"fromBlock":    fromBlock,
+				"toBlock":      limit,
+				"currentBlock": currentBlock,
+			}).Debug("backfilling job events; scanning up to MAX_BLOCKS_PER_POLL blocks this poll")
+			currentBlock, currentBlockHash, backfilling = limit, common.Hash{}, true
+		}
+	}
+
+	// Scan in chunks so large gaps (e.g. after downtime) stay within node getLogs limits. Each chunk's events
+	// and cursor commit in one transaction, so a chunk is applied exactly once and progress isn't lost when a
+	// later chunk fails
+	for chunkFrom := fromBlock; chunkFrom.Cmp(currentBlock) <= 0; {
+		chunkTo := new(big.Int).Add(chunkFrom, big.NewInt(p.logScanChunkSize-1))
+		if chunkTo.Cmp(currentBlock) > 0 {
+			chunkTo.Set(currentBlock)
+		}
+
+		// The head's hash is already known, so the last chunk can skip looking it up
+		chunkToHash := common.Hash{}
+		if chunkTo.Cmp(currentBlock) == 0 {
+			chunkToHash = currentBlockHash
+		}
+
+		if err := p.processEventRange(events, chunkFrom, chunkTo, chunkToHash); err != nil {
+			log.WithError(err).WithFields(logrus.Fields{
+				"fromBlock": chunkFrom,
+				"toBlock":   chunkTo,
+			}).Error("error processing job events")
+			p.reportEventError(err)
+			return false
+		}
+
+		chunkFrom = new(big.Int).Add(chunkTo, big.NewInt(1))
+	}
+
+	p.pollInterval.advanced()
+	p.status.recordBackfill(backfilling)
+	p.status.recordPoll()
+	return true
+}
+
+// streamEvents catches job events as they are emitted via a log subscription, returning once the subscription fails.
+// The blocks between the cursor and the head when the subscription starts are first caught up on with a log query, as
+// the subscription only delivers logs from then on; a streamed log already applied, by the catch-up or earlier in the
+// stream, is skipped. Logs are applied as soon as they arrive, which is why config.Validate refuses subscriptions with
+// a confirmation depth or the finalized tag, and the "auto" mode polls instead. The cursor is moved forward to just
+// before each log's block, so a poll that follows a dropped subscription picks up where the stream left off. The
+// subscription is dropped if the cursor has been reset since the given resync generation, or if the registry has
+// resolved a new agent to watch.
+func (p Processor) streamEvents(events jobEvents, generation uint64) error {
+	var registryVersion uint64
+	if p.registry != nil {
+		registryVersion = p.registry.currentVersion()
+	}
+
+	jobLogs := make(chan types.Log)
+	sub, err := p.ethClient.SubscribeFilterLogs(context.Background(), events.filterQuery(p.agentAddresses()), jobLogs)
+	if err != nil {
+		return errors.Wrap(err, "error subscribing to job logs")
+	}
+	defer sub.Unsubscribe()
+
+	log.Debug("subscribed to job events")
+
+	// Subscribing first leaves no block that neither the catch-up nor the stream covers
+	latestBlock, applied, err := p.catchUpEvents(events, generation)
+	if err != nil {
+		return err
+	}
+	p.status.recordPoll()
+
+	// A live subscription counts as a healthy event loop even when no events arrive
+	ticker := time.NewTicker(p.pollSleep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case <-ticker.C:
+			if p.cursorResetSince(generation) {
+				return errors.New("event cursor reset; resubscribing after re-scan")
+			}
+			if p.registry != nil && p.registry.changedSince(registryVersion) {
+				return errors.New("agent registry changed; resubscribing for the new agent")
+			}
+			p.status.recordPoll()
+
+			// A log can only be delivered again around the blocks still being streamed
+			for id, block := range applied {
+				if block+uint64(p.reorgRewindDepth) < latestBlock {
+					delete(applied, id)
 				}
-			} else {
-				log.WithError(err).Error("error getting job completed logs")
+			}
+		case jobLog := <-jobLogs:
+			if jobLog.Removed {
+				// The block was reorganized away; the poll after the next reorg check re-scans it
+				log.WithField("blockNumber", jobLog.BlockNumber).Warn("received removed job log; ignoring")
+				continue
 			}
 
-			p.boltDB.Update(func(tx *bolt.Tx) error {
-				bucket := tx.Bucket(db.ChainBucketName)
-				if err = bucket.Put([]byte("lastBlock"), currentBlockBytes); err != nil {
-					log.WithError(err).Error("error putting current block to db")
+			// The catch-up and the stream overlap on the blocks mined while the subscription was being set up, and a
+			// node may deliver a log again, e.g. after reconnecting
+			id := logID{jobLog.BlockHash, jobLog.TxHash, jobLog.Index}
+			if _, ok := applied[id]; ok {
+				log.WithField("txHash", jobLog.TxHash.Hex()).
+					WithField("logIndex", jobLog.Index).
+					Debug("skipping streamed job log already applied")
+				continue
+			}
+
+			// Later logs in the same block may still be on their way, so the cursor only covers the previous block
+			if jobLog.BlockNumber > 0 {
+				if err := p.commitStreamedEvent(events, jobLog, generation); err != nil {
+					return err
 				}
-				return nil
-			})
+			}
+			applied[id] = jobLog.BlockNumber
+			if jobLog.BlockNumber > latestBlock {
+				latestBlock = jobLog.BlockNumber
+			}
 		}
 	}
 }
 
+// catchUpEvents applies the job events between the event cursor and the chain head with a log query and moves the
+// cursor to the head, unless a resync has moved the cursor since the given generation. It returns the head and the
+// logs applied with their blocks, so the stream that follows can skip those it delivers again.
+func (p Processor) catchUpEvents(events jobEvents, generation uint64) (uint64, map[logID]uint64, error) {
+	p.cursorLock.lock()
+	defer p.cursorLock.unlock()
+
+	if p.cursorLock.resetSince(generation) {
+		return 0, nil, errors.New("event cursor reset; resubscribing after re-scan")
+	}
+
+	ctx, cancel := p.rpcContext()
+	defer cancel()
+	head, headHash, err := p.chainHead(ctx)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "error retrieving chain head to catch up to")
+	}
+	lastBlock, err := p.LastProcessedBlock()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	fromBlock := new(big.Int).Set(head)
+	if lastBlock != nil {
+		fromBlock.Add(lastBlock, big.NewInt(1))
+	}
+	if fromBlock.Cmp(head) > 0 {
+		return head.Uint64(), make(map[logID]uint64), nil
+	}
+
+	query := events.filterQuery(p.agentAddresses())
+	query.FromBlock, query.ToBlock = fromBlock, head
+	jobLogs, err := p.ethClient.FilterLogs(ctx, query)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "error getting job logs to catch up on")
+	}
+	jobLogs = logsInRange(jobLogs, fromBlock, head)
+
+	if headHash != (common.Hash{}) {
+		err = p.commitJobLogs(events, jobLogs, head, headHash)
+	} else {
+		err = p.commitEvents(events, jobLogs, head)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	caughtUp := make(map[logID]uint64, len(jobLogs))
+	for _, jobLog := range jobLogs {
+		caughtUp[logID{jobLog.BlockHash, jobLog.TxHash, jobLog.Index}] = jobLog.BlockNumber
+	}
+	log.WithFields(logrus.Fields{
+		"fromBlock": fromBlock,
+		"toBlock":   head,
+		"logs":      len(jobLogs),
+	}).Debug("caught up on job events before streaming")
+	return head.Uint64(), caughtUp, nil
+}
+
+// commitStreamedEvent applies a streamed job log and moves the cursor to just before its block, unless the cursor is
+// already past that, e.g. for a log delivered out of order, or a resync has moved the cursor since the subscription
+// started; the subscription must then be dropped so the poll that follows re-scans from the resync point. It must also
+// be dropped if the log fails to commit, as a later log would otherwise move the cursor past it.
+func (p Processor) commitStreamedEvent(events jobEvents, jobLog types.Log, generation uint64) error {
+	p.cursorLock.lock()
+	defer p.cursorLock.unlock()
+
+	if p.cursorLock.resetSince(generation) {
+		return errors.New("event cursor reset; resubscribing after re-scan")
+	}
+
+	block := new(big.Int).SetUint64(jobLog.BlockNumber - 1)
+	lastBlock, err := p.LastProcessedBlock()
+	if err != nil {
+		return errors.Wrap(err, "error committing streamed job event")
+	}
+	if lastBlock != nil && lastBlock.Cmp(block) > 0 {
+		block = lastBlock
+	}
+
+	err = p.commitEvents(events, []types.Log{jobLog}, block)
+	return errors.Wrap(err, "error committing streamed job event")
+}
+
+// processEventRange applies all job events in [fromBlock, toBlock] to the db and advances the cursor to toBlock. The
+// hash of toBlock is looked up unless toBlockHash is given.
+func (p Processor) processEventRange(events jobEvents, fromBlock, toBlock *big.Int, toBlockHash common.Hash) error {
+	return p.splitEventRange(events, fromBlock, toBlock, toBlockHash, 0)
+}
+
+// splitEventRange is processEventRange for a range already halved splits times. A range whose logs the node refuses
+// to return in one response is split in two and each half processed in turn, until the split limit is reached.
+func (p Processor) splitEventRange(events jobEvents, fromBlock, toBlock *big.Int, toBlockHash common.Hash,
+	splits int) error {
+	query := events.filterQuery(p.agentAddresses())
+	query.FromBlock, query.ToBlock = fromBlock, toBlock
+
+	ctx, cancel := p.rpcContext()
+	jobLogs, err := p.ethClient.FilterLogs(ctx, query)
+	cancel()
+	if err != nil && isTooManyLogsError(err) && splits < p.logScanMaxSplits && fromBlock.Cmp(toBlock) < 0 {
+		midBlock := new(big.Int).Add(fromBlock, toBlock)
+		midBlock.Rsh(midBlock, 1)
+
+		log.WithError(err).WithFields(logrus.Fields{
+			"fromBlock": fromBlock,
+			"toBlock":   toBlock,
+			"splits":    splits + 1,
+		}).Info("too many job logs for one query; splitting block range")
+
+		if err := p.splitEventRange(events, fromBlock, midBlock, common.Hash{}, splits+1); err != nil {
+			return err
+		}
+		return p.splitEventRange(events, new(big.Int).Add(midBlock, big.NewInt(1)), toBlock, toBlockHash, splits+1)
+	}
+	if err != nil && isMissingHistoryError(err) {
+		return p.skipMissingHistory(events, fromBlock, toBlock, toBlockHash, splits, err)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error getting job logs")
+	}
+	jobLogs = logsInRange(jobLogs, fromBlock, toBlock)
+
+	if toBlockHash != (common.Hash{}) {
+		return p.commitJobLogs(events, jobLogs, toBlock, toBlockHash)
+	}
+	return p.commitEvents(events, jobLogs, toBlock)
+}
+
+// skipMissingHistory handles a range whose logs the node couldn't return because it has pruned the blocks' history.
+// Retrying won't help, so unless HISTORY_SKIP_TO_BLOCK allows the range to be skipped, the returned error says what
+// the operator needs to change. Otherwise the part of the range below HISTORY_SKIP_TO_BLOCK is skipped, losing any job
+// events in it, and the rest is scanned as usual.
+func (p Processor) skipMissingHistory(events jobEvents, fromBlock, toBlock *big.Int, toBlockHash common.Hash,
+	splits int, err error) error {
+	if p.historySkipTo == nil || fromBlock.Cmp(p.historySkipTo) >= 0 {
+		return errors.Wrapf(err, "node has no history for blocks %v to %v; use an archive node, or set START_BLOCK "+
+			"or HISTORY_SKIP_TO_BLOCK past the pruned blocks", fromBlock, toBlock)
+	}
+
+	skipTo := new(big.Int).Sub(p.historySkipTo, big.NewInt(1))
+	if skipTo.Cmp(toBlock) > 0 {
+		skipTo = toBlock
+	}
+
+	log.WithError(err).WithFields(logrus.Fields{
+		"fromBlock": fromBlock,
+		"toBlock":   skipTo,
+	}).Warn("node has no history for blocks; skipping their job events up to HISTORY_SKIP_TO_BLOCK")
+
+	// The skipped blocks' hashes are just as unavailable, so the cursor is left without one
+	if err := p.commitJobLogs(events, nil, skipTo, common.Hash{}); err != nil {
+		return err
+	}
+	if skipTo.Cmp(toBlock) == 0 {
+		return nil
+	}
+	return p.splitEventRange(events, new(big.Int).Add(skipTo, big.NewInt(1)), toBlock, toBlockHash, splits)
+}
+
+// logsInRange drops any logs outside [fromBlock, toBlock] that a provider returned for a query of that range. They
+// belong to a neighbouring range, which applies them itself, so keeping them would apply them twice in a poll.
+func logsInRange(jobLogs []types.Log, fromBlock, toBlock *big.Int) []types.Log {
+	from, to := fromBlock.Uint64(), toBlock.Uint64()
+	inRange := make([]types.Log, 0, len(jobLogs))
+	for _, jobLog := range jobLogs {
+		if jobLog.BlockNumber < from || jobLog.BlockNumber > to {
+			log.WithField("txHash", jobLog.TxHash.Hex()).
+				WithField("blockNumber", jobLog.BlockNumber).
+				WithField("fromBlock", fromBlock).
+				WithField("toBlock", toBlock).
+				Warn("skipping job log outside the queried block range")
+			continue
+		}
+		inRange = append(inRange, jobLog)
+	}
+	return inRange
+}
+
+// commitEvents applies jobLogs and advances the event cursor to block, looking up the block's hash for reorg detection
+func (p Processor) commitEvents(events jobEvents, jobLogs []types.Log, block *big.Int) error {
+	ctx, cancel := p.rpcContext()
+	blockHash, err := p.blockHash(ctx, block)
+	cancel()
+	if err != nil {
+		log.WithError(err).Error("error retrieving current block hash")
+	}
+
+	return p.commitJobLogs(events, jobLogs, block, blockHash)
+}
+
+// commitJobLogs applies jobLogs to the db, records the resulting transitions of the jobs it knows in their histories,
+// once each however often their events are re-scanned, and advances the event cursor in a single transaction. Either
+// the events and the new cursor commit together or neither does, in which case the range is re-scanned on the next
+// poll.
+func (p Processor) commitJobLogs(events jobEvents, jobLogs []types.Log, block *big.Int, blockHash common.Hash) error {
+	var processed map[string]int
+	var transitions []JobTransition
+
+	if err := p.boltDB.Update(func(tx *bolt.Tx) (err error) {
+		if p.archiveLogs {
+			if err := archiveJobLogs(tx.Bucket(db.LogArchiveBucketName), jobLogs); err != nil {
+				return err
+			}
+		}
+		if processed, transitions, err = applyJobLogs(tx.Bucket(db.JobBucketName), events, jobLogs); err != nil {
+			return err
+		}
+		for _, transition := range transitions {
+			if transition.unknown {
+				continue
+			}
+			if err := db.AppendJobTransition(tx, transition.JobAddress.Bytes(), db.HistoryEntry{Timestamp: time.Now(),
+				FromState: transition.OldState, ToState: transition.NewState,
+				TxHash: transition.TxHash.Bytes()}); err != nil {
+				return err
+			}
+		}
+		if err := deleteConfirmedJobs(tx.Bucket(db.JobBucketName), block, p.deleteConfirmations); err != nil {
+			return err
+		}
+		return putCursor(tx.Bucket(db.ChainBucketName), block, blockHash)
+	}); err != nil {
+		return errors.Wrap(err, "error committing job events")
+	}
+
+	for event, count := range processed {
+		eventsProcessed.WithLabelValues(event).Add(float64(count))
+	}
+	for _, transition := range transitions {
+		if transition.NewState != jobCompletedState {
+			continue
+		}
+		if transition.completedByDaemon {
+			jobsCompleted.WithLabelValues("daemon").Inc()
+		} else {
+			jobsCompleted.WithLabelValues("other").Inc()
+		}
+	}
+	lastBlockHeight.Set(float64(block.Int64()))
+	p.listeners.emit(transitions)
+
+	return nil
+}
+
+// deleteConfirmedJobs deletes the completed jobs whose JobCompleted event is at least confirmations blocks behind
+// block, the block the event cursor is advancing to. With no confirmations required, every completed job is deleted.
+func deleteConfirmedJobs(bucket *bolt.Bucket, block *big.Int, confirmations int64) error {
+	var confirmed [][]byte
+	if err := bucket.ForEach(func(k, v []byte) error {
+		job := &db.Job{}
+		if err := json.Unmarshal(v, job); err != nil || job.CompletedAtBlock == nil {
+			return nil
+		}
+		if confirmations == 0 || new(big.Int).SetUint64(*job.CompletedAtBlock+uint64(confirmations)).Cmp(block) <= 0 {
+			confirmed = append(confirmed, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error reading completed jobs")
+	}
+
+	// Bolt doesn't allow deleting keys while iterating over a bucket
+	for _, jobAddressBytes := range confirmed {
+		log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+			Debug("job completion confirmed; deleting from db")
+		if err := bucket.Delete(jobAddressBytes); err != nil {
+			return errors.Wrap(err, "error deleting job from db")
+		}
+	}
+	return nil
+}
+
+// restoreCompletedJobs clears the completion of jobs whose JobCompleted event, or mined completion transaction, is
+// after block, for when the cursor is moved back to block and the events after it are to be re-scanned. A completion
+// that is still on the canonical chain is seen again by the re-scan; one that was reorganized away leaves the job to
+// be completed again.
+func restoreCompletedJobs(bucket *bolt.Bucket, block *big.Int) error {
+	after := func(b *uint64) bool { return b != nil && new(big.Int).SetUint64(*b).Cmp(block) > 0 }
+
+	restored := make(map[string][]byte)
+	if err := bucket.ForEach(func(k, v []byte) error {
+		job := &db.Job{}
+		if err := json.Unmarshal(v, job); err != nil {
+			return nil
+		}
+		if !after(job.CompletedAtBlock) && !after(job.CompletionMinedAtBlock) {
+			return nil
+		}
+
+		if after(job.CompletedAtBlock) {
+			job.CompletedAtBlock = nil
+		}
+		if after(job.CompletionMinedAtBlock) {
+			job.CompletionMinedAtBlock = nil
+		}
+		job.Touch(time.Now())
+		jobBytes, err := json.Marshal(job)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling job")
+		}
+		restored[string(k)] = jobBytes
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error reading completed jobs")
+	}
+
+	for jobAddress, jobBytes := range restored {
+		log.WithField("jobAddress", common.BytesToAddress([]byte(jobAddress)).Hex()).
+			Info("job completion is being re-scanned; restoring job")
+		if err := bucket.Put([]byte(jobAddress), jobBytes); err != nil {
+			return errors.Wrap(err, "error putting job to db")
+		}
+	}
+	return nil
+}
+
+// applyJobLogs dispatches each log to the handler for its event in the order the logs were emitted, returning the
+// number of each event applied and the job state transitions they caused. bucket must belong to the caller's write
+// transaction: the handlers read, modify and write back each job within it, which is what keeps them from racing other
+// writers of the same job.
+func applyJobLogs(bucket *bolt.Bucket, events jobEvents, jobLogs []types.Log) (map[string]int, []JobTransition,
+	error) {
+	processed := make(map[string]int)
+	var transitions []JobTransition
+
+	seen := make(map[logID]bool)
+	for _, jobLog := range emissionOrder(jobLogs) {
+		if len(jobLog.Topics) == 0 {
+			continue
+		}
+
+		// A provider may return a log twice, e.g. on either side of a query boundary it treats loosely; handlers
+		// touch the job on every call, so the copy is skipped rather than relied on to be a no-op
+		if id := (logID{jobLog.BlockHash, jobLog.TxHash, jobLog.Index}); jobLog.TxHash != (common.Hash{}) {
+			if seen[id] {
+				log.WithField("txHash", jobLog.TxHash.Hex()).
+					WithField("logIndex", jobLog.Index).
+					Debug("skipping duplicate job log")
+				continue
+			}
+			seen[id] = true
+		}
+
+		var event string
+		var handle func(*bolt.Bucket, types.Log, jobEventData) (*JobTransition, error)
+		switch jobLog.Topics[0] {
+		case events.jobCreatedID:
+			event, handle = "JobCreated", handleJobCreated
+		case events.jobFundedID:
+			event, handle = "JobFunded", handleJobFunded
+		case events.jobCompletedID:
+			event, handle = "JobCompleted", handleJobCompleted
+		default:
+			continue
+		}
+
+		// A non-conforming or upgraded contract's events are skipped rather than misread
+		data, err := events.decode(event, jobLog)
+		if err != nil {
+			log.WithError(err).
+				WithField("event", event).
+				WithField("txHash", jobLog.TxHash.Hex()).
+				WithField("blockNumber", jobLog.BlockNumber).
+				Warn("skipping job event that failed to decode")
+			continue
+		}
+		transition, err := handle(bucket, jobLog, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if transition != nil && transition.OldState != transition.NewState {
+			transition.TxHash = jobLog.TxHash
+			transitions = append(transitions, *transition)
+		}
+		processed[event]++
+	}
+
+	return processed, transitions, nil
+}
+
+// logID identifies a log on chain by its block, transaction and index within the block
+type logID struct {
+	blockHash common.Hash
+	txHash    common.Hash
+	index     uint
+}
+
+// emissionOrder returns a copy of jobLogs sorted by block and index within the block. Nodes aren't bound to return
+// logs in that order, and a job's events applied out of it, e.g. its JobCompleted before its JobFunded, would leave
+// the job in the wrong state.
+func emissionOrder(jobLogs []types.Log) []types.Log {
+	sorted := append([]types.Log(nil), jobLogs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].BlockNumber != sorted[j].BlockNumber {
+			return sorted[i].BlockNumber < sorted[j].BlockNumber
+		}
+		return sorted[i].Index < sorted[j].Index
+	})
+	return sorted
+}
+
+// putCursor records block as the last block processed for events, along with its hash for reorg detection
+func putCursor(bucket *bolt.Bucket, block *big.Int, blockHash common.Hash) error {
+	if bucket == nil {
+		return errors.New("chain bucket not found")
+	}
+
+	if err := bucket.Put(db.LastBlockKey, block.Bytes()); err != nil {
+		return errors.Wrap(err, "error putting current block to db")
+	}
+
+	// Without a hash there is nothing to compare against on the next poll, so don't keep a stale one
+	var err error
+	if blockHash == (common.Hash{}) {
+		err = bucket.Delete(db.LastBlockHashKey)
+	} else {
+		err = bucket.Put(db.LastBlockHashKey, blockHash.Bytes())
+	}
+	return errors.Wrap(err, "error putting current block hash to db")
+}
+
+// rpcContext returns a context for a single node call, bounded by the RPC timeout so a hung connection fails the call
+// instead of stalling the event loop, and cancelled when the processor stops
+func (p Processor) rpcContext() (context.Context, context.CancelFunc) {
+	if p.rpcTimeout <= 0 {
+		return context.WithCancel(p.ctx)
+	}
+	return context.WithTimeout(p.ctx, p.rpcTimeout)
+}
+
+// tooManyLogsErrors are fragments of the errors nodes and providers return for log queries whose response would be
+// too large
+var tooManyLogsErrors = []string{
+	"query returned more than",
+	"response size exceeded",
+	"response size should not",
+	"too many results",
+	"limit exceeded",
+}
+
+// isTooManyLogsError reports whether a FilterLogs error means the query matched too many logs rather than failed
+func isTooManyLogsError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, fragment := range tooManyLogsErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// missingHistoryErrors are fragments of the errors nodes return for queries on blocks whose history they've pruned,
+// as a full rather than archive node does
+var missingHistoryErrors = []string{
+	"missing trie node",
+	"block not found",
+	"header not found",
+	"unknown block",
+	"pruned",
+	"history not available",
+	"historical state",
+}
+
+// isMissingHistoryError reports whether a FilterLogs error means the node lacks the queried blocks' history, which
+// won't change on retry, rather than a transient failure
+func isMissingHistoryError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, fragment := range missingHistoryErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// chainHead returns the number and hash of the newest block. With raw block lookups, the number comes from
+// eth_blockNumber and the hash is left empty for the caller to look up if needed.
+func (p Processor) chainHead(ctx context.Context) (*big.Int, common.Hash, error) {
+	if p.rawBlockLookup {
+		var currentBlockHex string
+		if err := p.rawClient.CallContext(ctx, &currentBlockHex, "eth_blockNumber"); err != nil {
+			return nil, common.Hash{}, err
+		}
+		return new(big.Int).SetBytes(common.FromHex(currentBlockHex)), common.Hash{}, nil
+	}
+
+	header, err := p.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	return header.Number, header.Hash(), nil
+}
+
+// finalizedBlock returns the number of the newest block the node reports finalized. Its hash is left for the caller to
+// look up with blockHash, so that it is computed the same way as the hash reorg detection compares it to.
+func (p Processor) finalizedBlock(ctx context.Context) (*big.Int, error) {
+	var header struct {
+		Number *hexutil.Big `json:"number"`
+	}
+
+	if err := p.rawClient.CallContext(ctx, &header, "eth_getBlockByNumber", "finalized", false); err != nil {
+		return nil, errors.Wrap(err, "error retrieving finalized block")
+	}
+
+	// Nodes without the tag reject it, but one may also have nothing finalized yet
+	if header.Number == nil {
+		return nil, errors.New("finalized block not found")
+	}
+
+	return (*big.Int)(header.Number), nil
+}
+
+// blockHash returns the hash of the canonical block at the given height.
+//
+// Without raw block lookups the hash is computed from the decoded header, which for headers with fields the pinned
+// go-ethereum doesn't know (e.g. the EIP-1559 base fee) differs from the node's reported hash. Lookups are consistent
+// within a mode, which is all reorg detection needs, but switching modes triggers one spurious rewind.
+func (p Processor) blockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	if !p.rawBlockLookup {
+		header, err := p.ethClient.HeaderByNumber(ctx, number)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return header.Hash(), nil
+	}
+
+	// Nodes predating https://github.com/ethereum/go-ethereum/issues/3230 return headers ethClient can't unmarshal,
+	// so the raw lookup only decodes the hash
+	var header struct {
+		Hash common.Hash `json:"hash"`
+	}
+
+	if err := p.rawClient.CallContext(ctx, &header, "eth_getBlockByNumber", hexutil.EncodeBig(number),
+		false); err != nil {
+		return common.Hash{}, err
+	}
+
+	if header.Hash == (common.Hash{}) {
+		return common.Hash{}, fmt.Errorf("block %v not found", number)
+	}
+
+	return header.Hash, nil
+}
+
+func handleJobCreated(bucket *bolt.Bucket, jobCreatedLog types.Log, data jobEventData) (*JobTransition, error) {
+	job := &db.Job{}
+	jobAddressBytes := data.Job.Bytes()
+	jobConsumerBytes := data.Consumer.Bytes()
+
+	log.WithFields(logrus.Fields{
+		"jobAddress": common.BytesToAddress(jobAddressBytes).Hex(),
+	}).Debug("received JobCreated event; saving to db")
+
+	jobBytes := bucket.Get(jobAddressBytes)
+	if jobBytes != nil {
+		// Writing back a job that failed to deserialize would clobber the record with a zero-valued one
+		if err := json.Unmarshal(jobBytes, job); err != nil {
+			log.WithError(err).WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+				Error("error unmarshaling job from db; skipping JobCreated event")
+			return nil, nil
+		}
+	}
+	transition := &JobTransition{JobAddress: common.BytesToAddress(jobAddressBytes), OldState: job.JobState}
+	job.JobAddress = jobAddressBytes
+	job.Consumer = jobConsumerBytes
+	job.AgentAddress = jobCreatedLog.Address.Bytes()
+	// A JobFunded event handled first (e.g. out of order across a reorg re-scan) must not be downgraded
+	if job.JobState == "" {
+		job.JobState = jobPendingState
+	}
+	job.Touch(time.Now())
+	transition.NewState = job.JobState
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling job")
+	}
+	return transition, errors.Wrap(bucket.Put(jobAddressBytes, jobBytes), "error putting job to db")
+}
+
+func handleJobFunded(bucket *bolt.Bucket, jobFundedLog types.Log, data jobEventData) (*JobTransition, error) {
+	job := &db.Job{}
+	jobAddressBytes := data.Job.Bytes()
+
+	log.WithFields(logrus.Fields{
+		"jobAddress": common.BytesToAddress(jobAddressBytes).Hex(),
+	}).Debug("received JobFunded event; saving to db")
+
+	jobBytes := bucket.Get(jobAddressBytes)
+	if jobBytes != nil {
+		// Writing back a job that failed to deserialize would clobber the record with a zero-valued one
+		if err := json.Unmarshal(jobBytes, job); err != nil {
+			log.WithError(err).WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex()).
+				Error("error unmarshaling job from db; skipping JobFunded event")
+			return nil, nil
+		}
+	}
+	transition := &JobTransition{JobAddress: common.BytesToAddress(jobAddressBytes), OldState: job.JobState}
+	// Only the state changes; a job not yet seen as created keeps an empty consumer until JobCreated fills it in
+	if job.JobAddress == nil {
+		job.JobAddress = jobAddressBytes
+		job.AgentAddress = jobFundedLog.Address.Bytes()
+	}
+	// A job whose completion permanently failed stays failed when its funding is re-scanned
+	if job.JobState != jobFailedState {
+		job.JobState = jobFundedState
+	}
+	if data.Amount != nil {
+		job.FundedAmount = data.Amount
+	}
+	now := time.Now()
+	// A re-scan of the funding mustn't restart the completion delay
+	if job.FundedAt.IsZero() {
+		job.FundedAt = now
+	}
+	job.Touch(now)
+	transition.NewState = job.JobState
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling job")
+	}
+	return transition, errors.Wrap(bucket.Put(jobAddressBytes, jobBytes), "error putting job to db")
+}
+
+func handleJobCompleted(bucket *bolt.Bucket, jobCompletedLog types.Log, data jobEventData) (*JobTransition, error) {
+	jobAddressBytes := data.Job.Bytes()
+
+	log := log.WithField("jobAddress", common.BytesToAddress(jobAddressBytes).Hex())
+	log.Debug("received JobCompleted event; marking job completed in db")
+
+	// The old state is only reported; an unreadable record is deleted right away rather than kept until confirmed
+	job := &db.Job{}
+	jobBytes := bucket.Get(jobAddressBytes)
+	unreadable := jobBytes != nil && json.Unmarshal(jobBytes, job) != nil
+
+	// Only a job this daemon completed has a signature stored; any other was completed by someone else, or never seen
+	// created, and deleting it is a no-op
+	transition := &JobTransition{JobAddress: common.BytesToAddress(jobAddressBytes), OldState: job.JobState,
+		NewState: jobCompletedState, completedByDaemon: job.JobSignature != nil}
+	if transition.completedByDaemon {
+		log.WithField("txHash", jobCompletedLog.TxHash.Hex()).Info("job completion confirmed on chain")
+	} else {
+		log.WithField("txHash", jobCompletedLog.TxHash.Hex()).Debug("job completed by another party")
+	}
+
+	transition.unknown = jobBytes == nil || unreadable
+	if jobBytes == nil {
+		return transition, nil
+	}
+	if unreadable {
+		return transition, errors.Wrap(bucket.Delete(jobAddressBytes), "error deleting job from db")
+	}
+
+	// The job is deleted once the event is confirmed; see deleteConfirmedJobs
+	completedAtBlock := jobCompletedLog.BlockNumber
+	job.CompletedAtBlock = &completedAtBlock
+	job.Touch(time.Now())
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling job")
+	}
+	return transition, errors.Wrap(bucket.Put(jobAddressBytes, jobBytes), "error putting job to db")
+}
+
+// resubmitOldJobs scans the db for completed jobs to submit at startup and then every old job scan interval, so jobs
+// that couldn't be queued or whose completion failed to send are picked up again. Each pass reads the db afresh;
+// jobs still in flight from an earlier pass are not queued twice.
+func (p Processor) resubmitOldJobs() {
+	for {
+		recoverPanic("old job resubmission", p.submitOldJobsForCompletion)
+
+		if p.oldJobScanInterval <= 0 {
+			return
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(p.oldJobScanInterval):
+		}
+	}
+}
+
+// oldJobRetryInterval is how long an old job scan waits before retrying jobs that didn't fit in the queue
+var oldJobRetryInterval = 5 * time.Second
+
+// submitOldJobsForCompletion makes one pass over the db, queueing the jobs marked completed, i.e. moved toward
+// completion under the completion policy, that pass verification. Under the manual policy it queues none.
 func (p Processor) submitOldJobsForCompletion() {
+	if p.completionPolicy == completionManual {
+		log.Debug("COMPLETION_POLICY manual; not resubmitting old jobs")
+		return
+	}
+
+	var jobs []*db.Job
 	p.boltDB.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(db.JobBucketName)
 		bucket.ForEach(func(k, v []byte) error {
 			job := &db.Job{}
-			json.Unmarshal(v, job)
-			if job.Completed {
-				log.WithFields(log.Fields{
-					"jobAddress":   common.BytesToAddress(job.JobAddress).Hex(),
-					"jobSignature": hex.EncodeToString(job.JobSignature),
-				}).Debug("completing old job found in db")
-				p.jobCompletionQueue <- &jobInfo{job.JobAddress, job.JobSignature}
+			if err := json.Unmarshal(v, job); err != nil {
+				log.WithError(err).WithField("jobAddress", common.BytesToAddress(k).Hex()).
+					Error("error unmarshaling job from db; skipping")
+				return nil
+			}
+			// Failed jobs have used up their completion attempts, and jobs seen completed on chain, or whose
+			// completion transaction was mined and whose event is yet to be seen, need none. Nor, while dry runs go
+			// on, does a job whose completion a dry run has logged already; it's sent once DRY_RUN is turned off.
+			if job.Completed && job.JobState != jobFailedState && job.CompletedAtBlock == nil &&
+				job.CompletionMinedAtBlock == nil && !(p.dryRun && job.DryRun) {
+				// A job completed without an event seen for it has no address in its record; the key always has it
+				job.JobAddress = append([]byte(nil), k...)
+				jobs = append(jobs, job)
 			}
 			return nil
 		})
 		return nil
 	})
+
+	// Verification may fall back to the chain, so it happens outside the read transaction
+	var verified []*db.Job
+	for _, job := range jobs {
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		log := log.WithFields(logrus.Fields{
+			"jobAddress":   common.BytesToAddress(job.JobAddress).Hex(),
+			"jobSignature": hex.EncodeToString(job.JobSignature),
+		})
+
+		if !p.consumerAllowed(job.Consumer) {
+			log.WithField("consumer", common.BytesToAddress(job.Consumer).Hex()).
+				Debug("skipping completion of old job from consumer not in allowlist")
+			continue
+		}
+
+		if !p.fundingSufficient(job.FundedAmount) {
+			log.WithField("fundedAmount", job.FundedAmount).
+				Debug("skipping completion of old job funded below MIN_JOB_FUNDING")
+			continue
+		}
+
+		// A later scan picks the job up once its delay is over
+		if p.remainingCompletionDelay(job.JobState, job.FundedAt, time.Now()) > 0 {
+			log.Debug("skipping completion of old job within COMPLETION_DELAY")
+			continue
+		}
+
+		if err := p.verifyJobSignature(job.JobAddress, job, job.JobSignature); err != nil {
+			log.WithError(err).Warn("skipping completion of old job with invalid signature")
+			continue
+		}
+
+		log.Debug("completing old job found in db")
+		verified = append(verified, job)
+	}
+
+	jobInfos := make([]*jobInfo, len(verified))
+	for i, job := range verified {
+		jobInfos[i] = &jobInfo{jobAddressBytes: job.JobAddress, jobSignatureBytes: job.JobSignature,
+			agentAddressBytes: job.AgentAddress}
+	}
+	p.enqueueJobCompletions(jobInfos)
+}
+
+// enqueueJobCompletions submits jobs found by an old job scan to the completion queue. A stuck completion loop must not
+// hold up the caller forever, so jobs that don't fit in the queue are retried periodically until the processor stops.
+func (p Processor) enqueueJobCompletions(jobInfos []*jobInfo) {
+	for len(jobInfos) > 0 {
+		var full []*jobInfo
+		for _, jobInfo := range jobInfos {
+			if !p.tryEnqueueJobCompletion(jobInfo) {
+				full = append(full, jobInfo)
+			}
+		}
+
+		if jobInfos = full; len(jobInfos) == 0 {
+			return
+		}
+
+		log.WithField("remaining", len(jobInfos)).Warn("job completion queue full; retrying remaining jobs later")
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(oldJobRetryInterval):
+		}
+	}
 }
@@ -0,0 +1,1812 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/singnet/snet-daemon/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAgentABI = func() abi.ABI {
+	a, err := abi.JSON(strings.NewReader(AgentABI))
+	if err != nil {
+		panic(err)
+	}
+	return a
+}()
+
+var testEvents = newJobEvents(testAgentABI)
+
+func newTestDB(t *testing.T) (*bolt.DB, func()) {
+	dir, err := ioutil.TempDir("", "snetd-blockchain")
+	require.NoError(t, err)
+
+	boltDB, err := db.Connect(filepath.Join(dir, "snetd.db"))
+	require.NoError(t, err)
+
+	return boltDB, func() {
+		boltDB.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// newTestProcessor returns a processor over boltDB with the state the loops need; the returned func stops it
+func newTestProcessor(boltDB *bolt.DB) (Processor, context.CancelFunc) {
+	p := Processor{
+		boltDB:             boltDB,
+		agents:             []*agentContract{{}},
+		agentABI:           testAgentABI,
+		events:             testEvents,
+		jobCompletionQueue: make(chan *jobInfo, 10),
+		queueMutex:         &sync.RWMutex{},
+		inFlight:           newInFlightJobs(),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p, p.cancel
+}
+
+func TestRecoverPanic(t *testing.T) {
+	assert.True(t, recoverPanic("test", func() { panic("boom") }))
+	assert.False(t, recoverPanic("test", func() {}))
+}
+
+func TestParseAgentABI(t *testing.T) {
+	_, err := parseAgentABI("[{")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error parsing agent ABI")
+
+	_, err = parseAgentABI(`[{"anonymous":false,"inputs":[],"name":"JobCreated","type":"event"}]`)
+	assert.Error(t, err)
+}
+
+func TestNewProcessorCachesAgentABI(t *testing.T) {
+	p, err := NewProcessor(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, crypto.Keccak256Hash([]byte("JobCreated(address,address)")), p.events.jobCreatedID)
+	assert.Equal(t, crypto.Keccak256Hash([]byte("JobFunded(address)")), p.events.jobFundedID)
+	assert.Equal(t, crypto.Keccak256Hash([]byte("JobCompleted(address)")), p.events.jobCompletedID)
+	assert.Equal(t, testEvents.jobCreatedID, p.events.jobCreatedID)
+	assert.Contains(t, p.agentABI.Methods, "completeJob")
+}
+
+func TestStartLoopWithoutAgentABI(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.enabled = true
+	p.events = jobEvents{}
+	p.loops, p.closeQueue = &sync.WaitGroup{}, &sync.Once{}
+
+	assert.Error(t, p.StartLoop())
+
+	// No loop was left running without an ABI
+	ctx, stop := context.WithTimeout(context.Background(), time.Second)
+	defer stop()
+	assert.NoError(t, p.Stop(ctx))
+}
+
+func TestApplyJobLogsSurvivesTruncatedLog(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobAddress := common.HexToAddress("0x1234")
+
+	logs := []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: make([]byte, 40)},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+	}
+
+	assert.False(t, recoverPanic("test", func() { p.commitJobLogs(testEvents, logs, big.NewInt(1), common.Hash{}) }))
+
+	// The well-formed log after the truncated one is still applied
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		assert.NotNil(t, tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()))
+		return nil
+	})
+}
+
+func TestApplyJobLogsMalformedData(t *testing.T) {
+	jobAddress := common.HexToAddress("0x1234")
+	consumer := common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	tests := []struct {
+		name   string
+		topic  common.Hash
+		data   []byte
+		stored bool
+	}{
+		{"JobCreated empty", testEvents.jobCreatedID, nil, false},
+		{"JobCreated job address only", testEvents.jobCreatedID, word(jobAddress), false},
+		{"JobCreated one byte short", testEvents.jobCreatedID, append(word(jobAddress), word(consumer)[:31]...), false},
+		{"JobCreated valid", testEvents.jobCreatedID, append(word(jobAddress), word(consumer)...), true},
+		{"JobFunded empty", testEvents.jobFundedID, nil, false},
+		{"JobFunded one byte short", testEvents.jobFundedID, word(jobAddress)[:31], false},
+		{"JobFunded valid", testEvents.jobFundedID, word(jobAddress), true},
+		{"JobFunded trailing data", testEvents.jobFundedID, append(word(jobAddress), 0xff), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boltDB, cleanup := newTestDB(t)
+			defer cleanup()
+
+			p := Processor{boltDB: boltDB}
+			require.NoError(t, p.commitJobLogs(testEvents, []types.Log{{Topics: []common.Hash{tt.topic}, Data: tt.data}},
+				big.NewInt(1), common.Hash{}))
+
+			p.boltDB.View(func(tx *bolt.Tx) error {
+				assert.Equal(t, tt.stored, tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()) != nil)
+				return nil
+			})
+		})
+	}
+}
+
+func TestApplyJobLogsMalformedJobCompleted(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobAddress := common.HexToAddress("0x1234")
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: make([]byte, 16)},
+	}, big.NewInt(1), common.Hash{}))
+
+	// The truncated JobCompleted is skipped rather than deleting anything
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		assert.NotNil(t, tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()))
+		return nil
+	})
+}
+
+func TestApplyJobLogsCorruptRecord(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobAddress := common.HexToAddress("0x1234")
+	consumer := common.HexToAddress("0x5678")
+	corrupt := []byte("{not json")
+
+	require.NoError(t, p.boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.JobBucketName).Put(jobAddress.Bytes(), corrupt)
+	}))
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(common.LeftPadBytes(jobAddress.Bytes(), 32),
+			common.LeftPadBytes(consumer.Bytes(), 32)...)},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+	}, big.NewInt(1), common.Hash{}))
+
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, corrupt, tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()))
+		return nil
+	})
+}
+
+func TestMarkJobCompletedCorruptRecord(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobAddress := common.HexToAddress("0x1234")
+	corrupt := []byte("{not json")
+
+	require.NoError(t, p.boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.JobBucketName).Put(jobAddress.Bytes(), corrupt)
+	}))
+
+	_, err := p.markJobCompleted(jobAddress.Bytes(), testJobSignature, false)
+	assert.Error(t, err)
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, corrupt, tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()))
+		return nil
+	})
+}
+
+func TestCommitJobLogsAtomicWithCursor(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobAddress := common.HexToAddress("0x1234")
+
+	// Without the chain bucket the cursor write fails
+	require.NoError(t, p.boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(db.ChainBucketName)
+	}))
+
+	err := p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+	}, big.NewInt(1), common.HexToHash("0xabcd"))
+	assert.Error(t, err)
+
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()))
+		return nil
+	})
+}
+
+func TestCommitJobLogsRange(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	created, funded, completed := common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")
+	consumer := common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	blockHash := common.HexToHash("0xabcd")
+
+	jobLogs := []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(created), word(consumer)...)},
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(funded), word(consumer)...)},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(funded)},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(completed)},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(completed)},
+	}
+
+	check := func() {
+		p.boltDB.View(func(tx *bolt.Tx) error {
+			jobs := tx.Bucket(db.JobBucketName)
+			for address, state := range map[common.Address]string{created: jobPendingState, funded: jobFundedState} {
+				job := &db.Job{}
+				require.NoError(t, json.Unmarshal(jobs.Get(address.Bytes()), job))
+				assert.Equal(t, state, job.JobState)
+				assert.Equal(t, consumer.Bytes(), job.Consumer)
+			}
+			assert.Nil(t, jobs.Get(completed.Bytes()))
+
+			chain := tx.Bucket(db.ChainBucketName)
+			assert.Equal(t, big.NewInt(10).Bytes(), chain.Get(db.LastBlockKey))
+			assert.Equal(t, blockHash.Bytes(), chain.Get(db.LastBlockHashKey))
+			return nil
+		})
+	}
+
+	require.NoError(t, p.commitJobLogs(testEvents, jobLogs, big.NewInt(10), blockHash))
+	check()
+
+	// Re-applying a range, e.g. after a reorg rewind, leaves the same state
+	require.NoError(t, p.commitJobLogs(testEvents, jobLogs, big.NewInt(10), blockHash))
+	check()
+}
+
+func TestApplyJobLogsEmissionOrder(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobA, jobB := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	consumer := common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	// Job A is created, funded and completed over three blocks, interleaved with job B being created and funded. The
+	// logs arrive in the reverse of their emission order.
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobB), BlockNumber: 3, Index: 2},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(jobA), BlockNumber: 3, Index: 1},
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobB), word(consumer)...), BlockNumber: 3},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobA), BlockNumber: 2, Index: 5},
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobA), word(consumer)...), BlockNumber: 1,
+			Index: 9},
+	}, big.NewInt(3), common.Hash{}))
+
+	// Applied in emission order, job A ends up completed and deleted rather than revived by its earlier events
+	job, err := db.GetJob(boltDB, jobA.Bytes())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	job, err = db.GetJob(boltDB, jobB.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, jobFundedState, job.JobState)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+}
+
+func TestApplyJobLogsSkipsDuplicates(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	job, consumer := common.HexToAddress("0x01"), common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	created := types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(job), word(consumer)...),
+		BlockNumber: 1, TxHash: common.HexToHash("0xc1"), Index: 0}
+	funded := types.Log{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(job), BlockNumber: 2,
+		TxHash: common.HexToHash("0xf1"), Index: 3}
+
+	// Each log is applied once however often the provider returned it
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		processed, transitions, err := applyJobLogs(tx.Bucket(db.JobBucketName), testEvents,
+			[]types.Log{created, funded, created, funded, created})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"JobCreated": 1, "JobFunded": 1}, processed)
+		assert.Len(t, transitions, 2)
+		return nil
+	}))
+
+	// Logs outside the queried range are dropped before they're applied
+	inRange := logsInRange([]types.Log{created, funded, {BlockNumber: 3}}, big.NewInt(2), big.NewInt(2))
+	assert.Equal(t, []types.Log{funded}, inRange)
+}
+
+func TestCompletedJobDeletionConfirmations(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB, deleteConfirmations: 3}
+	job, consumer := common.HexToAddress("0x01"), common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	getJob := func() *db.Job {
+		stored, err := db.GetJob(boltDB, job.Bytes())
+		require.NoError(t, err)
+		return stored
+	}
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(job), word(consumer)...), BlockNumber: 1},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(job), BlockNumber: 2},
+	}, big.NewInt(2), common.Hash{}))
+	completed := []types.Log{{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(job), BlockNumber: 5}}
+
+	// The job is kept, marked with the block of its JobCompleted event, until that has three confirmations
+	require.NoError(t, p.commitJobLogs(testEvents, completed, big.NewInt(5), common.Hash{}))
+	require.NotNil(t, getJob())
+	assert.Equal(t, uint64(5), *getJob().CompletedAtBlock)
+	require.NoError(t, p.commitJobLogs(testEvents, nil, big.NewInt(7), common.Hash{}))
+	require.NotNil(t, getJob())
+
+	// Moving the cursor back before the event, as after a reorg, restores the job until the event is seen again
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return restoreCompletedJobs(tx.Bucket(db.JobBucketName), big.NewInt(4))
+	}))
+	require.NotNil(t, getJob())
+	assert.Nil(t, getJob().CompletedAtBlock)
+	assert.Equal(t, jobFundedState, getJob().JobState)
+	require.NoError(t, p.commitJobLogs(testEvents, nil, big.NewInt(9), common.Hash{}))
+	require.NotNil(t, getJob())
+
+	require.NoError(t, p.commitJobLogs(testEvents, completed, big.NewInt(7), common.Hash{}))
+	require.NotNil(t, getJob())
+	require.NoError(t, p.commitJobLogs(testEvents, nil, big.NewInt(8), common.Hash{}))
+	assert.Nil(t, getJob())
+}
+
+func TestApplyJobLogsMultipleAgents(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	agentA, agentB := common.HexToAddress("0xaaaa"), common.HexToAddress("0xbbbb")
+	jobA, jobB := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	consumer := common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	assert.Equal(t, []common.Address{agentA, agentB}, testEvents.filterQuery([]common.Address{agentA, agentB}).Addresses)
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Address: agentA, Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobA), word(consumer)...)},
+		{Address: agentB, Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobB)},
+	}, big.NewInt(1), common.Hash{}))
+
+	p.boltDB.View(func(tx *bolt.Tx) error {
+		for jobAddress, agentAddress := range map[common.Address]common.Address{jobA: agentA, jobB: agentB} {
+			job := &db.Job{}
+			require.NoError(t, json.Unmarshal(tx.Bucket(db.JobBucketName).Get(jobAddress.Bytes()), job))
+			assert.Equal(t, agentAddress.Bytes(), job.AgentAddress)
+		}
+		return nil
+	})
+}
+
+func TestAgentFor(t *testing.T) {
+	agentA := &agentContract{address: common.HexToAddress("0xaaaa")}
+	agentB := &agentContract{address: common.HexToAddress("0xbbbb")}
+	p := Processor{agents: []*agentContract{agentA, agentB}}
+
+	agent, err := p.agentFor(agentB.address.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, agentB, agent)
+	agent, err = p.agentFor(nil)
+	require.NoError(t, err)
+	assert.Equal(t, agentA, agent)
+
+	// An unknown agent isn't mistaken for the first one, and with no agents there is nothing to fall back to
+	_, err = p.agentFor(common.HexToAddress("0xcccc").Bytes())
+	assert.Error(t, err)
+	_, err = Processor{}.agentFor(nil)
+	assert.Error(t, err)
+}
+
+// putCompletedJobs stores jobs marked completed locally with valid consumer signatures under p's first agent
+func putCompletedJobs(t *testing.T, p Processor, states map[common.Address]string) {
+	p.agents[0].sigHasher = func(i []byte) []byte { return crypto.Keccak256(i) }
+	consumerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	require.NoError(t, p.boltDB.Update(func(tx *bolt.Tx) error {
+		for address, state := range states {
+			signature, err := crypto.Sign(p.agents[0].sigHasher(address.Bytes()), consumerKey)
+			require.NoError(t, err)
+			jobBytes, err := json.Marshal(db.Job{JobAddress: address.Bytes(), JobState: state, Completed: true,
+				JobSignature: signature, Consumer: crypto.PubkeyToAddress(consumerKey.PublicKey).Bytes()})
+			require.NoError(t, err)
+			require.NoError(t, tx.Bucket(db.JobBucketName).Put(address.Bytes(), jobBytes))
+		}
+		return nil
+	}))
+}
+
+func TestSubmitOldJobsCompletionDelay(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.completionDelay = time.Minute
+
+	// Both jobs were written just now, but only one was funded within the delay
+	early, late := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	putCompletedJobs(t, p, map[common.Address]string{early: jobFundedState, late: jobFundedState})
+	now := time.Now()
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.JobBucketName)
+		for address, fundedAt := range map[common.Address]time.Time{early: now.Add(-time.Hour), late: now} {
+			job := &db.Job{}
+			require.NoError(t, json.Unmarshal(bucket.Get(address.Bytes()), job))
+			job.FundedAt = fundedAt
+			job.Touch(now)
+			jobBytes, err := json.Marshal(job)
+			require.NoError(t, err)
+			require.NoError(t, bucket.Put(address.Bytes(), jobBytes))
+		}
+		return nil
+	}))
+
+	p.submitOldJobsForCompletion()
+
+	require.Len(t, p.jobCompletionQueue, 1)
+	assert.Equal(t, early.Bytes(), (<-p.jobCompletionQueue).jobAddressBytes)
+}
+
+func TestSubmitOldJobsSkipsFailed(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+
+	funded, failed := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	putCompletedJobs(t, p, map[common.Address]string{funded: jobFundedState, failed: jobFailedState})
+
+	p.submitOldJobsForCompletion()
+
+	require.Len(t, p.jobCompletionQueue, 1)
+	assert.Equal(t, funded.Bytes(), (<-p.jobCompletionQueue).jobAddressBytes)
+}
+
+func TestSubmitOldJobsConsumerAllowlist(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		allowed bool
+	}{
+		{name: "allowed", allowed: true},
+		{name: "disallowed", allowed: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			boltDB, cleanup := newTestDB(t)
+			defer cleanup()
+
+			p, cancel := newTestProcessor(boltDB)
+			defer cancel()
+
+			jobAddress := common.HexToAddress("0x01")
+			putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFundedState})
+			job, err := db.GetJob(boltDB, jobAddress.Bytes())
+			require.NoError(t, err)
+
+			p.settings = &liveSettings{consumerAllowlist: map[common.Address]bool{common.HexToAddress("0xc0"): true}}
+			if tt.allowed {
+				p.settings.consumerAllowlist[common.BytesToAddress(job.Consumer)] = true
+			}
+
+			p.submitOldJobsForCompletion()
+
+			if tt.allowed {
+				require.Len(t, p.jobCompletionQueue, 1)
+				assert.Equal(t, jobAddress.Bytes(), (<-p.jobCompletionQueue).jobAddressBytes)
+			} else {
+				assert.Empty(t, p.jobCompletionQueue)
+			}
+		})
+	}
+}
+
+func TestCompleteJobConsumerAllowlist(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+
+	trusted, untrusted := common.HexToAddress("0xc1"), common.HexToAddress("0xc2")
+	allowedJob, disallowedJob := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		for jobAddress, consumer := range map[common.Address]common.Address{allowedJob: trusted,
+			disallowedJob: untrusted} {
+			jobBytes, err := json.Marshal(db.Job{JobAddress: jobAddress.Bytes(), JobState: jobFundedState,
+				Consumer: consumer.Bytes()})
+			require.NoError(t, err)
+			require.NoError(t, tx.Bucket(db.JobBucketName).Put(jobAddress.Bytes(), jobBytes))
+		}
+		return nil
+	}))
+
+	p.settings = &liveSettings{consumerAllowlist: map[common.Address]bool{trusted: true}}
+	p.CompleteJob(allowedJob.Bytes(), []byte{1})
+	p.CompleteJob(disallowedJob.Bytes(), []byte{2})
+
+	require.Len(t, p.jobCompletionQueue, 1)
+	assert.Equal(t, allowedJob.Bytes(), (<-p.jobCompletionQueue).jobAddressBytes)
+
+	// The disallowed job is left completed in the db for manual handling
+	job, err := db.GetJob(boltDB, disallowedJob.Bytes())
+	require.NoError(t, err)
+	assert.True(t, job.Completed)
+	assert.Equal(t, []byte{2}, job.JobSignature)
+}
+
+func TestCompleteJobMinFunding(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+
+	// The upgraded contract's JobFunded carries the amount the job was funded with
+	a, err := abi.JSON(strings.NewReader(fundedAgentABI))
+	require.NoError(t, err)
+	events := newJobEvents(a)
+	require.True(t, events.carriesFundedAmount())
+	assert.False(t, testEvents.carriesFundedAmount())
+
+	underfunded, overfunded := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	amount := func(n int64) []byte { return common.LeftPadBytes(big.NewInt(n).Bytes(), 32) }
+	require.NoError(t, p.commitJobLogs(events, []types.Log{
+		{Topics: []common.Hash{events.jobFundedID}, Data: append(word(underfunded), amount(99)...)},
+		{Topics: []common.Hash{events.jobFundedID}, Data: append(word(overfunded), amount(101)...)},
+	}, big.NewInt(1), common.Hash{}))
+
+	job, err := db.GetJob(boltDB, underfunded.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(99), job.FundedAmount)
+	assert.Equal(t, "99", newJobView(*job).FundedAmount)
+
+	p.minJobFunding = big.NewInt(100)
+	p.CompleteJob(underfunded.Bytes(), []byte{1})
+	p.CompleteJob(overfunded.Bytes(), []byte{2})
+
+	require.Len(t, p.jobCompletionQueue, 1)
+	assert.Equal(t, overfunded.Bytes(), (<-p.jobCompletionQueue).jobAddressBytes)
+
+	// The underfunded job is left completed in the db for manual handling
+	job, err = db.GetJob(boltDB, underfunded.Bytes())
+	require.NoError(t, err)
+	assert.True(t, job.Completed)
+	assert.Equal(t, []byte{1}, job.JobSignature)
+
+	// A job whose funded amount isn't known doesn't meet the minimum
+	assert.False(t, p.fundingSufficient(nil))
+	p.minJobFunding = nil
+	assert.True(t, p.fundingSufficient(nil))
+}
+
+func TestCompletionDelay(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.completionDelay = 200 * time.Millisecond
+
+	jobAddress := common.HexToAddress("0x1234")
+	funded := time.Now()
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{{Topics: []common.Hash{testEvents.jobFundedID},
+		Data: common.LeftPadBytes(jobAddress.Bytes(), 32)}}, big.NewInt(1), common.Hash{}))
+
+	// The job is marked completed at once but only enqueued once the delay since its funding is over
+	p.CompleteJob(jobAddress.Bytes(), []byte{1})
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.True(t, job.Completed)
+	assert.Len(t, p.jobCompletionQueue, 0)
+
+	// Nor does an old job scan within the delay pick it up
+	p.submitOldJobsForCompletion()
+	assert.Len(t, p.jobCompletionQueue, 0)
+
+	select {
+	case jobInfo := <-p.jobCompletionQueue:
+		assert.Equal(t, jobAddress.Bytes(), jobInfo.jobAddressBytes)
+		assert.True(t, time.Since(funded) >= p.completionDelay)
+	case <-time.After(5 * time.Second):
+		t.Fatal("job completion not enqueued after delay")
+	}
+
+	// The delay runs from the funding, and a job not yet seen funded waits all of it
+	now := time.Now()
+	assert.Equal(t, 50*time.Millisecond, p.remainingCompletionDelay(jobFundedState,
+		now.Add(-150*time.Millisecond), now))
+	assert.True(t, p.remainingCompletionDelay(jobFundedState, now.Add(-time.Second), now) <= 0)
+	assert.Equal(t, p.completionDelay, p.remainingCompletionDelay(jobPendingState, now, now))
+	assert.Equal(t, time.Duration(0), p.remainingCompletionDelay(jobFundedState, time.Time{}, now))
+
+	// Writes to the job after its funding, such as a re-scan of the funding itself, don't restart the delay
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	fundedAt := job.FundedAt
+	require.False(t, fundedAt.IsZero())
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{{Topics: []common.Hash{testEvents.jobFundedID},
+		Data: common.LeftPadBytes(jobAddress.Bytes(), 32)}}, big.NewInt(2), common.Hash{}))
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.True(t, fundedAt.Equal(job.FundedAt))
+	assert.True(t, job.UpdatedAt.After(fundedAt))
+
+	p.completionDelay = 0
+	assert.Equal(t, time.Duration(0), p.remainingCompletionDelay(jobPendingState, now, now))
+}
+
+func TestCompletionPolicy(t *testing.T) {
+	for _, policy := range []string{completionOnSignal, completionOnFunded, completionManual} {
+		t.Run(policy, func(t *testing.T) {
+			boltDB, cleanup := newTestDB(t)
+			defer cleanup()
+
+			p, cancel := newTestProcessor(boltDB)
+			defer cancel()
+			p.completionPolicy = policy
+			p.agents[0].sigHasher = func(i []byte) []byte { return crypto.Keccak256(i) }
+
+			consumerKey, err := crypto.GenerateKey()
+			require.NoError(t, err)
+			jobAddress := common.HexToAddress("0x1234")
+			signature, err := crypto.Sign(p.agents[0].sigHasher(jobAddress.Bytes()), consumerKey)
+			require.NoError(t, err)
+			require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+				jobBytes, err := json.Marshal(db.Job{JobAddress: jobAddress.Bytes(), JobState: jobFundedState,
+					Consumer: crypto.PubkeyToAddress(consumerKey.PublicKey).Bytes()})
+				require.NoError(t, err)
+				return tx.Bucket(db.JobBucketName).Put(jobAddress.Bytes(), jobBytes)
+			}))
+			completed := func() bool {
+				job, err := db.GetJob(boltDB, jobAddress.Bytes())
+				require.NoError(t, err)
+				return job.Completed
+			}
+
+			// Only on_funded moves the job toward completion as soon as its invocation is validated
+			require.True(t, p.IsValidJobInvocation(jobAddress.Bytes(), signature))
+			if policy == completionOnFunded {
+				assert.True(t, completed())
+				assert.Len(t, p.jobCompletionQueue, 1)
+			} else {
+				assert.False(t, completed())
+				assert.Len(t, p.jobCompletionQueue, 0)
+			}
+
+			// The served call's signal queues it under on_signal; under on_funded it is already queued, and
+			// under manual it is only marked completed
+			p.CompleteJob(jobAddress.Bytes(), signature)
+			assert.True(t, completed())
+			if policy == completionManual {
+				assert.Len(t, p.jobCompletionQueue, 0)
+			} else {
+				require.Len(t, p.jobCompletionQueue, 1)
+				<-p.jobCompletionQueue
+				p.inFlight.remove(jobAddress.Bytes())
+			}
+
+			// A later old job scan resubmits the completed job unless it is left for manual completion
+			p.submitOldJobsForCompletion()
+			if policy == completionManual {
+				assert.Len(t, p.jobCompletionQueue, 0)
+			} else {
+				assert.Len(t, p.jobCompletionQueue, 1)
+			}
+		})
+	}
+}
+
+func TestObserverMode(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	head := &types.Header{Number: big.NewInt(42), Difficulty: big.NewInt(1), Time: big.NewInt(1),
+		Extra: []byte{}}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &FakeChain{head: head}))
+	client := rpc.DialInProc(server)
+
+	// An observer has no signer
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.enabled, p.observerMode = true, true
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 100
+	p.status = &processorStatus{}
+	p.pollBackoff, p.pollBackoffMax = &backoff{}, time.Second
+	p.pollInterval = newPollInterval(time.Millisecond, time.Millisecond, time.Millisecond)
+	p.loops, p.closeQueue = &sync.WaitGroup{}, &sync.Once{}
+
+	// A completed job that a signing daemon would resubmit at startup
+	oldJob, newJob := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		for _, job := range []db.Job{
+			{JobAddress: oldJob.Bytes(), JobState: jobFundedState, Completed: true, JobSignature: []byte{1}},
+			{JobAddress: newJob.Bytes(), JobState: jobFundedState},
+		} {
+			jobBytes, err := json.Marshal(job)
+			require.NoError(t, err)
+			require.NoError(t, tx.Bucket(db.JobBucketName).Put(job.JobAddress, jobBytes))
+		}
+		return nil
+	}))
+
+	require.NoError(t, p.StartLoop())
+	for deadline := time.Now().Add(5 * time.Second); p.status.lastPollTime().IsZero(); {
+		require.True(t, time.Now().Before(deadline), "event loop never polled")
+		time.Sleep(time.Millisecond)
+	}
+
+	// Jobs the service finishes are recorded but not queued for completion
+	p.CompleteJob(newJob.Bytes(), []byte{2})
+	job, err := db.GetJob(boltDB, newJob.Bytes())
+	require.NoError(t, err)
+	assert.True(t, job.Completed)
+
+	_, err = p.SubmitJobForCompletion(context.Background(), newJob.Bytes(), []byte{2})
+	assert.Error(t, err)
+
+	require.NoError(t, p.Stop(context.Background()))
+	assert.Len(t, p.jobCompletionQueue, 0)
+	block, _ := getCursor(t, boltDB)
+	assert.Equal(t, head.Number, block)
+}
+
+func TestJobFundedKeepsFailedState(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	jobAddress := common.HexToAddress("0x1234")
+	jobBytes, err := json.Marshal(db.Job{JobAddress: jobAddress.Bytes(), JobState: jobFailedState})
+	require.NoError(t, err)
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.JobBucketName).Put(jobAddress.Bytes(), jobBytes)
+	}))
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: common.LeftPadBytes(jobAddress.Bytes(), 32)},
+	}, big.NewInt(1), common.Hash{}))
+
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobFailedState, job.JobState)
+}
+
+func TestJobFundedBeforeJobCreated(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB}
+	agent, jobAddress, consumer := common.HexToAddress("0xaaaa"), common.HexToAddress("0x1234"),
+		common.HexToAddress("0x5678")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	funded := types.Log{Address: agent, Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress)}
+
+	// A lone JobFunded for a job never seen created stores the job's and agent's addresses, and no consumer
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{funded}, big.NewInt(1), common.Hash{}))
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobAddress.Bytes(), job.JobAddress)
+	assert.Equal(t, agent.Bytes(), job.AgentAddress)
+	assert.Empty(t, job.Consumer)
+	assert.Equal(t, jobFundedState, job.JobState)
+
+	// The JobCreated handled afterwards fills in the consumer without downgrading the state
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Address: agent, Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress),
+			word(consumer)...)},
+	}, big.NewInt(2), common.Hash{}))
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobAddress.Bytes(), job.JobAddress)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+	assert.Equal(t, jobFundedState, job.JobState)
+
+	// Another JobFunded, e.g. re-scanned after a reorg, keeps the consumer
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{funded}, big.NewInt(3), common.Hash{}))
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobAddress.Bytes(), job.JobAddress)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+}
+
+func TestJobFundedStoresAmount(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	a, err := abi.JSON(strings.NewReader(fundedAgentABI))
+	require.NoError(t, err)
+	events := newJobEvents(a)
+
+	// More wei than fits in a uint64 is stored exactly
+	p := Processor{boltDB: boltDB}
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	amount, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	require.NoError(t, p.commitJobLogs(events, []types.Log{
+		{Topics: []common.Hash{events.jobFundedID}, Data: append(word(jobAddress), common.LeftPadBytes(amount.Bytes(),
+			32)...)},
+	}, big.NewInt(1), common.Hash{}))
+
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, jobFundedState, job.JobState)
+	assert.Equal(t, amount, job.FundedAmount)
+
+	// A JobCreated handled after the funding keeps the amount
+	require.NoError(t, p.commitJobLogs(events, []types.Log{
+		{Topics: []common.Hash{events.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...)},
+	}, big.NewInt(2), common.Hash{}))
+
+	job, err = db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+	assert.Equal(t, amount, job.FundedAmount)
+}
+
+func TestJobCompletedByDaemon(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.listeners = &jobListeners{}
+	recorder := make(transitionRecorder, 10)
+	p.AddJobStateListener(recorder)
+
+	ours, theirs, unknown := common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(ours)},
+		{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(theirs)},
+	}, big.NewInt(1), common.Hash{}))
+	_, err := p.markJobCompleted(ours.Bytes(), []byte{0x5e}, false)
+	require.NoError(t, err)
+	for range []int{1, 2} {
+		<-recorder
+	}
+
+	counter := func(completedBy string) float64 { return counterValue(t, jobsCompleted.WithLabelValues(completedBy)) }
+	daemonBefore, otherBefore := counter("daemon"), counter("other")
+
+	require.NoError(t, p.commitJobLogs(testEvents, []types.Log{
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(ours)},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(theirs)},
+		{Topics: []common.Hash{testEvents.jobCompletedID}, Data: word(unknown)},
+	}, big.NewInt(2), common.Hash{}))
+
+	completedByDaemon := make(map[common.Address]bool)
+	for range []int{1, 2, 3} {
+		select {
+		case transition := <-recorder:
+			assert.Equal(t, jobCompletedState, transition.NewState)
+			completedByDaemon[transition.JobAddress] = transition.completedByDaemon
+		case <-time.After(time.Second):
+			t.Fatal("missing completion transition")
+		}
+	}
+	assert.Equal(t, map[common.Address]bool{ours: true, theirs: false, unknown: false}, completedByDaemon)
+	assert.Equal(t, float64(1), counter("daemon")-daemonBefore)
+	assert.Equal(t, float64(2), counter("other")-otherBefore)
+
+	// Both known jobs are gone from the db regardless of who completed them
+	for _, jobAddress := range []common.Address{ours, theirs} {
+		job, err := db.GetJob(boltDB, jobAddress.Bytes())
+		require.NoError(t, err)
+		assert.Nil(t, job)
+	}
+}
+
+func TestConcurrentJobUpdates(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p := Processor{boltDB: boltDB, maxCompletionAttempts: 1000}
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	signature := []byte{0x5e}
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	created := types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress), word(consumer)...)}
+	funded := types.Log{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress)}
+
+	// Event handling, local completion and failed attempts all race on the same job
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, p.commitJobLogs(testEvents, []types.Log{created, funded}, big.NewInt(1), common.Hash{}))
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := p.markJobCompleted(jobAddress.Bytes(), signature, false)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := p.recordCompletionAttempt(jobAddress.Bytes(), false)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// No writer's change was lost to another's
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, n, job.CompletionAttempts)
+	assert.Equal(t, jobFundedState, job.JobState)
+	assert.Equal(t, consumer.Bytes(), job.Consumer)
+	assert.True(t, job.Completed)
+	assert.Equal(t, signature, job.JobSignature)
+}
+
+func TestEnqueueJobCompletionDeduplicates(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+
+	jobAddress := common.HexToAddress("0x1234").Bytes()
+	p.enqueueJobCompletion(&jobInfo{jobAddressBytes: jobAddress})
+	p.enqueueJobCompletion(&jobInfo{jobAddressBytes: jobAddress})
+	assert.Len(t, p.jobCompletionQueue, 1)
+
+	// Once the completion resolves the job may be enqueued again
+	<-p.jobCompletionQueue
+	p.inFlight.remove(jobAddress)
+	p.enqueueJobCompletion(&jobInfo{jobAddressBytes: jobAddress})
+	assert.Len(t, p.jobCompletionQueue, 1)
+}
+
+func TestSubmitOldJobsFullQueue(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.jobCompletionQueue = make(chan *jobInfo, 1)
+
+	defer func(interval time.Duration) { oldJobRetryInterval = interval }(oldJobRetryInterval)
+	oldJobRetryInterval = 10 * time.Millisecond
+
+	states := make(map[common.Address]string)
+	for i := int64(1); i <= 3; i++ {
+		states[common.BigToAddress(big.NewInt(i))] = jobFundedState
+	}
+	putCompletedJobs(t, p, states)
+
+	done := make(chan struct{})
+	go func() {
+		p.submitOldJobsForCompletion()
+		close(done)
+	}()
+
+	// A slow consumer eventually receives every job
+	received := make(map[common.Address]bool)
+	for len(received) < len(states) {
+		select {
+		case jobInfo := <-p.jobCompletionQueue:
+			received[common.BytesToAddress(jobInfo.jobAddressBytes)] = true
+			time.Sleep(20 * time.Millisecond)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %v of %v old jobs", len(received), len(states))
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("old job scan did not finish")
+	}
+}
+
+func TestResubmitOldJobsRescans(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	p, cancel := newTestProcessor(boltDB)
+	p.oldJobScanInterval = 20 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		p.resubmitOldJobs()
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	receive := func() *jobInfo {
+		select {
+		case jobInfo := <-p.jobCompletionQueue:
+			return jobInfo
+		case <-time.After(5 * time.Second):
+			t.Fatal("old job was not rescanned")
+			return nil
+		}
+	}
+
+	// The job may be written before or after the first pass; either way a pass finds it
+	jobAddress := common.HexToAddress("0x01")
+	putCompletedJobs(t, p, map[common.Address]string{jobAddress: jobFundedState})
+	assert.Equal(t, jobAddress.Bytes(), receive().jobAddressBytes)
+
+	// It isn't queued again while in flight, but is once its completion attempt has ended without completing it
+	time.Sleep(3 * p.oldJobScanInterval)
+	assert.Empty(t, p.jobCompletionQueue)
+	p.inFlight.remove(jobAddress.Bytes())
+	assert.Equal(t, jobAddress.Bytes(), receive().jobAddressBytes)
+}
+
+// HangingEth serves eth_ methods that never respond until released, like a node on a hung connection
+type HangingEth struct {
+	release chan struct{}
+}
+
+func (h *HangingEth) BlockNumber() string {
+	<-h.release
+	return "0x1"
+}
+
+func (h *HangingEth) GetBlockByNumber(number string, full bool) map[string]interface{} {
+	<-h.release
+	return nil
+}
+
+func (h *HangingEth) GetLogs(query map[string]interface{}) []types.Log {
+	<-h.release
+	return nil
+}
+
+func TestPollEventsTimesOut(t *testing.T) {
+	eth := &HangingEth{release: make(chan struct{})}
+	defer close(eth.release)
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", eth))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(nil)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.rpcTimeout = 50 * time.Millisecond
+	p.pollBackoff = &backoff{}
+
+	done := make(chan struct{})
+	go func() {
+		p.processEventsOnce(testEvents)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event poll hung on an unresponsive node")
+	}
+	assert.Equal(t, uint(1), p.pollBackoff.consecutiveFailures())
+
+	start := time.Now()
+	err := p.processEventRange(testEvents, big.NewInt(1), big.NewInt(2), common.Hash{})
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+// FakeChain serves a single block as the chain head, counting the log queries made against it
+type FakeChain struct {
+	head       *types.Header
+	logQueries int
+}
+
+func (f *FakeChain) BlockNumber() *hexutil.Big {
+	return (*hexutil.Big)(f.head.Number)
+}
+
+func (f *FakeChain) GetBlockByNumber(number string, full bool) *types.Header {
+	return f.head
+}
+
+func (f *FakeChain) GetLogs(query map[string]interface{}) []types.Log {
+	f.logQueries++
+	return []types.Log{}
+}
+
+func TestPollEventsCapturesHead(t *testing.T) {
+	head := &types.Header{Number: big.NewInt(42), Difficulty: big.NewInt(1), Time: big.NewInt(1),
+		Extra: []byte{}}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &FakeChain{head: head}))
+	client := rpc.DialInProc(server)
+
+	for _, rawBlockLookup := range []bool{false, true} {
+		boltDB, cleanup := newTestDB(t)
+
+		p, cancel := newTestProcessor(boltDB)
+		p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+		p.rawBlockLookup = rawBlockLookup
+		p.logScanChunkSize = 10
+		p.status = &processorStatus{}
+
+		number, hash, err := p.chainHead(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, head.Number, number)
+		if !rawBlockLookup {
+			assert.Equal(t, head.Hash(), hash)
+		}
+
+		require.True(t, p.pollEvents(testEvents))
+		require.NoError(t, boltDB.View(func(tx *bolt.Tx) error {
+			chain := tx.Bucket(db.ChainBucketName)
+			assert.Equal(t, head.Number, new(big.Int).SetBytes(chain.Get(db.LastBlockKey)))
+			assert.Equal(t, head.Hash().Bytes(), chain.Get(db.LastBlockHashKey))
+			return nil
+		}))
+
+		cancel()
+		cleanup()
+	}
+}
+
+// FinalityChain serves blocks by number or tag, with the finalized block trailing the head; a nil finalized block is
+// reported missing as by a node without the tag
+type FinalityChain struct {
+	head, finalized      *big.Int
+	fromBlocks, toBlocks []string
+}
+
+func (f *FinalityChain) GetBlockByNumber(number string, full bool) *types.Header {
+	n := f.head
+	switch number {
+	case "latest":
+	case "finalized":
+		if f.finalized == nil {
+			return nil
+		}
+		n = f.finalized
+	default:
+		n = hexutil.MustDecodeBig(number)
+	}
+	return &types.Header{Number: n, Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}}
+}
+
+func (f *FinalityChain) GetLogs(query map[string]interface{}) []types.Log {
+	f.fromBlocks = append(f.fromBlocks, query["fromBlock"].(string))
+	f.toBlocks = append(f.toBlocks, query["toBlock"].(string))
+	return []types.Log{}
+}
+
+func TestPollEventsFinalityStrategy(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		finalizedTag bool
+		finalized    *big.Int
+		ok           bool
+		scannedTo    int64
+	}{
+		{name: "confirmations", finalized: big.NewInt(30), ok: true, scannedTo: 40},
+		{name: "finalized tag", finalizedTag: true, finalized: big.NewInt(30), ok: true, scannedTo: 30},
+		{name: "finalized tag unsupported", finalizedTag: true, scannedTo: 25},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			boltDB, cleanup := newTestDB(t)
+			defer cleanup()
+
+			chain := &FinalityChain{head: big.NewInt(42), finalized: tt.finalized}
+			server := rpc.NewServer()
+			require.NoError(t, server.RegisterName("eth", chain))
+			client := rpc.DialInProc(server)
+
+			p, cancel := newTestProcessor(boltDB)
+			defer cancel()
+			p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+			p.logScanChunkSize = 100
+			p.status = &processorStatus{}
+			p.blockConfirmations = 3
+			p.finalizedTag = tt.finalizedTag
+
+			require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+				return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(25), common.Hash{})
+			}))
+
+			assert.Equal(t, tt.ok, p.pollEvents(testEvents))
+			block, hash := getCursor(t, boltDB)
+			assert.Equal(t, big.NewInt(tt.scannedTo), block)
+			if tt.ok {
+				assert.Equal(t, []string{hexutil.EncodeBig(big.NewInt(tt.scannedTo))}, chain.toBlocks)
+				// The cursor's hash is computed as reorg detection computes it
+				assert.Equal(t, chain.GetBlockByNumber(hexutil.EncodeBig(block), false).Hash().Bytes(), hash)
+			} else {
+				assert.Empty(t, chain.toBlocks)
+			}
+		})
+	}
+}
+
+func TestPollEventsMaxBlocksPerPoll(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	chain := &FinalityChain{head: big.NewInt(20)}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 100
+	p.status = &processorStatus{}
+	p.maxBlocksPerPoll = 6
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(0), common.Hash{})
+	}))
+
+	// Each poll advances the cursor by at most six blocks until it reaches the head
+	for _, expected := range []int64{6, 12, 18, 20, 20} {
+		require.True(t, p.pollEvents(testEvents))
+		block, _ := getCursor(t, boltDB)
+		assert.Equal(t, big.NewInt(expected), block)
+		assert.Equal(t, expected < 20, p.status.isBackfilling())
+	}
+	assert.Equal(t, []string{"0x6", "0xc", "0x12", "0x14"}, chain.toBlocks)
+}
+
+func TestPollEventsStartBlock(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		startBlock *big.Int
+		fromBlock  int64
+	}{
+		{name: "start block", startBlock: big.NewInt(10), fromBlock: 10},
+		{name: "no start block", fromBlock: 42},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			boltDB, cleanup := newTestDB(t)
+			defer cleanup()
+
+			chain := &FinalityChain{head: big.NewInt(42)}
+			server := rpc.NewServer()
+			require.NoError(t, server.RegisterName("eth", chain))
+			client := rpc.DialInProc(server)
+
+			p, cancel := newTestProcessor(boltDB)
+			defer cancel()
+			p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+			p.logScanChunkSize = 100
+			p.status = &processorStatus{}
+			p.startBlock = tt.startBlock
+
+			// With no cursor persisted the first scan begins at the start block, or at the head without one
+			require.True(t, p.pollEvents(testEvents))
+			assert.Equal(t, []string{hexutil.EncodeBig(big.NewInt(tt.fromBlock))}, chain.fromBlocks)
+			assert.Equal(t, []string{"0x2a"}, chain.toBlocks)
+			block, hash := getCursor(t, boltDB)
+			assert.Equal(t, big.NewInt(42), block)
+			assert.Equal(t, chain.GetBlockByNumber("latest", false).Hash().Bytes(), hash)
+
+			// Once a cursor is persisted the start block no longer applies
+			chain.head = big.NewInt(45)
+			require.True(t, p.pollEvents(testEvents))
+			assert.Equal(t, "0x2b", chain.fromBlocks[1])
+		})
+	}
+}
+
+func TestPollEventsReorgRewind(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	chain := &FinalityChain{head: big.NewInt(42)}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 100
+	p.status = &processorStatus{}
+	p.reorgRewindDepth = 5
+
+	// The block the cursor was left at has since been replaced on the canonical chain
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(30), common.HexToHash("0xdead"))
+	}))
+
+	// The cursor walks back by the rewind depth and the range after it is scanned again up to the head
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, []string{"0x1a"}, chain.fromBlocks)
+	assert.Equal(t, []string{"0x2a"}, chain.toBlocks)
+	block, hash := getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(42), block)
+	assert.Equal(t, chain.GetBlockByNumber("latest", false).Hash().Bytes(), hash)
+
+	// With the cursor's block canonical, the next poll carries on from it without rewinding
+	chain.head = big.NewInt(45)
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, "0x2b", chain.fromBlocks[1])
+}
+
+func TestPollEventsCaughtUp(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	head := &types.Header{Number: big.NewInt(42), Difficulty: big.NewInt(1), Time: big.NewInt(1),
+		Extra: []byte{}}
+	chain := &FakeChain{head: head}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 10
+	p.status = &processorStatus{}
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), head.Number, head.Hash())
+	}))
+
+	// With the cursor at the head there are no blocks to scan
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, 0, chain.logQueries)
+	assert.False(t, p.status.lastPollTime().IsZero())
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(41), common.Hash{})
+	}))
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, 1, chain.logQueries)
+}
+
+func TestPollEventsBlocksBehind(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	head := &types.Header{Number: big.NewInt(42), Difficulty: big.NewInt(1), Time: big.NewInt(1),
+		Extra: []byte{}}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", &FakeChain{head: head}))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 100
+	p.status = &processorStatus{}
+	p.blocksBehindWarning = 20
+
+	out := logger.Out
+	defer func() { logger.Out = out }()
+	buf := &bytes.Buffer{}
+	logger.Out = buf
+
+	// The daemon was down while the chain advanced 30 blocks
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(12), common.Hash{})
+	}))
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, float64(30), gaugeValue(t, blocksBehind))
+	assert.Contains(t, buf.String(), "far behind the chain")
+
+	// Having caught up, the next poll reports no gap and doesn't warn
+	buf.Reset()
+	require.True(t, p.pollEvents(testEvents))
+	assert.Equal(t, float64(0), gaugeValue(t, blocksBehind))
+	assert.NotContains(t, buf.String(), "far behind the chain")
+}
+
+func TestPollIntervalAdapts(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	head := &types.Header{Number: big.NewInt(42), Difficulty: big.NewInt(1), Time: big.NewInt(1),
+		Extra: []byte{}}
+	chain := &FakeChain{head: head}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanChunkSize = 10
+	p.status = &processorStatus{}
+	p.pollInterval = newPollInterval(4*time.Second, time.Second, 8*time.Second)
+
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(41), common.Hash{})
+	}))
+
+	// Each poll either scans the blocks the chain has advanced by or finds nothing new
+	for _, tt := range []struct {
+		head     int64
+		interval time.Duration
+	}{
+		{head: 42, interval: 2 * time.Second},
+		{head: 42, interval: 4 * time.Second},
+		{head: 42, interval: 8 * time.Second},
+		{head: 42, interval: 8 * time.Second},
+		{head: 43, interval: 4 * time.Second},
+		{head: 50, interval: 2 * time.Second},
+		{head: 51, interval: time.Second},
+		{head: 52, interval: time.Second},
+	} {
+		head.Number = big.NewInt(tt.head)
+		require.True(t, p.pollEvents(testEvents))
+		assert.Equal(t, tt.interval, p.pollInterval.current(), "after poll at head %v", tt.head)
+	}
+}
+
+// FakeLogs serves log queries like a provider that refuses ranges of more than maxRange blocks
+type FakeLogs struct {
+	maxRange int64
+	queries  [][2]int64
+}
+
+func (f *FakeLogs) GetLogs(query map[string]interface{}) ([]types.Log, error) {
+	from := new(big.Int).SetBytes(common.FromHex(query["fromBlock"].(string))).Int64()
+	to := new(big.Int).SetBytes(common.FromHex(query["toBlock"].(string))).Int64()
+	f.queries = append(f.queries, [2]int64{from, to})
+
+	if to-from+1 > f.maxRange {
+		return nil, fmt.Errorf("query returned more than %v results", 10000)
+	}
+	return []types.Log{}, nil
+}
+
+func (f *FakeLogs) GetBlockByNumber(number string, full bool) *types.Header {
+	return &types.Header{Number: new(big.Int).SetBytes(common.FromHex(number)), Difficulty: big.NewInt(1),
+		Time: big.NewInt(1), Extra: []byte{}}
+}
+
+func TestPollJitter(t *testing.T) {
+	p := Processor{pollInterval: newPollInterval(10*time.Second, 10*time.Second, 10*time.Second),
+		pollBackoff: &backoff{}, pollBackoffMax: time.Minute}
+
+	// Without jitter every sleep is the poll interval
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 10*time.Second, p.nextPollDelay())
+	}
+
+	// With it, sleeps spread over the interval ± the jitter fraction
+	p.pollJitter = 0.2
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 1000; i++ {
+		delay := p.nextPollDelay()
+		assert.True(t, delay >= 8*time.Second && delay <= 12*time.Second, "delay %v outside jittered range", delay)
+		seen[delay] = true
+	}
+	assert.True(t, len(seen) > 1)
+}
+
+func TestProcessEventRangeSplitsLargeRanges(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	fakeLogs := &FakeLogs{maxRange: 3}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", fakeLogs))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+	p.logScanMaxSplits = 3
+
+	require.NoError(t, p.processEventRange(testEvents, big.NewInt(10), big.NewInt(19), common.Hash{}))
+
+	// Every block was covered by a query that succeeded, and the cursor reached the end of the range
+	covered := map[int64]bool{}
+	for _, query := range fakeLogs.queries {
+		if query[1]-query[0]+1 <= fakeLogs.maxRange {
+			for block := query[0]; block <= query[1]; block++ {
+				covered[block] = true
+			}
+		}
+	}
+	for block := int64(10); block <= 19; block++ {
+		assert.True(t, covered[block], "block %v not scanned", block)
+	}
+	require.NoError(t, boltDB.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, big.NewInt(19), new(big.Int).SetBytes(tx.Bucket(db.ChainBucketName).Get(db.LastBlockKey)))
+		return nil
+	}))
+
+	// Past the split limit the error is returned
+	fakeLogs.maxRange, fakeLogs.queries = 0, nil
+	assert.Error(t, p.processEventRange(testEvents, big.NewInt(20), big.NewInt(29), common.Hash{}))
+	assert.Len(t, fakeLogs.queries, p.logScanMaxSplits+1)
+}
+
+func TestIsTooManyLogsError(t *testing.T) {
+	assert.True(t, isTooManyLogsError(errors.New("query returned more than 10000 results")))
+	assert.True(t, isTooManyLogsError(errors.New("Log response size exceeded. You can make eth_getLogs requests")))
+	assert.False(t, isTooManyLogsError(errors.New("connection refused")))
+}
+
+// FakePrunedLogs serves log queries like a full node that has pruned the history of blocks before prunedBefore
+type FakePrunedLogs struct {
+	prunedBefore int64
+	queries      [][2]int64
+}
+
+func (f *FakePrunedLogs) GetLogs(query map[string]interface{}) ([]types.Log, error) {
+	from := new(big.Int).SetBytes(common.FromHex(query["fromBlock"].(string))).Int64()
+	to := new(big.Int).SetBytes(common.FromHex(query["toBlock"].(string))).Int64()
+	f.queries = append(f.queries, [2]int64{from, to})
+
+	if from < f.prunedBefore {
+		return nil, fmt.Errorf("missing trie node %x (path )", crypto.Keccak256([]byte{byte(from)}))
+	}
+	return []types.Log{}, nil
+}
+
+func (f *FakePrunedLogs) GetBlockByNumber(number string, full bool) *types.Header {
+	return &types.Header{Number: new(big.Int).SetBytes(common.FromHex(number)), Difficulty: big.NewInt(1),
+		Time: big.NewInt(1), Extra: []byte{}}
+}
+
+func TestProcessEventRangeMissingHistory(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	fakeLogs := &FakePrunedLogs{prunedBefore: 15}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", fakeLogs))
+	client := rpc.DialInProc(server)
+
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.rawClient, p.ethClient = client, ethclient.NewClient(client)
+
+	// Without HISTORY_SKIP_TO_BLOCK the range fails with an error naming the fix, and the cursor doesn't move
+	err := p.processEventRange(testEvents, big.NewInt(10), big.NewInt(19), common.Hash{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "node has no history for blocks 10 to 19")
+	assert.Contains(t, err.Error(), "archive node")
+	block, _ := getCursor(t, boltDB)
+	assert.Zero(t, block.Sign())
+
+	// With it, the pruned blocks below it are skipped and the rest of the range is scanned
+	p.historySkipTo = big.NewInt(15)
+	fakeLogs.queries = nil
+	require.NoError(t, p.processEventRange(testEvents, big.NewInt(10), big.NewInt(19), common.Hash{}))
+	assert.Equal(t, [][2]int64{{10, 19}, {15, 19}}, fakeLogs.queries)
+	block, _ = getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(19), block)
+
+	// Missing history at or past HISTORY_SKIP_TO_BLOCK isn't skipped
+	fakeLogs.prunedBefore = 30
+	assert.Error(t, p.processEventRange(testEvents, big.NewInt(20), big.NewInt(29), common.Hash{}))
+	block, _ = getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(19), block)
+}
+
+func TestIsMissingHistoryError(t *testing.T) {
+	assert.True(t, isMissingHistoryError(errors.New("missing trie node 1b2c3d (path )")))
+	assert.True(t, isMissingHistoryError(errors.New("block not found")))
+	assert.True(t, isMissingHistoryError(errors.New("header not found")))
+	assert.False(t, isMissingHistoryError(errors.New("connection refused")))
+	assert.False(t, isMissingHistoryError(errors.New("query returned more than 10000 results")))
+}
+
+// streamingEthClient delivers live logs over a subscription, failing it once they have all been received
+type streamingEthClient struct {
+	*fakeEthClient
+	live []types.Log
+}
+
+func (c *streamingEthClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery,
+	ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub := &fakeSubscription{err: make(chan error, 1)}
+	go func() {
+		for _, jobLog := range c.live {
+			ch <- jobLog
+		}
+		sub.err <- errors.New("subscription closed")
+	}()
+	return sub, nil
+}
+
+type fakeSubscription struct {
+	err chan error
+}
+
+func (s *fakeSubscription) Err() <-chan error { return s.err }
+
+func (s *fakeSubscription) Unsubscribe() {}
+
+func TestStreamEventsCatchUp(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	jobAddress, liveAddress := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	consumer := common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	created := types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(jobAddress),
+		word(consumer)...), BlockNumber: 11, TxHash: common.Hash{1}}
+	funded := types.Log{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress), BlockNumber: 12,
+		TxHash: common.Hash{2}}
+	liveCreated := types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(liveAddress),
+		word(consumer)...), BlockNumber: 14, TxHash: common.Hash{3}}
+
+	// The cursor was left at block 10 by the last poll and the subscription streams from block 12 on, so only the
+	// catch-up sees block 11 while both see block 12
+	head := &types.Header{Number: big.NewInt(13), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}}
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.ethClient = &streamingEthClient{&fakeEthClient{head: head, logs: []types.Log{created, funded}},
+		[]types.Log{funded, liveCreated}}
+	p.status, p.pollSleep = &processorStatus{}, time.Hour
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(10), common.Hash{})
+	}))
+
+	fundedEvents := counterValue(t, eventsProcessed.WithLabelValues("JobFunded"))
+	assert.Error(t, p.streamEvents(testEvents, p.cursorGeneration()))
+
+	job, err := db.GetJob(boltDB, jobAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, jobFundedState, job.JobState)
+	liveJob, err := db.GetJob(boltDB, liveAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, liveJob)
+	assert.Equal(t, jobPendingState, liveJob.JobState)
+
+	// The funding in the overlap was applied once, and the cursor follows the stream
+	assert.Equal(t, fundedEvents+1, counterValue(t, eventsProcessed.WithLabelValues("JobFunded")))
+	block, _ := getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(13), block)
+}
+
+func TestStreamEventsOutOfOrder(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	jobAddress, lateAddress := common.HexToAddress("0x1234"), common.HexToAddress("0x5678")
+	consumer := common.HexToAddress("0xc1")
+	word := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+	funded := types.Log{Topics: []common.Hash{testEvents.jobFundedID}, Data: word(jobAddress), BlockNumber: 16,
+		BlockHash: common.Hash{16}, TxHash: common.Hash{1}}
+	lateCreated := types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(word(lateAddress),
+		word(consumer)...), BlockNumber: 14, BlockHash: common.Hash{14}, TxHash: common.Hash{2}}
+	// The same transaction and index in a block that replaced block 16 is a different log
+	reorged := funded
+	reorged.BlockHash = common.Hash{0x16}
+
+	// A later block's log is delivered twice, and both arrive before an earlier block's
+	head := &types.Header{Number: big.NewInt(13), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}}
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	p.ethClient = &streamingEthClient{&fakeEthClient{head: head}, []types.Log{funded, funded, reorged, lateCreated}}
+	p.status, p.pollSleep = &processorStatus{}, time.Hour
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(10), common.Hash{})
+	}))
+
+	fundedEvents := counterValue(t, eventsProcessed.WithLabelValues("JobFunded"))
+	assert.Error(t, p.streamEvents(testEvents, p.cursorGeneration()))
+
+	lateJob, err := db.GetJob(boltDB, lateAddress.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, lateJob)
+	assert.Equal(t, jobPendingState, lateJob.JobState)
+
+	// The repeated log was skipped, and the late one didn't move the cursor back
+	assert.Equal(t, fundedEvents+2, counterValue(t, eventsProcessed.WithLabelValues("JobFunded")))
+	block, _ := getCursor(t, boltDB)
+	assert.Equal(t, big.NewInt(15), block)
+}
+
+// heldStreamingEthClient is a streamingEthClient that holds its live logs until release is closed
+type heldStreamingEthClient struct {
+	*streamingEthClient
+	release chan struct{}
+}
+
+func (c *heldStreamingEthClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery,
+	ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub := &fakeSubscription{err: make(chan error, 1)}
+	go func() {
+		<-c.release
+		for _, jobLog := range c.live {
+			ch <- jobLog
+		}
+		sub.err <- errors.New("subscription closed")
+	}()
+	return sub, nil
+}
+
+func TestStreamEventsCommitFailure(t *testing.T) {
+	boltDB, cleanup := newTestDB(t)
+	defer cleanup()
+
+	jobAddress, consumer := common.HexToAddress("0x1234"), common.HexToAddress("0xc1")
+	created := types.Log{Topics: []common.Hash{testEvents.jobCreatedID}, Data: append(
+		common.LeftPadBytes(jobAddress.Bytes(), 32), common.LeftPadBytes(consumer.Bytes(), 32)...), BlockNumber: 14,
+		TxHash: common.Hash{1}}
+
+	head := &types.Header{Number: big.NewInt(13), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: []byte{}}
+	p, cancel := newTestProcessor(boltDB)
+	defer cancel()
+	release := make(chan struct{})
+	p.ethClient = &heldStreamingEthClient{&streamingEthClient{&fakeEthClient{head: head}, []types.Log{created}},
+		release}
+	p.status, p.pollSleep = &processorStatus{}, time.Hour
+	require.NoError(t, boltDB.Update(func(tx *bolt.Tx) error {
+		return putCursor(tx.Bucket(db.ChainBucketName), big.NewInt(10), common.Hash{})
+	}))
+
+	// Once caught up, the db stops accepting writes before the live log arrives
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if block, err := p.LastProcessedBlock(); err == nil && block != nil && block.Cmp(head.Number) == 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		boltDB.Close()
+		close(release)
+	}()
+
+	// The subscription is dropped rather than carrying on past the log it failed to commit
+	err := p.streamEvents(testEvents, p.cursorGeneration())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error committing streamed job event")
+}
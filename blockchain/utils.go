@@ -3,9 +3,12 @@ package blockchain
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/tyler-smith/go-bip39"
 )
 
@@ -31,6 +34,21 @@ func derivePrivateKey(mnemonic string, path ...uint32) (*ecdsa.PrivateKey, error
 	return privKey.ToECDSA(), nil
 }
 
+// loadKeystoreKey decrypts the private key from a Web3 Secret Storage (UTC/JSON keystore) file
+func loadKeystoreKey(path, passphrase string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.PrivateKey, nil
+}
+
 func parseSignature(jobSignatureBytes []byte) (uint8, [32]byte, [32]byte, error) {
 	r := [32]byte{}
 	s := [32]byte{}
@@ -45,3 +63,39 @@ func parseSignature(jobSignatureBytes []byte) (uint8, [32]byte, [32]byte, error)
 
 	return v, r, s, nil
 }
+
+func scaleGasPrice(gasPrice *big.Int, multiplier float64) *big.Int {
+	if multiplier == 1 {
+		return new(big.Int).Set(gasPrice)
+	}
+
+	scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(gasPrice), big.NewFloat(multiplier)).Int(nil)
+	return scaled
+}
+
+// dynamicFeeGasPrice prices a legacy transaction for an EIP-1559 chain: the base fee, with headroom for the largest
+// possible base fee increase in the next block (12.5%), plus the tip, never exceeding feeCap when one is set
+func dynamicFeeGasPrice(baseFee, tip, feeCap *big.Int) *big.Int {
+	gasPrice := new(big.Int).Add(baseFee, new(big.Int).Div(baseFee, big.NewInt(8)))
+	if tip != nil {
+		gasPrice.Add(gasPrice, tip)
+	}
+
+	if feeCap != nil && gasPrice.Cmp(feeCap) > 0 {
+		gasPrice.Set(feeCap)
+	}
+
+	return gasPrice
+}
+
+// bumpGasPrice raises gasPrice by the given percentage, never exceeding maxGasPrice when one is set
+func bumpGasPrice(gasPrice *big.Int, percent uint64, maxGasPrice *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(100+percent))
+	bumped.Div(bumped, big.NewInt(100))
+
+	if maxGasPrice != nil && bumped.Cmp(maxGasPrice) > 0 {
+		bumped.Set(maxGasPrice)
+	}
+
+	return bumped
+}
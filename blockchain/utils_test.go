@@ -0,0 +1,21 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKeystoreKey(t *testing.T) {
+	privKey, err := loadKeystoreKey("testdata/keystore.json", "testpassphrase")
+	require.NoError(t, err)
+	assert.Equal(t, "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23", crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+
+	_, err = loadKeystoreKey("testdata/keystore.json", "wrong")
+	assert.Error(t, err)
+
+	_, err = loadKeystoreKey("testdata/missing.json", "testpassphrase")
+	assert.Error(t, err)
+}
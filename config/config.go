@@ -3,32 +3,119 @@ package config
 import (
 	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 const (
+	ActOnPendingKey            = "ACT_ON_PENDING"
+	AdminListenKey             = "ADMIN_LISTEN"
+	AgentABIVersionKey         = "AGENT_ABI_VERSION"
 	AgentContractAddressKey    = "AGENT_CONTRACT_ADDRESS"
+	ArchiveLogsKey             = "ARCHIVE_LOGS"
 	AutoSSLDomainKey           = "AUTO_SSL_DOMAIN"
 	AutoSSLCacheDirKey         = "AUTO_SSL_CACHE_DIR"
+	BalanceAlertCooldownKey    = "BALANCE_ALERT_COOLDOWN"
+	BalanceAlertWebhookKey     = "BALANCE_ALERT_WEBHOOK"
+	BatchCompletionSizeKey     = "BATCH_COMPLETION_SIZE"
 	BlockchainEnabledKey       = "BLOCKCHAIN_ENABLED"
+	BlockchainLogFormatKey     = "BLOCKCHAIN_LOG_FORMAT"
+	BlockchainLogLevelKey      = "BLOCKCHAIN_LOG_LEVEL"
+	BlockConfirmationsKey      = "BLOCK_CONFIRMATIONS"
+	BlocksBehindWarningKey     = "BLOCKS_BEHIND_WARNING"
+	BlockchainEventModeKey     = "BLOCKCHAIN_EVENT_MODE"
+	CircuitBreakerCooldownKey  = "CIRCUIT_BREAKER_COOLDOWN"
+	CircuitBreakerThresholdKey = "CIRCUIT_BREAKER_THRESHOLD"
+	ClefAccountKey             = "CLEF_ACCOUNT"
+	ClefEndpointKey            = "CLEF_ENDPOINT"
+	CompletionDelayKey         = "COMPLETION_DELAY"
+	CompletionPolicyKey        = "COMPLETION_POLICY"
+	CompletionQueueSizeKey     = "COMPLETION_QUEUE_SIZE"
+	CompletionTimeoutKey       = "COMPLETION_CONFIRMATION_TIMEOUT"
+	CompletionWorkersKey       = "COMPLETION_WORKERS"
 	ConfigPathKey              = "CONFIG_PATH"
+	ConsumerAllowlistKey       = "CONSUMER_ALLOWLIST"
 	DaemonListeningPortKey     = "DAEMON_LISTENING_PORT"
 	DaemonTypeKey              = "DAEMON_TYPE"
 	DbPathKey                  = "DB_PATH"
+	DeleteConfirmationsKey     = "COMPLETED_JOB_CONFIRMATIONS"
+	DryRunKey                  = "DRY_RUN"
 	EthereumJsonRpcEndpointKey = "ETHEREUM_JSON_RPC_ENDPOINT"
+	EthereumJsonRpcProxyKey    = "ETHEREUM_JSON_RPC_PROXY"
+	EthereumJsonRpcTLSCAKey    = "ETHEREUM_JSON_RPC_TLS_CA"
+	EthereumJsonRpcTLSCertKey  = "ETHEREUM_JSON_RPC_TLS_CERT"
+	EthereumJsonRpcTLSKeyKey   = "ETHEREUM_JSON_RPC_TLS_KEY"
 	ExecutablePathKey          = "EXECUTABLE_PATH"
+	FallbackEndpointsKey       = "ETHEREUM_JSON_RPC_FALLBACKS"
+	FinalityStrategyKey        = "FINALITY_STRATEGY"
+	GasPriceBumpKey            = "GAS_PRICE_BUMP"
+	GasPriceMultiplierKey      = "GAS_PRICE_MULTIPLIER"
+	GasTipCapKey               = "GAS_TIP_CAP"
+	GasFeeCapKey               = "GAS_FEE_CAP"
+	GasLimitCapKey             = "GAS_LIMIT_CAP"
 	HdwalletIndexKey           = "HDWALLET_INDEX"
+	HealthListenKey            = "HEALTH_LISTEN"
+	HealthStalenessKey         = "HEALTH_STALENESS"
+	HistorySkipToBlockKey      = "HISTORY_SKIP_TO_BLOCK"
 	HdwalletMnemonicKey        = "HDWALLET_MNEMONIC"
+	JobCompletionGasBufferKey  = "JOB_COMPLETION_GAS_BUFFER"
+	JobCompletionGasLimitKey   = "JOB_COMPLETION_GAS_LIMIT"
+	JobsGrpcCompleteKey        = "JOBS_GRPC_COMPLETE_ENABLED"
+	JobsGrpcListenKey          = "JOBS_GRPC_LISTEN"
+	JobsListenKey              = "JOBS_LISTEN"
+	JobTTLKey                  = "JOB_TTL"
+	KeystorePassphraseKey      = "KEYSTORE_PASSPHRASE"
+	KeystorePathKey            = "KEYSTORE_PATH"
 	LogLevelKey                = "LOG_LEVEL"
+	LogScanChunkSizeKey        = "LOG_SCAN_CHUNK_SIZE"
+	LogScanMaxSplitsKey        = "LOG_SCAN_MAX_SPLITS"
+	MaxAttemptsKey             = "COMPLETION_MAX_ATTEMPTS"
+	MaxBlocksPerPollKey        = "MAX_BLOCKS_PER_POLL"
+	MaxConfirmationsKey        = "MAX_INFLIGHT_CONFIRMATIONS"
+	MaxGasPriceKey             = "MAX_GAS_PRICE"
+	MaxResubmitsKey            = "COMPLETION_MAX_RESUBMITS"
+	MetricsListenKey           = "METRICS_LISTEN"
+	MinBalanceAlertKey         = "MIN_BALANCE_ALERT"
+	MinJobFundingKey           = "MIN_JOB_FUNDING"
+	MulticallAddressKey        = "MULTICALL_CONTRACT_ADDRESS"
+	NetworkIDKey               = "NETWORK_ID"
+	ObserverModeKey            = "OBSERVER_MODE"
+	OldJobScanIntervalKey      = "OLD_JOB_SCAN_INTERVAL"
 	PassthroughEnabledKey      = "PASSTHROUGH_ENABLED"
 	PassthroughEndpointKey     = "PASSTHROUGH_ENDPOINT"
+	PollBackoffMaxKey          = "POLL_BACKOFF_MAX"
+	PollJitterKey              = "POLL_JITTER"
 	PollSleepKey               = "POLL_SLEEP"
+	PollSleepMaxKey            = "POLL_SLEEP_MAX"
+	PollSleepMinKey            = "POLL_SLEEP_MIN"
 	PrivateKeyKey              = "PRIVATE_KEY"
+	RawBlockLookupKey          = "RAW_BLOCK_LOOKUP"
+	RegistryAddressKey         = "REGISTRY_CONTRACT_ADDRESS"
+	RegistryAgentIDKey         = "REGISTRY_AGENT_ID"
+	RegistryRefreshIntervalKey = "REGISTRY_REFRESH_INTERVAL"
+	RelayerFormatKey           = "RELAYER_FORMAT"
+	RelayerURLKey              = "RELAYER_URL"
+	ReorgRewindDepthKey        = "REORG_REWIND_DEPTH"
+	ResubmitIntervalKey        = "COMPLETION_RESUBMIT_INTERVAL"
+	RetryDelayKey              = "COMPLETION_RETRY_DELAY"
+	RPCFailbackIntervalKey     = "RPC_FAILBACK_INTERVAL"
+	RPCFailoverThresholdKey    = "RPC_FAILOVER_THRESHOLD"
+	RPCRateLimitKey            = "RPC_RATE_LIMIT"
+	RPCTimeoutKey              = "RPC_TIMEOUT"
 	ServiceTypeKey             = "SERVICE_TYPE"
+	SignerTypeKey              = "SIGNER_TYPE"
 	SSLCertPathKey             = "SSL_CERT"
+	StartBlockKey              = "START_BLOCK"
 	SSLKeyPathKey              = "SSL_KEY"
+	SubmissionBackendKey       = "SUBMISSION_BACKEND"
+	SubmitAttemptsKey          = "COMPLETION_SUBMIT_ATTEMPTS"
+	SubmitRetryDelayKey        = "COMPLETION_SUBMIT_RETRY_DELAY"
+	SubmitTimeoutKey           = "COMPLETION_SUBMIT_TIMEOUT"
+	UseRegistryKey             = "USE_REGISTRY"
 	WireEncodingKey            = "WIRE_ENCODING"
 )
 
@@ -40,6 +127,47 @@ func init() {
 	vip.AutomaticEnv()
 
 	vip.SetDefault(LogLevelKey, 5)
+	vip.SetDefault(AgentABIVersionKey, "1")
+	vip.SetDefault(BlockchainLogFormatKey, "text")
+	vip.SetDefault(JobCompletionGasLimitKey, 1000000)
+	vip.SetDefault(JobCompletionGasBufferKey, 20)
+	vip.SetDefault(GasPriceMultiplierKey, 1.0)
+	vip.SetDefault(CompletionTimeoutKey, "5m")
+	vip.SetDefault(CompletionDelayKey, "0")
+	vip.SetDefault(CompletionQueueSizeKey, 1000)
+	vip.SetDefault(CompletionWorkersKey, 1)
+	vip.SetDefault(CompletionPolicyKey, "on_signal")
+	vip.SetDefault(BatchCompletionSizeKey, 1)
+	vip.SetDefault(SubmissionBackendKey, "direct")
+	vip.SetDefault(RelayerFormatKey, "json")
+	vip.SetDefault(ResubmitIntervalKey, "1m")
+	vip.SetDefault(MaxResubmitsKey, 3)
+	vip.SetDefault(MaxAttemptsKey, 5)
+	vip.SetDefault(RetryDelayKey, "30s")
+	vip.SetDefault(SubmitAttemptsKey, 3)
+	vip.SetDefault(SubmitRetryDelayKey, "1s")
+	vip.SetDefault(SubmitTimeoutKey, "30s")
+	vip.SetDefault(MaxConfirmationsKey, 1)
+	vip.SetDefault(GasPriceBumpKey, 10)
+	vip.SetDefault(GasTipCapKey, "1000000000")
+	vip.SetDefault(ReorgRewindDepthKey, 12)
+	vip.SetDefault(BlockConfirmationsKey, 1)
+	vip.SetDefault(FinalityStrategyKey, "confirmations")
+	vip.SetDefault(BlocksBehindWarningKey, 1000)
+	vip.SetDefault(LogScanChunkSizeKey, 5000)
+	vip.SetDefault(LogScanMaxSplitsKey, 10)
+	vip.SetDefault(JobTTLKey, "0")
+	vip.SetDefault(OldJobScanIntervalKey, "5m")
+	vip.SetDefault(BalanceAlertCooldownKey, "1h")
+	vip.SetDefault(BlockchainEventModeKey, "poll")
+	vip.SetDefault(SignerTypeKey, "key")
+	vip.SetDefault(HealthStalenessKey, "1m")
+	vip.SetDefault(PollBackoffMaxKey, "5m")
+	vip.SetDefault(CircuitBreakerCooldownKey, "1m")
+	vip.SetDefault(RPCTimeoutKey, "30s")
+	vip.SetDefault(RPCFailoverThresholdKey, 3)
+	vip.SetDefault(RPCFailbackIntervalKey, "5m")
+	vip.SetDefault(RegistryRefreshIntervalKey, "10m")
 
 	vip.AddConfigPath(".")
 }
@@ -74,8 +202,310 @@ func Validate() error {
 	}
 
 	if vip.GetBool(BlockchainEnabledKey) {
-		if vip.GetString(PrivateKeyKey) == "" && vip.GetString(HdwalletMnemonicKey) == "" {
-			return errors.New("either PRIVATE_KEY or HDWALLET_MNEMONIC are required")
+		// An observer never submits completions, so it needs no identity
+		if !vip.GetBool(ObserverModeKey) {
+			switch signerType := vip.GetString(SignerTypeKey); signerType {
+			case "key":
+				if vip.GetString(KeystorePathKey) == "" && vip.GetString(PrivateKeyKey) == "" &&
+					vip.GetString(HdwalletMnemonicKey) == "" {
+					return errors.New("one of KEYSTORE_PATH, PRIVATE_KEY or HDWALLET_MNEMONIC is required")
+				}
+			case "clef":
+				if vip.GetString(ClefEndpointKey) == "" || vip.GetString(ClefAccountKey) == "" {
+					return errors.New("CLEF_ENDPOINT and CLEF_ACCOUNT are required with SIGNER_TYPE 'clef'")
+				}
+			default:
+				return fmt.Errorf("unrecognized SIGNER_TYPE '%+v'", signerType)
+			}
+		}
+
+		if vip.GetBool(UseRegistryKey) {
+			if vip.GetString(RegistryAddressKey) == "" || vip.GetString(RegistryAgentIDKey) == "" {
+				return errors.New("REGISTRY_CONTRACT_ADDRESS and REGISTRY_AGENT_ID are required with USE_REGISTRY")
+			}
+			if len(vip.GetString(RegistryAgentIDKey)) > 32 {
+				return errors.New("REGISTRY_AGENT_ID must be at most 32 bytes")
+			}
+			if vip.GetDuration(RegistryRefreshIntervalKey) < 0 {
+				return errors.New("REGISTRY_REFRESH_INTERVAL must be non-negative")
+			}
+		} else if len(GetStringSlice(AgentContractAddressKey)) == 0 {
+			return errors.New("at least one AGENT_CONTRACT_ADDRESS is required")
+		}
+
+		if vip.GetDuration(PollSleepKey) <= 0 {
+			return errors.New("POLL_SLEEP must be positive")
+		}
+
+		if vip.GetDuration(PollBackoffMaxKey) < vip.GetDuration(PollSleepKey) {
+			return errors.New("POLL_BACKOFF_MAX must not be less than POLL_SLEEP")
+		}
+
+		if min := vip.GetDuration(PollSleepMinKey); min < 0 || min > vip.GetDuration(PollSleepKey) {
+			return errors.New("POLL_SLEEP_MIN must be non-negative and not more than POLL_SLEEP")
+		}
+
+		if max := vip.GetDuration(PollSleepMaxKey); max != 0 && max < vip.GetDuration(PollSleepKey) {
+			return errors.New("POLL_SLEEP_MAX must not be less than POLL_SLEEP")
+		}
+
+		if jitter := vip.GetFloat64(PollJitterKey); jitter < 0 || jitter >= 1 {
+			return errors.New("POLL_JITTER must be at least 0 and less than 1")
+		}
+
+		if vip.GetDuration(RPCTimeoutKey) <= 0 {
+			return errors.New("RPC_TIMEOUT must be positive")
+		}
+
+		if vip.GetInt(CircuitBreakerThresholdKey) < 0 {
+			return errors.New("CIRCUIT_BREAKER_THRESHOLD must be non-negative")
+		}
+
+		if vip.GetDuration(CircuitBreakerCooldownKey) <= 0 {
+			return errors.New("CIRCUIT_BREAKER_COOLDOWN must be positive")
+		}
+
+		for _, fallback := range GetStringSlice(FallbackEndpointsKey) {
+			if !IsHTTPEndpoint(fallback) && !IsWebSocketEndpoint(fallback) {
+				return fmt.Errorf("unable to parse ETHEREUM_JSON_RPC_FALLBACKS endpoint '%+v'", fallback)
+			}
+		}
+
+		if vip.GetInt(RPCFailoverThresholdKey) < 1 {
+			return errors.New("RPC_FAILOVER_THRESHOLD must be at least 1")
+		}
+
+		if vip.GetDuration(RPCFailbackIntervalKey) < 0 {
+			return errors.New("RPC_FAILBACK_INTERVAL must be non-negative")
+		}
+
+		if vip.GetFloat64(RPCRateLimitKey) < 0 {
+			return errors.New("RPC_RATE_LIMIT must be non-negative")
+		}
+
+		switch format := vip.GetString(BlockchainLogFormatKey); format {
+		case "text":
+		case "json":
+		default:
+			return fmt.Errorf("unrecognized BLOCKCHAIN_LOG_FORMAT '%+v'", format)
+		}
+
+		if vip.GetInt(JobCompletionGasLimitKey) < 0 {
+			return errors.New("JOB_COMPLETION_GAS_LIMIT must be non-negative")
+		}
+
+		if vip.GetInt(JobCompletionGasBufferKey) < 0 {
+			return errors.New("JOB_COMPLETION_GAS_BUFFER must be non-negative")
+		}
+
+		if gasLimitCap := vip.GetInt(GasLimitCapKey); gasLimitCap < 0 {
+			return errors.New("GAS_LIMIT_CAP must be non-negative")
+		} else if gasLimitCap > 0 && vip.GetInt(JobCompletionGasLimitKey) > gasLimitCap {
+			return errors.New("JOB_COMPLETION_GAS_LIMIT must not exceed GAS_LIMIT_CAP")
+		}
+
+		if vip.GetFloat64(GasPriceMultiplierKey) <= 0 {
+			return errors.New("GAS_PRICE_MULTIPLIER must be positive")
+		}
+
+		if vip.GetDuration(CompletionTimeoutKey) <= 0 {
+			return errors.New("COMPLETION_CONFIRMATION_TIMEOUT must be positive")
+		}
+
+		if vip.GetInt(CompletionQueueSizeKey) < 1 {
+			return errors.New("COMPLETION_QUEUE_SIZE must be at least 1")
+		}
+
+		if vip.GetInt(CompletionWorkersKey) < 1 {
+			return errors.New("COMPLETION_WORKERS must be at least 1")
+		}
+
+		switch policy := vip.GetString(CompletionPolicyKey); policy {
+		case "on_funded", "on_signal", "manual":
+		default:
+			return fmt.Errorf("unrecognized COMPLETION_POLICY '%+v'", policy)
+		}
+
+		if batchSize := vip.GetInt(BatchCompletionSizeKey); batchSize < 1 {
+			return errors.New("BATCH_COMPLETION_SIZE must be at least 1")
+		} else if batchSize > 1 && vip.GetString(MulticallAddressKey) == "" {
+			return errors.New("MULTICALL_CONTRACT_ADDRESS is required when BATCH_COMPLETION_SIZE is more than 1")
+		}
+
+		switch backend := vip.GetString(SubmissionBackendKey); backend {
+		case "direct":
+		case "relayer":
+			relayerURL := vip.GetString(RelayerURLKey)
+			if u, err := url.Parse(relayerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+				u.Host == "" {
+				return fmt.Errorf("unable to parse RELAYER_URL '%+v'", relayerURL)
+			}
+			if format := vip.GetString(RelayerFormatKey); format != "json" && format != "defender" {
+				return fmt.Errorf("unrecognized RELAYER_FORMAT '%+v'", format)
+			}
+			// A batch is a single Multicall transaction sent from the daemon's own account
+			if vip.GetInt(BatchCompletionSizeKey) > 1 {
+				return errors.New("BATCH_COMPLETION_SIZE must be 1 with SUBMISSION_BACKEND 'relayer'")
+			}
+		default:
+			return fmt.Errorf("unrecognized SUBMISSION_BACKEND '%+v'", backend)
+		}
+
+		if vip.GetInt(MaxAttemptsKey) < 1 {
+			return errors.New("COMPLETION_MAX_ATTEMPTS must be at least 1")
+		}
+
+		if vip.GetDuration(RetryDelayKey) <= 0 {
+			return errors.New("COMPLETION_RETRY_DELAY must be positive")
+		}
+
+		if vip.GetInt(SubmitAttemptsKey) < 1 {
+			return errors.New("COMPLETION_SUBMIT_ATTEMPTS must be at least 1")
+		}
+
+		if vip.GetDuration(SubmitRetryDelayKey) <= 0 {
+			return errors.New("COMPLETION_SUBMIT_RETRY_DELAY must be positive")
+		}
+
+		if vip.GetDuration(SubmitTimeoutKey) <= 0 {
+			return errors.New("COMPLETION_SUBMIT_TIMEOUT must be positive")
+		}
+
+		if vip.GetInt(MaxConfirmationsKey) < 1 {
+			return errors.New("MAX_INFLIGHT_CONFIRMATIONS must be at least 1")
+		}
+
+		// Nodes reject replacement transactions that don't raise the gas price by at least 10%
+		if vip.GetInt(MaxResubmitsKey) > 0 && vip.GetInt(GasPriceBumpKey) < 10 {
+			return errors.New("GAS_PRICE_BUMP must be at least 10 when resubmission is enabled")
+		}
+
+		if vip.GetInt(BlocksBehindWarningKey) < 0 {
+			return errors.New("BLOCKS_BEHIND_WARNING must be non-negative")
+		}
+
+		if vip.GetInt(BlockConfirmationsKey) < 0 {
+			return errors.New("BLOCK_CONFIRMATIONS must be non-negative")
+		}
+
+		if vip.GetInt(DeleteConfirmationsKey) < 0 {
+			return errors.New("COMPLETED_JOB_CONFIRMATIONS must be non-negative")
+		}
+
+		switch strategy := vip.GetString(FinalityStrategyKey); strategy {
+		case "confirmations":
+		case "finalized_tag":
+		default:
+			return fmt.Errorf("unrecognized FINALITY_STRATEGY '%+v'", strategy)
+		}
+
+		switch mode := vip.GetString(BlockchainEventModeKey); mode {
+		case "poll":
+		case "auto":
+		case "subscribe":
+			if !IsWebSocketEndpoint(vip.GetString(EthereumJsonRpcEndpointKey)) {
+				return errors.New("BLOCKCHAIN_EVENT_MODE 'subscribe' requires a ws:// or wss:// ETHEREUM_JSON_RPC_ENDPOINT")
+			}
+			// Streamed events are applied as they arrive, so they would bypass the confirmation depth
+			if vip.GetInt(BlockConfirmationsKey) > 1 || vip.GetString(FinalityStrategyKey) == "finalized_tag" {
+				return errors.New("BLOCKCHAIN_EVENT_MODE 'subscribe' applies events from the chain head; it can't be " +
+					"combined with BLOCK_CONFIRMATIONS above 1 or FINALITY_STRATEGY 'finalized_tag'")
+			}
+		default:
+			return fmt.Errorf("unrecognized BLOCKCHAIN_EVENT_MODE '%+v'", mode)
+		}
+
+		if proxy := vip.GetString(EthereumJsonRpcProxyKey); proxy != "" {
+			if !IsHTTPEndpoint(vip.GetString(EthereumJsonRpcEndpointKey)) {
+				return errors.New("ETHEREUM_JSON_RPC_PROXY requires an http:// or https:// ETHEREUM_JSON_RPC_ENDPOINT")
+			}
+			if u, err := url.Parse(proxy); err != nil || (u.Scheme != "http" && u.Scheme != "https" &&
+				u.Scheme != "socks5") || u.Host == "" {
+				return fmt.Errorf("unable to parse ETHEREUM_JSON_RPC_PROXY '%+v'", proxy)
+			}
+		}
+
+		tlsCert, tlsKey := vip.GetString(EthereumJsonRpcTLSCertKey), vip.GetString(EthereumJsonRpcTLSKeyKey)
+		if (tlsCert != "") != (tlsKey != "") {
+			return errors.New("ETHEREUM_JSON_RPC_TLS_CERT and ETHEREUM_JSON_RPC_TLS_KEY must be set together")
+		}
+		if tlsCert != "" || vip.GetString(EthereumJsonRpcTLSCAKey) != "" {
+			if !strings.HasPrefix(vip.GetString(EthereumJsonRpcEndpointKey), "https://") {
+				return errors.New("ETHEREUM_JSON_RPC_TLS settings require an https:// ETHEREUM_JSON_RPC_ENDPOINT")
+			}
+		}
+
+		if startBlock := vip.GetString(StartBlockKey); startBlock != "" {
+			if b, ok := new(big.Int).SetString(startBlock, 10); !ok || b.Sign() < 0 {
+				return fmt.Errorf("unable to parse START_BLOCK '%+v'", startBlock)
+			}
+		}
+
+		if skipTo := vip.GetString(HistorySkipToBlockKey); skipTo != "" {
+			if b, ok := new(big.Int).SetString(skipTo, 10); !ok || b.Sign() < 0 {
+				return fmt.Errorf("unable to parse HISTORY_SKIP_TO_BLOCK '%+v'", skipTo)
+			}
+		}
+
+		if networkID := vip.GetString(NetworkIDKey); networkID != "" {
+			if n, ok := new(big.Int).SetString(networkID, 10); !ok || n.Sign() <= 0 {
+				return fmt.Errorf("unable to parse NETWORK_ID '%+v'", networkID)
+			}
+		}
+
+		if vip.GetDuration(JobTTLKey) < 0 {
+			return errors.New("JOB_TTL must be non-negative")
+		}
+
+		if vip.GetDuration(OldJobScanIntervalKey) < 0 {
+			return errors.New("OLD_JOB_SCAN_INTERVAL must be non-negative")
+		}
+
+		if vip.GetDuration(CompletionDelayKey) < 0 {
+			return errors.New("COMPLETION_DELAY must be non-negative")
+		}
+
+		if webhook := vip.GetString(BalanceAlertWebhookKey); webhook != "" {
+			if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("unable to parse BALANCE_ALERT_WEBHOOK '%+v'", webhook)
+			}
+			if vip.GetString(MinBalanceAlertKey) == "" {
+				return errors.New("BALANCE_ALERT_WEBHOOK requires MIN_BALANCE_ALERT")
+			}
+		}
+
+		if vip.GetDuration(BalanceAlertCooldownKey) <= 0 {
+			return errors.New("BALANCE_ALERT_COOLDOWN must be positive")
+		}
+
+		if vip.GetInt(LogScanChunkSizeKey) < 1 {
+			return errors.New("LOG_SCAN_CHUNK_SIZE must be at least 1")
+		}
+
+		if vip.GetInt(LogScanMaxSplitsKey) < 0 {
+			return errors.New("LOG_SCAN_MAX_SPLITS must be non-negative")
+		}
+
+		if vip.GetInt(MaxBlocksPerPollKey) < 0 {
+			return errors.New("MAX_BLOCKS_PER_POLL must be non-negative")
+		}
+
+		if vip.GetInt(ReorgRewindDepthKey) < 1 {
+			return errors.New("REORG_REWIND_DEPTH must be at least 1")
+		}
+
+		if maxGasPrice := vip.GetString(MaxGasPriceKey); maxGasPrice != "" {
+			if _, ok := new(big.Int).SetString(maxGasPrice, 10); !ok {
+				return fmt.Errorf("unable to parse MAX_GAS_PRICE '%+v'", maxGasPrice)
+			}
+		}
+
+		for _, key := range []string{GasTipCapKey, GasFeeCapKey, MinBalanceAlertKey, MinJobFundingKey} {
+			if wei := vip.GetString(key); wei != "" {
+				if w, ok := new(big.Int).SetString(wei, 10); !ok || w.Sign() < 0 {
+					return fmt.Errorf("unable to parse %v '%+v'", key, wei)
+				}
+			}
 		}
 	}
 
@@ -87,6 +517,24 @@ func Validate() error {
 	return nil
 }
 
+// Reload re-reads the config file and validates the result. Values already read, e.g. by the blockchain processor at
+// startup, are unaffected until their reader picks them up again.
+func Reload() error {
+	if err := vip.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading config: %v", err)
+	}
+	return Validate()
+}
+
+// IsWebSocketEndpoint reports whether the given JSON-RPC endpoint is a WebSocket URL
+func IsWebSocketEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://")
+}
+
+func IsHTTPEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")
+}
+
 func GetString(key string) string {
 	return vip.GetString(key)
 }
@@ -99,6 +547,27 @@ func GetDuration(key string) time.Duration {
 	return vip.GetDuration(key)
 }
 
+func GetFloat64(key string) float64 {
+	return vip.GetFloat64(key)
+}
+
 func GetBool(key string) bool {
 	return vip.GetBool(key)
 }
+
+// GetStringSlice returns a list given either as an array in the config file or as a comma-separated string, e.g. from
+// an environment variable
+func GetStringSlice(key string) []string {
+	values := vip.GetStringSlice(key)
+	if s, ok := vip.Get(key).(string); ok {
+		values = strings.Split(s, ",")
+	}
+
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
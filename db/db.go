@@ -1,6 +1,10 @@
 package db
 
 import (
+	"encoding/json"
+	"math/big"
+	"time"
+
 	"github.com/coreos/bbolt"
 	"github.com/pkg/errors"
 )
@@ -11,14 +15,69 @@ type Job struct {
 	JobState     string
 	Consumer     []byte
 	Completed    bool
+	// AgentAddress is the agent contract that emitted the job's events
+	AgentAddress []byte
+	// FundedAmount is the amount the job was funded with, in wei, if its JobFunded event carries it
+	FundedAmount *big.Int
+	// CompletionAttempts counts the job completions that failed to send
+	CompletionAttempts int
+	// CompletionTxHash is the last transaction sent to complete the job, kept until its JobCompleted event is seen
+	CompletionTxHash []byte
+	// CompletionMinedAtBlock is the block a successful completion transaction was mined in while the job waits for
+	// its JobCompleted event; nil until the transaction's receipt is seen
+	CompletionMinedAtBlock *uint64
+	// CompletedAtBlock is the block of the job's JobCompleted event while the job waits for it to be confirmed before
+	// being deleted; nil until the event is seen
+	CompletedAtBlock *uint64
+	// DryRun marks a job whose completion transaction a dry run has logged instead of sending
+	DryRun bool
+	// CreatedAt and UpdatedAt record when the job was first stored and last written
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// FundedAt records when the job's JobFunded event was first handled; zero for a job not seen funded, or funded
+	// before it was recorded
+	FundedAt time.Time
+}
+
+// Touch marks the job as written at now, and as created at now if it is new
+func (job *Job) Touch(now time.Time) {
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+}
+
+// OutboxEntry is a job completion that has been queued but not yet resolved, keyed by job address in
+// OutboxBucketName
+type OutboxEntry struct {
+	JobAddress   []byte
+	JobSignature []byte
+	AgentAddress []byte
 }
 
 var (
-	JobBucketName   = []byte("job")
-	ChainBucketName = []byte("chain")
+	// JobBucketName holds jobs keyed by job address. A job is only ever changed by reading, modifying and writing it
+	// back within one db.Update transaction; Bolt runs one such transaction at a time, so concurrent changes to the
+	// same job are applied in turn rather than one overwriting the other. Reading a job in one transaction and
+	// writing it back in another breaks this.
+	JobBucketName    = []byte("job")
+	ChainBucketName  = []byte("chain")
+	OutboxBucketName = []byte("outbox")
+	// LogArchiveBucketName holds the raw job logs applied to JobBucketName, as JSON keyed by block number and log
+	// index, when the archive is enabled
+	LogArchiveBucketName = []byte("logArchive")
+	// HistoryBucketName holds a bucket per job address of the job's HistoryEntry records, keyed by sequence number
+	HistoryBucketName = []byte("history")
+
+	// LastBlockKey and LastBlockHashKey hold the number and hash of the last block processed for events in
+	// ChainBucketName
+	LastBlockKey     = []byte("lastBlock")
+	LastBlockHashKey = []byte("lastBlockHash")
+	// SchemaVersionKey holds the schema version of the db in ChainBucketName
+	SchemaVersionKey = []byte("schemaVersion")
 )
 
-// Connect initializes a connection to the given BoltDB
+// Connect initializes a connection to the given BoltDB, migrating it to SchemaVersion if it is behind
 func Connect(path string) (*bolt.DB, error) {
 	db, err := bolt.Open(path, 0644, nil)
 	if err != nil {
@@ -29,11 +88,90 @@ func Connect(path string) (*bolt.DB, error) {
 		if _, err = tx.CreateBucketIfNotExists(JobBucketName); err != nil {
 			return err
 		}
+		if _, err = tx.CreateBucketIfNotExists(OutboxBucketName); err != nil {
+			return err
+		}
+		if _, err = tx.CreateBucketIfNotExists(LogArchiveBucketName); err != nil {
+			return err
+		}
+		if _, err = tx.CreateBucketIfNotExists(HistoryBucketName); err != nil {
+			return err
+		}
 		_, err = tx.CreateBucketIfNotExists(ChainBucketName)
 		return err
 	}); err != nil {
 		return nil, errors.Wrap(err, "error initializing db")
 	}
 
+	if err = migrate(db, path); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// GetJob returns the job stored for jobAddress, or nil if there is none
+func GetJob(db *bolt.DB, jobAddress []byte) (*Job, error) {
+	var job *Job
+
+	err := db.View(func(tx *bolt.Tx) error {
+		jobBytes := tx.Bucket(JobBucketName).Get(jobAddress)
+		if jobBytes == nil {
+			return nil
+		}
+
+		job = &Job{}
+		return errors.Wrap(json.Unmarshal(jobBytes, job), "error unmarshaling job")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ListJobs returns all stored jobs in the given state, or all stored jobs if state is empty. Records that fail to
+// deserialize are skipped.
+func ListJobs(db *bolt.DB, state string) ([]Job, error) {
+	jobs := []Job{}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(JobBucketName).ForEach(func(k, v []byte) error {
+			job := Job{}
+			if err := json.Unmarshal(v, &job); err != nil {
+				return nil
+			}
+			if state == "" || job.JobState == state {
+				jobs = append(jobs, job)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing jobs")
+	}
+
+	return jobs, nil
+}
+
+// ListOutbox returns all queued job completions. Records that fail to deserialize are skipped.
+func ListOutbox(db *bolt.DB) ([]OutboxEntry, error) {
+	entries := []OutboxEntry{}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(OutboxBucketName).ForEach(func(k, v []byte) error {
+			entry := OutboxEntry{}
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return nil
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing outbox")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) (*bolt.DB, func()) {
+	dir, err := ioutil.TempDir("", "snetd-db")
+	require.NoError(t, err)
+
+	db, err := Connect(filepath.Join(dir, "snetd.db"))
+	require.NoError(t, err)
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func putJobs(t *testing.T, db *bolt.DB, jobs ...Job) {
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		for _, job := range jobs {
+			jobBytes, err := json.Marshal(job)
+			require.NoError(t, err)
+			if err = tx.Bucket(JobBucketName).Put(job.JobAddress, jobBytes); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func TestGetJob(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	job := Job{JobAddress: []byte{1}, JobState: "FUNDED", Consumer: []byte{2}}
+	putJobs(t, db, job)
+
+	got, err := GetJob(db, []byte{1})
+	require.NoError(t, err)
+	assert.Equal(t, &job, got)
+
+	got, err = GetJob(db, []byte{3})
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestListJobs(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	pending := Job{JobAddress: []byte{1}, JobState: "PENDING"}
+	funded := Job{JobAddress: []byte{2}, JobState: "FUNDED"}
+	putJobs(t, db, pending, funded)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(JobBucketName).Put([]byte{3}, []byte("{not json"))
+	}))
+
+	jobs, err := ListJobs(db, "")
+	require.NoError(t, err)
+	assert.Equal(t, []Job{pending, funded}, jobs)
+
+	jobs, err = ListJobs(db, "FUNDED")
+	require.NoError(t, err)
+	assert.Equal(t, []Job{funded}, jobs)
+
+	jobs, err = ListJobs(db, "COMPLETED")
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestJobTimestampsRoundTrip(t *testing.T) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	job := Job{JobAddress: []byte{1}, JobState: "PENDING"}
+	job.Touch(created)
+	job.Touch(created.Add(time.Hour))
+
+	jobBytes, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	decoded := Job{}
+	require.NoError(t, json.Unmarshal(jobBytes, &decoded))
+	assert.True(t, created.Equal(decoded.CreatedAt))
+	assert.True(t, created.Add(time.Hour).Equal(decoded.UpdatedAt))
+}
+
+func TestJobWithoutTimestamps(t *testing.T) {
+	// A record written before the timestamp fields existed
+	legacy := []byte(`{"JobAddress":"AQ==","JobSignature":null,"JobState":"FUNDED","Consumer":"Ag==","Completed":false}`)
+
+	job := Job{}
+	require.NoError(t, json.Unmarshal(legacy, &job))
+	assert.Equal(t, "FUNDED", job.JobState)
+	assert.True(t, job.CreatedAt.IsZero())
+	assert.True(t, job.UpdatedAt.IsZero())
+}
+
+func TestListOutbox(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	entry := OutboxEntry{JobAddress: []byte{1}, JobSignature: []byte{2}, AgentAddress: []byte{3}}
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		entryBytes, err := json.Marshal(entry)
+		require.NoError(t, err)
+		if err = tx.Bucket(OutboxBucketName).Put(entry.JobAddress, entryBytes); err != nil {
+			return err
+		}
+		return tx.Bucket(OutboxBucketName).Put([]byte{4}, []byte("corrupt"))
+	}))
+
+	entries, err := ListOutbox(db)
+	require.NoError(t, err)
+	assert.Equal(t, []OutboxEntry{entry}, entries)
+}
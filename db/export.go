@@ -0,0 +1,95 @@
+package db
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/coreos/bbolt"
+	"github.com/pkg/errors"
+)
+
+// ExportVersion is the version of the stream written by ExportJobs. It is bumped whenever the record format or the
+// meaning of the exported buckets changes, so an import can refuse a stream it would misread.
+const ExportVersion = 1
+
+// exportedBuckets are the buckets carried by an export: the jobs and the chain state, including the event cursor
+var exportedBuckets = [][]byte{JobBucketName, ChainBucketName}
+
+// exportHeader is the first line of an export stream
+type exportHeader struct {
+	Version int `json:"version"`
+}
+
+// exportRecord is a single key and value of an exported bucket, both base64 encoded as they're stored
+type exportRecord struct {
+	Bucket string `json:"bucket"`
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+}
+
+// ExportJobs writes the jobs and chain state in db to w as newline-delimited JSON, a version header followed by one
+// record per key, e.g. to move a daemon's state to another host with ImportJobs. The export is a consistent snapshot
+// of the db.
+func ExportJobs(db *bolt.DB, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+
+	if err := encoder.Encode(exportHeader{Version: ExportVersion}); err != nil {
+		return errors.Wrap(err, "error writing export header")
+	}
+
+	return db.View(func(tx *bolt.Tx) error {
+		for _, name := range exportedBuckets {
+			if err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				return encoder.Encode(exportRecord{Bucket: string(name), Key: k, Value: v})
+			}); err != nil {
+				return errors.Wrapf(err, "error exporting %v bucket", string(name))
+			}
+		}
+		return nil
+	})
+}
+
+// ImportJobs restores the records of a stream written by ExportJobs to db, replacing any already stored under the
+// same key. The stream is imported in a single transaction, so a malformed or unsupported one leaves db unchanged.
+func ImportJobs(db *bolt.DB, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+
+	header := exportHeader{}
+	if err := decoder.Decode(&header); err != nil {
+		return errors.Wrap(err, "error reading export header")
+	}
+	if header.Version != ExportVersion {
+		return errors.Errorf("unsupported export version %v; expected %v", header.Version, ExportVersion)
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		buckets := make(map[string]*bolt.Bucket)
+		for _, name := range exportedBuckets {
+			bucket, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return errors.Wrapf(err, "error creating %v bucket", string(name))
+			}
+			buckets[string(name)] = bucket
+		}
+
+		for line := 2; ; line++ {
+			record := exportRecord{}
+			if err := decoder.Decode(&record); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return errors.Wrapf(err, "error reading export record on line %v", line)
+			}
+
+			bucket, ok := buckets[record.Bucket]
+			if !ok {
+				return errors.Errorf("unrecognized bucket '%v' on line %v", record.Bucket, line)
+			}
+			if len(record.Key) == 0 {
+				return errors.Errorf("record without a key on line %v", line)
+			}
+			if err := bucket.Put(record.Key, record.Value); err != nil {
+				return errors.Wrapf(err, "error importing record on line %v", line)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportJobs(t *testing.T) {
+	source, cleanupSource := newTestDB(t)
+	defer cleanupSource()
+
+	now := time.Now().UTC().Round(time.Second)
+	jobs := []Job{
+		{JobAddress: []byte{1}, JobState: "PENDING", Consumer: []byte{2}, CreatedAt: now, UpdatedAt: now},
+		{JobAddress: []byte{3}, JobState: "FUNDED", Consumer: []byte{4}, JobSignature: []byte{5}, Completed: true,
+			AgentAddress: []byte{6}, CompletionAttempts: 2},
+	}
+	putJobs(t, source, jobs...)
+	require.NoError(t, source.Update(func(tx *bolt.Tx) error {
+		chain := tx.Bucket(ChainBucketName)
+		if err := chain.Put(LastBlockKey, []byte{0x01, 0x00}); err != nil {
+			return err
+		}
+		if err := chain.Put(LastBlockHashKey, bytes.Repeat([]byte{0xab}, 32)); err != nil {
+			return err
+		}
+		return tx.Bucket(OutboxBucketName).Put([]byte{1}, []byte(`{}`))
+	}))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, ExportJobs(source, buf))
+	assert.True(t, strings.HasPrefix(buf.String(), `{"version":1}`+"\n"))
+	// The chain records are the cursor, its hash and the schema version
+	assert.Equal(t, 1+len(jobs)+3, strings.Count(buf.String(), "\n"))
+
+	target, cleanupTarget := newTestDB(t)
+	defer cleanupTarget()
+	require.NoError(t, ImportJobs(target, buf))
+
+	imported, err := ListJobs(target, "")
+	require.NoError(t, err)
+	assert.Equal(t, jobs, imported)
+
+	require.NoError(t, target.View(func(tx *bolt.Tx) error {
+		chain := tx.Bucket(ChainBucketName)
+		assert.Equal(t, []byte{0x01, 0x00}, chain.Get(LastBlockKey))
+		assert.Equal(t, bytes.Repeat([]byte{0xab}, 32), chain.Get(LastBlockHashKey))
+		assert.NotNil(t, chain.Get(SchemaVersionKey))
+		return nil
+	}))
+
+	// Queued completions aren't part of the export
+	outbox, err := ListOutbox(target)
+	require.NoError(t, err)
+	assert.Empty(t, outbox)
+}
+
+func TestImportJobsRejectsInvalidStream(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		stream string
+	}{
+		{name: "empty", stream: ""},
+		{name: "unsupported version", stream: `{"version":2}` + "\n" + `{"bucket":"job","key":"AQ==","value":"e30="}`},
+		{name: "missing header", stream: `{"bucket":"job","key":"AQ==","value":"e30="}`},
+		{name: "unknown bucket", stream: `{"version":1}` + "\n" + `{"bucket":"job","key":"AQ==","value":"e30="}` + "\n" +
+			`{"bucket":"other","key":"AQ==","value":"e30="}`},
+		{name: "malformed record", stream: `{"version":1}` + "\n" + `{"bucket":"job","key":"AQ==","value":"e30="}` +
+			"\n" + `{"bucket":`},
+		{name: "missing key", stream: `{"version":1}` + "\n" + `{"bucket":"job","value":"e30="}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, cleanup := newTestDB(t)
+			defer cleanup()
+
+			assert.Error(t, ImportJobs(db, strings.NewReader(tt.stream)))
+
+			// Nothing from a rejected stream is kept
+			jobs, err := ListJobs(db, "")
+			require.NoError(t, err)
+			assert.Empty(t, jobs)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/pkg/errors"
+)
+
+// HistoryEntry is a change in a job's state, or a transaction sent for it without one, in the job's history in
+// HistoryBucketName. Hash chains the entry to those before it: it is the SHA-256 of the previous entry's hash followed
+// by this entry serialized without its hash, so an entry altered, removed or reordered after it was written no longer
+// matches the hashes of the entries after it.
+type HistoryEntry struct {
+	Timestamp time.Time
+	FromState string
+	ToState   string
+	TxHash    []byte
+	Hash      []byte
+}
+
+// hashEntry returns the hash chaining entry to the entry before it, whose hash is prevHash
+func hashEntry(entry HistoryEntry, prevHash []byte) ([]byte, error) {
+	entry.Hash = nil
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling job history entry")
+	}
+	hash := sha256.Sum256(append(append([]byte(nil), prevHash...), entryBytes...))
+	return hash[:], nil
+}
+
+// AppendJobHistory appends entry to the history of the job at jobAddress within tx, chaining it to the last entry.
+// The history is only ever appended to, and is kept after a completed job is deleted from JobBucketName; only
+// DeleteJobHistory removes it.
+func AppendJobHistory(tx *bolt.Tx, jobAddress []byte, entry HistoryEntry) error {
+	bucket, err := tx.Bucket(HistoryBucketName).CreateBucketIfNotExists(jobAddress)
+	if err != nil {
+		return errors.Wrap(err, "error creating job history bucket")
+	}
+
+	var prevHash []byte
+	if k, v := bucket.Cursor().Last(); k != nil {
+		prev := HistoryEntry{}
+		if err := json.Unmarshal(v, &prev); err != nil {
+			return errors.Wrap(err, "error unmarshaling job history entry")
+		}
+		prevHash = prev.Hash
+	}
+
+	// Stored in UTC so the entry hashes the same once read back, whatever the local zone
+	entry.Timestamp = entry.Timestamp.UTC()
+	if entry.Hash, err = hashEntry(entry, prevHash); err != nil {
+		return err
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling job history entry")
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return errors.Wrap(err, "error allocating job history key")
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return errors.Wrap(bucket.Put(key, entryBytes), "error putting job history entry to db")
+}
+
+// AppendJobTransition is AppendJobHistory for a transition caused by an event in the transaction entry.TxHash. A
+// history that already records the same transition from the same transaction, as one does once the event is
+// re-scanned, is left as it is.
+func AppendJobTransition(tx *bolt.Tx, jobAddress []byte, entry HistoryEntry) error {
+	if bucket := tx.Bucket(HistoryBucketName).Bucket(jobAddress); bucket != nil {
+		recorded := false
+		if err := bucket.ForEach(func(k, v []byte) error {
+			prev := HistoryEntry{}
+			if err := json.Unmarshal(v, &prev); err != nil {
+				return errors.Wrap(err, "error unmarshaling job history entry")
+			}
+			recorded = recorded || prev.FromState == entry.FromState && prev.ToState == entry.ToState &&
+				bytes.Equal(prev.TxHash, entry.TxHash)
+			return nil
+		}); err != nil || recorded {
+			return err
+		}
+	}
+	return AppendJobHistory(tx, jobAddress, entry)
+}
+
+// DeleteJobHistory removes the history of the job at jobAddress within tx, if it has one
+func DeleteJobHistory(tx *bolt.Tx, jobAddress []byte) error {
+	err := tx.Bucket(HistoryBucketName).DeleteBucket(jobAddress)
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return errors.Wrap(err, "error deleting job history")
+}
+
+// GetJobHistory returns the history of the job at jobAddress, oldest entry first; a job with no history has none.
+// A history whose hashes don't chain is an error, as it has been altered since it was written.
+func GetJobHistory(db *bolt.DB, jobAddress []byte) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(HistoryBucketName).Bucket(jobAddress)
+		if bucket == nil {
+			return nil
+		}
+
+		var prevHash []byte
+		return bucket.ForEach(func(k, v []byte) error {
+			entry := HistoryEntry{}
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return errors.Wrap(err, "error unmarshaling job history entry")
+			}
+			hash, err := hashEntry(entry, prevHash)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(hash, entry.Hash) {
+				return errors.Errorf("job history entry %v doesn't match its hash; the history has been altered",
+					len(entries)+1)
+			}
+			entries = append(entries, entry)
+			prevHash = entry.Hash
+			return nil
+		})
+	})
+
+	return entries, errors.Wrap(err, "error reading job history")
+}
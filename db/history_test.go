@@ -0,0 +1,77 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobHistory(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// Entries keep their order across transactions and timezones
+	now := time.Now().In(time.FixedZone("test", 2*60*60))
+	appended := []HistoryEntry{
+		{Timestamp: now, ToState: "PENDING", TxHash: []byte{1}},
+		{Timestamp: now.Add(time.Second), FromState: "PENDING", ToState: "FUNDED", TxHash: []byte{2}},
+		{Timestamp: now.Add(2 * time.Second), FromState: "FUNDED", ToState: "FAILED"},
+	}
+	for _, entry := range appended {
+		require.NoError(t, db.Update(func(tx *bolt.Tx) error { return AppendJobHistory(tx, []byte{1}, entry) }))
+	}
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		if err := AppendJobHistory(tx, []byte{2}, HistoryEntry{Timestamp: now, ToState: "PENDING"}); err != nil {
+			return err
+		}
+		return AppendJobHistory(tx, []byte{2}, HistoryEntry{Timestamp: now, FromState: "PENDING", ToState: "FUNDED"})
+	}))
+
+	entries, err := GetJobHistory(db, []byte{1})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.True(t, appended[i].Timestamp.Equal(entry.Timestamp))
+		assert.Equal(t, appended[i].FromState, entry.FromState)
+		assert.Equal(t, appended[i].ToState, entry.ToState)
+		assert.Equal(t, appended[i].TxHash, entry.TxHash)
+		assert.Len(t, entry.Hash, 32)
+	}
+	assert.NotEqual(t, entries[0].Hash, entries[1].Hash)
+
+	entries, err = GetJobHistory(db, []byte{3})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Rewriting an entry, even with a matching hash of its own, breaks the chain
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(HistoryBucketName).Bucket([]byte{1})
+		k, v := bucket.Cursor().First()
+		entry := HistoryEntry{}
+		require.NoError(t, json.Unmarshal(v, &entry))
+		entry.ToState = "FUNDED"
+		entry.Hash, err = hashEntry(entry, nil)
+		require.NoError(t, err)
+		entryBytes, err := json.Marshal(entry)
+		require.NoError(t, err)
+		return bucket.Put(k, entryBytes)
+	}))
+	_, err = GetJobHistory(db, []byte{1})
+	assert.Error(t, err)
+
+	// Other jobs' histories are unaffected, until an entry is removed from them
+	entries, err = GetJobHistory(db, []byte{2})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(HistoryBucketName).Bucket([]byte{2})
+		k, _ := bucket.Cursor().First()
+		return bucket.Delete(k)
+	}))
+	_, err = GetJobHistory(db, []byte{2})
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// SchemaVersion is the version of the db layout this code reads and writes, stored under SchemaVersionKey. It is
+// bumped, with a migration added to migrations, whenever stored records must be rewritten for the code to read them
+// as intended.
+const SchemaVersion = 2
+
+// migration rewrites a db at the previous schema version to version
+type migration struct {
+	version     uint64
+	description string
+	migrate     func(tx *bolt.Tx) error
+}
+
+// migrations are run in order on a db whose stored schema version is behind SchemaVersion, each in the transaction
+// that records its version
+var migrations = []migration{
+	{2, "stamp jobs stored before job timestamps with the migration time", stampJobTimestamps},
+}
+
+// migrate brings the db opened from path up to SchemaVersion, backing the file up next to it before the first
+// migration. A db without a stored version is at version 1, the layout before versioning, unless it is empty, in
+// which case it is simply stamped with the current version. A db at a newer version than the code is refused.
+func migrate(db *bolt.DB, path string) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if version > SchemaVersion {
+		return errors.Errorf("db schema version %v is newer than the version %v this daemon supports; upgrade the "+
+			"daemon, or restore the backup of the db taken before it was migrated", version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+
+	backupPath := fmt.Sprintf("%v.v%v.bak", path, version)
+	if err := db.View(func(tx *bolt.Tx) error { return tx.CopyFile(backupPath, 0644) }); err != nil {
+		return errors.Wrap(err, "error backing up db before migrating it")
+	}
+	log.WithField("backup", backupPath).WithField("fromVersion", version).WithField("toVersion", SchemaVersion).
+		Info("migrating db schema")
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.migrate(tx); err != nil {
+				return err
+			}
+			return putSchemaVersion(tx, m.version)
+		}); err != nil {
+			return errors.Wrapf(err, "error migrating db to schema version %v (%v); the db is restorable from %v",
+				m.version, m.description, backupPath)
+		}
+		log.WithField("version", m.version).WithField("migration", m.description).Info("migrated db schema")
+	}
+	return nil
+}
+
+// schemaVersion returns the stored schema version, stamping an empty db with the current one
+func schemaVersion(db *bolt.DB) (uint64, error) {
+	var version uint64
+	err := db.Update(func(tx *bolt.Tx) error {
+		chain := tx.Bucket(ChainBucketName)
+		if versionBytes := chain.Get(SchemaVersionKey); versionBytes != nil {
+			if len(versionBytes) != 8 {
+				return errors.Errorf("invalid db schema version %x", versionBytes)
+			}
+			version = binary.BigEndian.Uint64(versionBytes)
+			return nil
+		}
+
+		if k, _ := chain.Cursor().First(); k == nil {
+			if k, _ := tx.Bucket(JobBucketName).Cursor().First(); k == nil {
+				version = SchemaVersion
+				return putSchemaVersion(tx, version)
+			}
+		}
+		version = 1
+		return nil
+	})
+	return version, errors.Wrap(err, "error reading db schema version")
+}
+
+func putSchemaVersion(tx *bolt.Tx, version uint64) error {
+	versionBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(versionBytes, version)
+	return tx.Bucket(ChainBucketName).Put(SchemaVersionKey, versionBytes)
+}
+
+// stampJobTimestamps sets the timestamps of jobs stored before jobs had them to now, so they count as written at
+// migration rather than at the zero time. Records that fail to deserialize are left as they are.
+func stampJobTimestamps(tx *bolt.Tx) error {
+	bucket := tx.Bucket(JobBucketName)
+	now := time.Now()
+
+	stamped := make(map[string][]byte)
+	if err := bucket.ForEach(func(k, v []byte) error {
+		job := &Job{}
+		if err := json.Unmarshal(v, job); err != nil || !job.UpdatedAt.IsZero() {
+			return nil
+		}
+		job.Touch(now)
+		jobBytes, err := json.Marshal(job)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling job")
+		}
+		stamped[string(k)] = jobBytes
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// The bucket can't be modified while iterating it
+	for k, jobBytes := range stamped {
+		if err := bucket.Put([]byte(k), jobBytes); err != nil {
+			return errors.Wrap(err, "error putting job to db")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/bbolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeV1DB writes a db as the daemon left it before schema versioning: jobs without timestamps or the fields added
+// since, and an event cursor
+func writeV1DB(t *testing.T, path string) {
+	db, err := bolt.Open(path, 0644, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		jobs, err := tx.CreateBucket(JobBucketName)
+		if err != nil {
+			return err
+		}
+		if err := jobs.Put([]byte{1}, []byte(`{"JobAddress":"AQ==","JobSignature":null,"JobState":"FUNDED",`+
+			`"Consumer":"Ag==","Completed":false}`)); err != nil {
+			return err
+		}
+		if err := jobs.Put([]byte{3}, []byte("{not json")); err != nil {
+			return err
+		}
+		chain, err := tx.CreateBucket(ChainBucketName)
+		if err != nil {
+			return err
+		}
+		return chain.Put(LastBlockKey, []byte{42})
+	}))
+}
+
+func storedSchemaVersion(t *testing.T, db *bolt.DB) []byte {
+	var version []byte
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		version = append(version, tx.Bucket(ChainBucketName).Get(SchemaVersionKey)...)
+		return nil
+	}))
+	return version
+}
+
+func TestMigrateV1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snetd-db")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snetd.db")
+	writeV1DB(t, path)
+
+	db, err := Connect(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	current := make([]byte, 8)
+	binary.BigEndian.PutUint64(current, SchemaVersion)
+	assert.Equal(t, current, storedSchemaVersion(t, db))
+
+	// The job keeps its fields and gains timestamps; the corrupt record is left alone
+	job, err := GetJob(db, []byte{1})
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, "FUNDED", job.JobState)
+	assert.Equal(t, []byte{2}, job.Consumer)
+	assert.False(t, job.CreatedAt.IsZero())
+	assert.False(t, job.UpdatedAt.IsZero())
+	_, err = GetJob(db, []byte{3})
+	assert.Error(t, err)
+
+	// The backup holds the db as it was before the migration
+	backup, err := bolt.Open(path+".v1.bak", 0644, nil)
+	require.NoError(t, err)
+	defer backup.Close()
+	assert.Empty(t, storedSchemaVersion(t, backup))
+	legacy, err := GetJob(backup, []byte{1})
+	require.NoError(t, err)
+	require.NotNil(t, legacy)
+	assert.True(t, legacy.UpdatedAt.IsZero())
+
+	// A migrated db opens without migrating again
+	db.Close()
+	require.NoError(t, os.Remove(path+".v1.bak"))
+	db, err = Connect(path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = os.Stat(path + ".v1.bak")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMigrateNewDB(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	version := storedSchemaVersion(t, db)
+	require.Len(t, version, 8)
+	assert.Equal(t, uint64(SchemaVersion), binary.BigEndian.Uint64(version))
+}
+
+func TestMigrateRefusesNewerDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snetd-db")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snetd.db")
+
+	db, err := Connect(path)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error { return putSchemaVersion(tx, SchemaVersion+1) }))
+	db.Close()
+
+	_, err = Connect(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "upgrade the daemon")
+}
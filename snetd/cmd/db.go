@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/coreos/bbolt"
+	"github.com/pkg/errors"
+	"github.com/singnet/snet-daemon/config"
+	"github.com/singnet/snet-daemon/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ExportDBCmd writes the job database at --db-path to a file, or stdout, for ImportDBCmd to load on another host. The
+// daemon holds the database open while running, so it must be stopped first.
+var ExportDBCmd = &cobra.Command{
+	Use:   "export-db [file]",
+	Short: "export the job database to a file, or stdout",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := exportDB(args); err != nil {
+			log.WithError(err).Error("Unable to export job database")
+			os.Exit(1)
+		}
+	},
+}
+
+// ImportDBCmd loads a job database export from a file, or stdin, into the database at --db-path
+var ImportDBCmd = &cobra.Command{
+	Use:   "import-db [file]",
+	Short: "import a job database export from a file, or stdin",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := importDB(args); err != nil {
+			log.WithError(err).Error("Unable to import job database")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	ServeCmd.AddCommand(ExportDBCmd, ImportDBCmd)
+}
+
+func exportDB(args []string) error {
+	out := io.Writer(os.Stdout)
+	if len(args) > 0 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return errors.Wrap(err, "unable to create export file")
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return withDB(func(boltDB *bolt.DB) error { return db.ExportJobs(boltDB, out) })
+}
+
+func importDB(args []string) error {
+	in := io.Reader(os.Stdin)
+	if len(args) > 0 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return errors.Wrap(err, "unable to open export file")
+		}
+		defer file.Close()
+		in = file
+	}
+
+	return withDB(func(boltDB *bolt.DB) error { return db.ImportJobs(boltDB, in) })
+}
+
+// withDB runs fn with the database at --db-path open
+func withDB(fn func(*bolt.DB) error) error {
+	boltDB, err := db.Connect(config.GetString(config.DbPathKey))
+	if err != nil {
+		return err
+	}
+	defer boltDB.Close()
+
+	return fn(boltDB)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -9,6 +10,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/coreos/bbolt"
 	"github.com/gorilla/handlers"
@@ -39,12 +41,20 @@ var ServeCmd = &cobra.Command{
 			os.Exit(2)
 		}
 
-		d.start()
+		if err := d.start(); err != nil {
+			log.WithError(err).Error("Unable to start daemon")
+			os.Exit(2)
+		}
 		defer d.stop()
 
 		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
-		<-sigChan
+		signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+		for sig := range sigChan {
+			if sig != syscall.SIGHUP {
+				break
+			}
+			d.reload()
+		}
 
 		log.Debug("exiting")
 	},
@@ -54,6 +64,10 @@ type daemon struct {
 	autoSSLDomain string
 	acmeListener  net.Listener
 	grpcServer    *grpc.Server
+	jobsServer    *grpc.Server
+	healthServer  *http.Server
+	jobsAPIServer *http.Server
+	adminServer   *http.Server
 	blockProc     blockchain.Processor
 	lis           net.Listener
 	boltDB        *bolt.DB
@@ -108,8 +122,65 @@ func newDaemon() (daemon, error) {
 	return d, nil
 }
 
-func (d daemon) start() {
-	d.blockProc.StartLoop()
+// reload re-reads the config file and applies its hot-reloadable settings to the blockchain processor; anything else
+// changed in it takes effect on restart
+func (d daemon) reload() {
+	if err := config.Reload(); err != nil {
+		log.WithError(err).Error("Unable to reload config; keeping running settings")
+		return
+	}
+
+	if err := d.blockProc.Reload(); err != nil {
+		log.WithError(err).Error("Unable to apply reloaded config; keeping running settings")
+	}
+}
+
+func (d *daemon) start() error {
+	if err := d.blockProc.StartLoop(); err != nil {
+		return errors.Wrap(err, "unable to start blockchain processor")
+	}
+
+	if healthListen := config.GetString(config.HealthListenKey); healthListen != "" {
+		log.Debug("starting health listener")
+		healthLis, err := net.Listen("tcp", healthListen)
+		if err != nil {
+			return errors.Wrap(err, "error listening for health checks")
+		}
+		d.healthServer = &http.Server{
+			Handler: d.blockProc.HealthHandler(config.GetDuration(config.HealthStalenessKey)),
+		}
+		go d.healthServer.Serve(healthLis)
+	}
+
+	if jobsListen := config.GetString(config.JobsListenKey); jobsListen != "" {
+		log.Debug("starting jobs API listener")
+		jobsLis, err := net.Listen("tcp", jobsListen)
+		if err != nil {
+			return errors.Wrap(err, "error listening for jobs API")
+		}
+		d.jobsAPIServer = &http.Server{Handler: d.blockProc.JobsHandler()}
+		go d.jobsAPIServer.Serve(jobsLis)
+	}
+
+	if adminListen := config.GetString(config.AdminListenKey); adminListen != "" {
+		log.Debug("starting admin API listener")
+		adminLis, err := net.Listen("tcp", adminListen)
+		if err != nil {
+			return errors.Wrap(err, "error listening for admin API")
+		}
+		d.adminServer = &http.Server{Handler: d.blockProc.AdminHandler()}
+		go d.adminServer.Serve(adminLis)
+	}
+
+	if jobsGrpcListen := config.GetString(config.JobsGrpcListenKey); jobsGrpcListen != "" {
+		log.Debug("starting jobs gRPC API listener")
+		jobsLis, err := net.Listen("tcp", jobsGrpcListen)
+		if err != nil {
+			return errors.Wrap(err, "error listening for jobs gRPC API")
+		}
+		d.jobsServer = d.blockProc.JobsGrpcServer()
+		go d.jobsServer.Serve(jobsLis)
+	}
 
 	var tlsConfig *tls.Config
 
@@ -188,22 +259,44 @@ func (d daemon) start() {
 
 		go http.Serve(d.lis, handlers.CORS(corsOptions...)(handler.GetHTTPHandler(d.blockProc)))
 	}
+	return nil
 }
 
 func (d daemon) stop() {
-	if d.boltDB != nil {
-		d.boltDB.Close()
-	}
-
 	if d.grpcServer != nil {
 		d.grpcServer.Stop()
 	}
 
+	if d.jobsServer != nil {
+		d.jobsServer.Stop()
+	}
+
+	if d.healthServer != nil {
+		d.healthServer.Close()
+	}
+
+	if d.jobsAPIServer != nil {
+		d.jobsAPIServer.Close()
+	}
+
+	if d.adminServer != nil {
+		d.adminServer.Close()
+	}
+
 	d.lis.Close()
 
 	if d.acmeListener != nil {
 		d.acmeListener.Close()
 	}
 
-	// TODO(aiden) add d.blockProc.StopLoop()
+	// Stop the blockchain processor before closing the db it writes to
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := d.blockProc.Stop(ctx); err != nil {
+		log.WithError(err).Error("error stopping blockchain processor")
+	}
+
+	if d.boltDB != nil {
+		d.boltDB.Close()
+	}
 }